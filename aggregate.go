@@ -0,0 +1,121 @@
+//go:build windows
+
+package proxdll
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+)
+
+// Aggregate proxies exports backed by more than one original DLL, such as a plugin
+// interface spread over several vendor DLLs. Each export is served by the Manager of
+// the DLL it is mapped to.
+type Aggregate struct {
+	managers map[string]*Manager // by DLL path
+	exports  map[string]*Manager // by export name
+}
+
+var _ HookRegistry = (*Aggregate)(nil)
+
+// NewAggregate creates an Aggregate from exports, which maps each export name to the path
+// of the original DLL implementing it. One Manager is created per distinct DLL with opts.
+func NewAggregate(exports map[string]string, opts ...Option) (*Aggregate, error) {
+	a := &Aggregate{
+		managers: make(map[string]*Manager),
+		exports:  make(map[string]*Manager, len(exports)),
+	}
+	for _, name := range slices.Sorted(maps.Keys(exports)) {
+		path := exports[name]
+		m, ok := a.managers[path]
+		if !ok {
+			var err error
+			if m, err = New(path, opts...); err != nil {
+				a.Free()
+				return nil, fmt.Errorf("failed to create manager for %s: %w", path, err)
+			}
+			a.managers[path] = m
+		}
+		a.exports[name] = m
+	}
+	return a, nil
+}
+
+// Manager returns the Manager serving funcName.
+func (a *Aggregate) Manager(funcName string) (*Manager, error) {
+	m, ok := a.exports[funcName]
+	if !ok {
+		return nil, fmt.Errorf("no original DLL mapped for export %s", funcName)
+	}
+	return m, nil
+}
+
+// Managers returns the Manager of each original DLL, keyed by path.
+func (a *Aggregate) Managers() map[string]*Manager {
+	return maps.Clone(a.managers)
+}
+
+// Call invokes funcName through the Manager serving it, as Manager.Call does.
+// Unmapped exports fail with an error rather than panicking.
+func (a *Aggregate) Call(funcName string, args ...uintptr) (r1, r2 uintptr, lastErr error) {
+	m, err := a.Manager(funcName)
+	if err != nil {
+		return 0, 0, err
+	}
+	return m.Call(funcName, args...)
+}
+
+// RegisterHook installs hook for funcName on the Manager serving it. Patterns are
+// installed on every Manager, so they cover matching exports of all original DLLs.
+func (a *Aggregate) RegisterHook(funcName string, hook Hook) {
+	for _, m := range a.hookTargets(funcName) {
+		m.RegisterHook(funcName, hook)
+	}
+}
+
+// UnregisterHook removes the hooks for funcName installed by RegisterHook.
+func (a *Aggregate) UnregisterHook(funcName string) {
+	for _, m := range a.hookTargets(funcName) {
+		m.UnregisterHook(funcName)
+	}
+}
+
+// hookTargets returns the Managers a hook registered under key applies to.
+func (a *Aggregate) hookTargets(key string) []*Manager {
+	if isHookPattern(key) {
+		return slices.Collect(maps.Values(a.managers))
+	}
+	if m, ok := a.exports[key]; ok {
+		return []*Manager{m}
+	}
+	return nil
+}
+
+// Shutdown shuts down every Manager, as Manager.Shutdown does.
+func (a *Aggregate) Shutdown() error {
+	var errs []error
+	for _, m := range a.managers {
+		errs = append(errs, m.Shutdown())
+	}
+	return errors.Join(errs...)
+}
+
+// ShutdownAtDetach shuts down every Manager, as Manager.ShutdownAtDetach does, for the
+// lifecycle package's process detach notification.
+func (a *Aggregate) ShutdownAtDetach() error {
+	var errs []error
+	for _, m := range a.managers {
+		errs = append(errs, m.ShutdownAtDetach())
+	}
+	return errors.Join(errs...)
+}
+
+// Free unloads every original DLL.
+func (a *Aggregate) Free() error {
+	var errs []error
+	for _, m := range a.managers {
+		errs = append(errs, m.Free())
+	}
+	return errors.Join(errs...)
+}
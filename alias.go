@@ -0,0 +1,33 @@
+//go:build windows
+
+package proxdll
+
+// SetAlias makes calls to the proxy's export funcName resolve originalName in the original
+// DLL instead, such as an API renamed between library versions. originalName may be an
+// ordinal of the form "#N". An empty originalName removes the alias. Aliases in the current
+// Config take precedence.
+func (m *Manager) SetAlias(funcName, originalName string) {
+	m.mu.Lock()
+	if originalName == "" {
+		delete(m.aliases, funcName)
+	} else {
+		m.aliases[funcName] = originalName
+	}
+	m.mu.Unlock()
+	m.procs.evict(funcName)
+}
+
+// originalName returns the name funcName resolves to in the original DLL.
+func (m *Manager) originalName(funcName string) string {
+	if s := m.config.Load(); s != nil {
+		if name, ok := s.aliases[funcName]; ok {
+			return name
+		}
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if name, ok := m.aliases[funcName]; ok {
+		return name
+	}
+	return funcName
+}
@@ -0,0 +1,54 @@
+//go:build windows
+
+package proxdll
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/windows"
+)
+
+// Attach creates a Manager for a module the process has already loaded, such as one the
+// host mapped from a path the proxy does not know, instead of loading a new copy.
+// moduleName is matched by the system loader like GetModuleHandle, by file name or full path.
+// The Manager takes its own reference to the module, which Free releases, so the module
+// stays loaded for as long as the Manager uses it.
+func Attach(moduleName string, opts ...Option) (*Manager, error) {
+	attach := func(m *Manager) { m.attach = true }
+	return New(moduleName, append(opts[:len(opts):len(opts)], attach)...)
+}
+
+// attachModule returns the loaded module named name, adding a reference to it.
+func attachModule(name string) (Module, error) {
+	p, err := windows.UTF16PtrFromString(name)
+	if err != nil {
+		return nil, err
+	}
+	var h windows.Handle
+	// Without GET_MODULE_HANDLE_EX_FLAG_UNCHANGED_REFCOUNT the reference is released by FreeLibrary.
+	if err := windows.GetModuleHandleEx(0, p, &h); err != nil {
+		return nil, fmt.Errorf("module %s is not loaded: %w", name, err)
+	}
+	return &systemModule{dll: &windows.DLL{Name: name, Handle: h}}, nil
+}
+
+// pinModule keeps mod loaded until the process exits, whatever FreeLibrary calls follow.
+func pinModule(mod Module) error {
+	h := mod.Handle()
+	if h == 0 {
+		return fmt.Errorf("module %s is not known to the system loader", mod.Name())
+	}
+	path, err := modulePath(h)
+	if err != nil {
+		return err
+	}
+	p, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return err
+	}
+	var pinned windows.Handle
+	if err := windows.GetModuleHandleEx(windows.GET_MODULE_HANDLE_EX_FLAG_PIN, p, &pinned); err != nil {
+		return fmt.Errorf("failed to pin module %s: %w", mod.Name(), err)
+	}
+	return nil
+}
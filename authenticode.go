@@ -0,0 +1,214 @@
+//go:build windows
+
+package proxdll
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// cmsgSignerInfoParam is CMSG_SIGNER_INFO_PARAM, which retrieves a CMSG_SIGNER_INFO.
+const cmsgSignerInfoParam = 6
+
+// cmsgSignerInfo mirrors the leading fields of CMSG_SIGNER_INFO.
+type cmsgSignerInfo struct {
+	version uint32
+	issuer  windows.CertNameBlob
+	serial  windows.CryptIntegerBlob
+}
+
+// VerifyAuthenticode checks the Authenticode signature of the file at path with
+// WinVerifyTrust and returns the display name of its signer, such as "Microsoft Windows".
+// A file without an embedded signature, as many system DLLs are, is instead verified
+// against the system catalog listing its hash, and the catalog's signer is returned.
+// Revocation is not checked, so verification works offline.
+func VerifyAuthenticode(path string) (signer string, err error) {
+	path16, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return "", err
+	}
+	signed, content := path16, uint32(windows.CERT_QUERY_CONTENT_FLAG_PKCS7_SIGNED_EMBED)
+	verifyErr := verifyTrust(windows.WTD_CHOICE_FILE, unsafe.Pointer(&windows.WinTrustFileInfo{
+		Size:     uint32(unsafe.Sizeof(windows.WinTrustFileInfo{})),
+		FilePath: path16,
+	}))
+	if verifyErr == windows.Errno(windows.TRUST_E_NOSIGNATURE) {
+		signed, verifyErr = verifyCatalog(path16)
+		content = windows.CERT_QUERY_CONTENT_FLAG_PKCS7_SIGNED | windows.CERT_QUERY_CONTENT_FLAG_CTL
+	}
+	if verifyErr != nil {
+		return "", fmt.Errorf("failed to verify signature of %s: %w", path, verifyErr)
+	}
+
+	signer, err = signerName(signed, content)
+	if err != nil {
+		return "", fmt.Errorf("failed to read signer of %s: %w", path, err)
+	}
+	return signer, nil
+}
+
+// verifyTrust runs WinVerifyTrust on the file, catalog member or other object info
+// describes, as choice tells.
+func verifyTrust(choice uint32, info unsafe.Pointer) error {
+	data := &windows.WinTrustData{
+		Size:                            uint32(unsafe.Sizeof(windows.WinTrustData{})),
+		UIChoice:                        windows.WTD_UI_NONE,
+		RevocationChecks:                windows.WTD_REVOKE_NONE,
+		UnionChoice:                     choice,
+		StateAction:                     windows.WTD_STATEACTION_VERIFY,
+		FileOrCatalogOrBlobOrSgnrOrCert: info,
+	}
+	err := windows.WinVerifyTrustEx(windows.InvalidHWND, &windows.WINTRUST_ACTION_GENERIC_VERIFY_V2, data)
+	data.StateAction = windows.WTD_STATEACTION_CLOSE
+	windows.WinVerifyTrustEx(windows.InvalidHWND, &windows.WINTRUST_ACTION_GENERIC_VERIFY_V2, data)
+	return err
+}
+
+// winTrustCatalogInfo mirrors WINTRUST_CATALOG_INFO.
+type winTrustCatalogInfo struct {
+	size                   uint32
+	catalogVersion         uint32
+	catalogFilePath        *uint16
+	memberTag              *uint16
+	memberFilePath         *uint16
+	memberFile             windows.Handle
+	calculatedFileHash     *byte
+	calculatedFileHashSize uint32
+	catalogContext         uintptr
+	catAdmin               windows.Handle
+}
+
+// catalogInfo mirrors CATALOG_INFO.
+type catalogInfo struct {
+	size        uint32
+	catalogFile [windows.MAX_PATH]uint16
+}
+
+// verifyCatalog verifies the file at path against the system catalogs listing its
+// SHA-256 hash and returns the path of the first catalog that vouches for it.
+func verifyCatalog(path *uint16) (catalog *uint16, err error) {
+	f, err := windows.CreateFile(path, windows.GENERIC_READ, windows.FILE_SHARE_READ, nil, windows.OPEN_EXISTING, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer windows.CloseHandle(f)
+
+	alg, err := windows.UTF16PtrFromString("SHA256")
+	if err != nil {
+		return nil, err
+	}
+	var admin windows.Handle
+	if r, _, err := procCryptCATAdminAcquireContext2.Call(uintptr(unsafe.Pointer(&admin)), 0, uintptr(unsafe.Pointer(alg)), 0, 0); r == 0 {
+		return nil, fmt.Errorf("failed to open catalog database: %w", err)
+	}
+	defer procCryptCATAdminReleaseContext.Call(uintptr(admin), 0)
+
+	var size uint32
+	procCryptCATAdminCalcHashFromFileHandle2.Call(uintptr(admin), uintptr(f), uintptr(unsafe.Pointer(&size)), 0, 0)
+	if size == 0 {
+		return nil, errors.New("failed to size catalog hash")
+	}
+	hash := make([]byte, size)
+	if r, _, err := procCryptCATAdminCalcHashFromFileHandle2.Call(uintptr(admin), uintptr(f), uintptr(unsafe.Pointer(&size)), uintptr(unsafe.Pointer(&hash[0])), 0); r == 0 {
+		return nil, fmt.Errorf("failed to hash file for catalog lookup: %w", err)
+	}
+	// Catalogs tag their members with the hash in upper-case hex.
+	tag, err := windows.UTF16PtrFromString(strings.ToUpper(hex.EncodeToString(hash[:size])))
+	if err != nil {
+		return nil, err
+	}
+
+	// Without a catalog listing the hash the file is simply unsigned.
+	err = windows.Errno(windows.TRUST_E_NOSIGNATURE)
+	var prev uintptr
+	for {
+		// Each call releases the context the previous one returned.
+		cat, _, _ := procCryptCATAdminEnumCatalogFromHash.Call(uintptr(admin), uintptr(unsafe.Pointer(&hash[0])), uintptr(size), 0, uintptr(unsafe.Pointer(&prev)))
+		if cat == 0 {
+			return nil, err
+		}
+		prev = cat
+		info := catalogInfo{size: uint32(unsafe.Sizeof(catalogInfo{}))}
+		if r, _, infoErr := procCryptCATCatalogInfoFromContext.Call(cat, uintptr(unsafe.Pointer(&info)), 0); r == 0 {
+			err = fmt.Errorf("failed to read catalog info: %w", infoErr)
+			continue
+		}
+		err = verifyTrust(windows.WTD_CHOICE_CATALOG, unsafe.Pointer(&winTrustCatalogInfo{
+			size:                   uint32(unsafe.Sizeof(winTrustCatalogInfo{})),
+			catalogFilePath:        &info.catalogFile[0],
+			memberTag:              tag,
+			memberFilePath:         path,
+			memberFile:             f,
+			calculatedFileHash:     &hash[0],
+			calculatedFileHashSize: size,
+			catAdmin:               admin,
+		}))
+		if err == nil {
+			procCryptCATAdminReleaseCatalogContext.Call(uintptr(admin), cat, 0)
+			return &info.catalogFile[0], nil
+		}
+	}
+}
+
+// signerName returns the display name of the certificate that signed the file at path,
+// whose content is of the CERT_QUERY_CONTENT_FLAG_* types given.
+func signerName(path *uint16, content uint32) (string, error) {
+	var encoding, contentType, formatType uint32
+	var store, msg windows.Handle
+	err := windows.CryptQueryObject(windows.CERT_QUERY_OBJECT_FILE, unsafe.Pointer(path),
+		content, windows.CERT_QUERY_FORMAT_FLAG_BINARY, 0,
+		&encoding, &contentType, &formatType, &store, &msg, nil)
+	if err != nil {
+		return "", err
+	}
+	defer windows.CertCloseStore(store, 0)
+	defer procCryptMsgClose.Call(uintptr(msg))
+
+	var size uint32
+	if r, _, err := procCryptMsgGetParam.Call(uintptr(msg), cmsgSignerInfoParam, 0, 0, uintptr(unsafe.Pointer(&size))); r == 0 {
+		return "", err
+	}
+	// A []uint64 keeps the structure at the start of the buffer aligned.
+	buf := make([]uint64, (size+7)/8)
+	if r, _, err := procCryptMsgGetParam.Call(uintptr(msg), cmsgSignerInfoParam, 0, uintptr(unsafe.Pointer(&buf[0])), uintptr(unsafe.Pointer(&size))); r == 0 {
+		return "", err
+	}
+	info := (*cmsgSignerInfo)(unsafe.Pointer(&buf[0]))
+
+	find := windows.CertInfo{Issuer: info.issuer, SerialNumber: info.serial}
+	cert, err := windows.CertFindCertificateInStore(store, windows.X509_ASN_ENCODING|windows.PKCS_7_ASN_ENCODING,
+		0, windows.CERT_FIND_SUBJECT_CERT, unsafe.Pointer(&find), nil)
+	if err != nil {
+		return "", err
+	}
+	defer windows.CertFreeCertificateContext(cert)
+
+	n := windows.CertGetNameString(cert, windows.CERT_NAME_SIMPLE_DISPLAY_TYPE, 0, nil, nil, 0)
+	name := make([]uint16, n)
+	windows.CertGetNameString(cert, windows.CERT_NAME_SIMPLE_DISPLAY_TYPE, 0, nil, &name[0], n)
+	return windows.UTF16ToString(name), nil
+}
+
+// authenticodeVerifier accepts validly signed files, from one of signers if any are given.
+func authenticodeVerifier(signers []string) verifier {
+	return verifier{name: "authenticode", check: func(path string) error {
+		signer, err := VerifyAuthenticode(path)
+		if err != nil {
+			return err
+		}
+		if len(signers) == 0 {
+			return nil
+		}
+		for _, want := range signers {
+			if strings.EqualFold(signer, want) {
+				return nil
+			}
+		}
+		return fmt.Errorf("signer %q is not among the required signers", signer)
+	}}
+}
@@ -0,0 +1,180 @@
+//go:build windows
+
+package proxdll
+
+import (
+	"fmt"
+	"reflect"
+	"runtime"
+	"syscall"
+	"unsafe"
+)
+
+// Bind resolves funcName once and returns a function that calls the original directly.
+// The returned function skips the cache lookup and locking done by TryCallOriginal,
+// which matters for exports called at very high rates. Hooks are not run.
+// After Reload, the function looks funcName up again in the new original.
+func (m *Manager) Bind(funcName string) (func(args ...uintptr) (r1, r2 uintptr, lastErr error), error) {
+	bound := m.calls.Load()
+	proc, err := m.GetOriginalFunc(funcName)
+	if err != nil {
+		return nil, err
+	}
+	return func(args ...uintptr) (r1, r2 uintptr, lastErr error) {
+		gate, ok := m.enterCall()
+		if !ok {
+			return 0, 0, ErrFreed
+		}
+		defer gate.exit()
+
+		if gate != bound {
+			proc, err := m.GetOriginalFunc(funcName)
+			if err != nil {
+				return 0, 0, err
+			}
+			return proc.Call(args...)
+		}
+		return proc.Call(args...)
+	}, nil
+}
+
+// errorType is the type of the optional last result of functions bound with Bind.
+var errorType = reflect.TypeFor[error]()
+
+// Bind returns a typed function calling funcName of the original, so arguments and
+// results are converted to the widths the Go types declare instead of by hand:
+//
+//	messageBox, err := proxdll.Bind[func(hwnd windows.HWND, text, caption *uint16, flags uint32) (int32, error)](m, "MessageBoxW")
+//
+// F must be a func type. Parameters may be booleans, integers, uintptr and its named
+// types, and pointers; on 386, 64-bit integers take two argument slots, low half first.
+// F may return a value of one of those types, taken from r1 (and r2 for 64-bit values
+// on 386), followed by an error. The error is the failure reported by the Convention of
+// funcName's Signature, or without one the thread's last error, nil when zero; or the
+// error from reaching the original, such as ErrFreed. Without an error result,
+// a failure to reach the original yields the zero value. Floating-point parameters and
+// results are not supported, since they are passed in different registers. Like
+// Manager.Bind, the function does not run hooks.
+func Bind[F any](m *Manager, funcName string) (F, error) {
+	var zero F
+	t := reflect.TypeFor[F]()
+	if t.Kind() != reflect.Func || t.IsVariadic() {
+		return zero, fmt.Errorf("cannot bind %s to %v: not a func type with fixed parameters", funcName, t)
+	}
+	for i := range t.NumIn() {
+		if !bindable(t.In(i)) {
+			return zero, fmt.Errorf("cannot bind %s to %v: unsupported parameter type %v", funcName, t, t.In(i))
+		}
+	}
+	results := t.NumOut()
+	withErr := results > 0 && t.Out(results-1) == errorType
+	if withErr {
+		results--
+	}
+	if results > 1 || results == 1 && !bindable(t.Out(0)) {
+		return zero, fmt.Errorf("cannot bind %s to %v: results must be a value and an optional error", funcName, t)
+	}
+
+	call, err := m.Bind(funcName)
+	if err != nil {
+		return zero, err
+	}
+	fn := reflect.MakeFunc(t, func(in []reflect.Value) []reflect.Value {
+		args := make([]uintptr, 0, len(in))
+		for _, v := range in {
+			args = appendArg(args, v)
+		}
+		r1, r2, lastErr := call(args...)
+		// Pointer arguments are only referenced by in during the call.
+		runtime.KeepAlive(in)
+		unreached := lastErr != nil && !isErrno(lastErr)
+		lastErr = m.checkResult(funcName, r1, lastErr)
+
+		out := make([]reflect.Value, 0, t.NumOut())
+		if results == 1 {
+			if unreached {
+				out = append(out, reflect.Zero(t.Out(0)))
+			} else {
+				out = append(out, convertResult(t.Out(0), r1, r2))
+			}
+		}
+		if withErr {
+			errVal := reflect.New(errorType).Elem()
+			if lastErr != nil {
+				errVal.Set(reflect.ValueOf(lastErr))
+			}
+			out = append(out, errVal)
+		}
+		return out
+	})
+	return fn.Interface().(F), nil
+}
+
+// isErrno reports whether err is a last error set by the called function, rather than
+// a failure to call it.
+func isErrno(err error) bool {
+	_, ok := err.(syscall.Errno)
+	return ok
+}
+
+// bindable reports whether values of t fit in argument registers.
+func bindable(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Pointer, reflect.UnsafePointer:
+		return true
+	}
+	return false
+}
+
+// wide reports whether t needs two argument slots.
+func wide(t reflect.Type) bool {
+	return t.Size() > unsafe.Sizeof(uintptr(0))
+}
+
+// appendArg appends the argument slots for v.
+func appendArg(args []uintptr, v reflect.Value) []uintptr {
+	var u uint64
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			u = 1
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		u = uint64(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		u = v.Uint()
+	case reflect.Pointer, reflect.UnsafePointer:
+		return append(args, v.Pointer())
+	}
+	if wide(v.Type()) {
+		return append(args, uintptr(uint32(u)), uintptr(u>>32))
+	}
+	return append(args, uintptr(u))
+}
+
+// convertResult converts the raw results to a value of t, truncated to its width.
+func convertResult(t reflect.Type, r1, r2 uintptr) reflect.Value {
+	u := uint64(r1)
+	if wide(t) {
+		u = uint64(uint32(r1)) | uint64(r2)<<32
+	}
+	v := reflect.New(t).Elem()
+	bits := 64 - uint(t.Size())*8
+	switch t.Kind() {
+	case reflect.Bool:
+		// BOOL is 32 bits wide; the upper half of the register is undefined.
+		v.SetBool(uint32(u) != 0)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v.SetInt(int64(u<<bits) >> bits)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		v.SetUint(u << bits >> bits)
+	case reflect.UnsafePointer:
+		v.SetPointer(unsafe.Add(unsafe.Pointer(nil), r1))
+	case reflect.Pointer:
+		v.Set(reflect.NewAt(t.Elem(), unsafe.Add(unsafe.Pointer(nil), r1)))
+	}
+	return v
+}
@@ -0,0 +1,105 @@
+//go:build windows
+
+package proxdll
+
+import (
+	"maps"
+	"slices"
+	"sync"
+	"sync/atomic"
+)
+
+// procCache is a copy-on-write cache of resolved functions.
+// Lookups are a single atomic load and never take a lock, so concurrent callers on hot
+// paths do not contend; stores copy the map, which is cheap because they are rare.
+type procCache struct {
+	entries atomic.Pointer[map[string]*Proc]
+	mu      sync.Mutex // serializes stores
+}
+
+// load returns the cached proc for funcName.
+func (c *procCache) load(funcName string) (*Proc, bool) {
+	entries := c.entries.Load()
+	if entries == nil {
+		return nil, false
+	}
+	proc, ok := (*entries)[funcName]
+	return proc, ok
+}
+
+// store adds procs to the cache, replacing existing entries with the same names.
+func (c *procCache) store(procs map[string]*Proc) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	next := make(map[string]*Proc)
+	if entries := c.entries.Load(); entries != nil {
+		maps.Copy(next, *entries)
+	}
+	maps.Copy(next, procs)
+	c.entries.Store(&next)
+}
+
+// replace discards every cached proc and caches procs instead.
+func (c *procCache) replace(procs map[string]*Proc) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	next := maps.Clone(procs)
+	c.entries.Store(&next)
+}
+
+// names returns the names of the cached procs.
+func (c *procCache) names() []string {
+	entries := c.entries.Load()
+	if entries == nil {
+		return nil
+	}
+	return slices.Collect(maps.Keys(*entries))
+}
+
+// evict removes the procs for names from the cache, so they are resolved again.
+func (c *procCache) evict(names ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entries := c.entries.Load()
+	if entries == nil {
+		return
+	}
+	next := maps.Clone(*entries)
+	for _, name := range names {
+		delete(next, name)
+	}
+	c.entries.Store(&next)
+}
+
+// len returns the number of cached procs.
+func (c *procCache) len() int {
+	entries := c.entries.Load()
+	if entries == nil {
+		return 0
+	}
+	return len(*entries)
+}
+
+// CachedProcs returns a copy of the cache of functions resolved in the original, keyed
+// by the names callers used, which include aliases and "#N" ordinals.
+func (m *Manager) CachedProcs() map[string]*Proc {
+	entries := m.procs.entries.Load()
+	if entries == nil {
+		return map[string]*Proc{}
+	}
+	return maps.Clone(*entries)
+}
+
+// Evict removes funcNames from the cache of resolved functions, so their next call looks
+// them up in the original again. Functions returned by Bind keep the Proc they resolved.
+func (m *Manager) Evict(funcNames ...string) {
+	m.procs.evict(funcNames...)
+}
+
+// ClearCache empties the cache of resolved functions, as Evict does for every name.
+func (m *Manager) ClearCache() {
+	m.procs.replace(nil)
+}
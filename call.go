@@ -0,0 +1,106 @@
+package proxdll
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// Call describes an intercepted call to an export of the original DLL.
+type Call struct {
+	// Name is the name of the called export.
+	Name string
+	// Args holds the raw arguments passed by the host. A hook may change them before
+	// calling next, which passes Args to the original; see also the Set methods.
+	// Tracers still report the arguments as the host passed them.
+	Args []uintptr
+
+	// Depth is the number of calls through the Manager in progress on the calling thread,
+	// including this one, so it is 1 unless the call is reentrant; see CallDepth.
+	Depth int
+
+	// caller is the result of Caller, once callerDone is set.
+	caller     Caller
+	callerDone bool
+
+	// pinner keeps Go memory substituted by the Set methods in place until the hook returns.
+	pinner runtime.Pinner
+}
+
+// Next continues an intercepted call, ending in the original function.
+type Next func() (r1, r2 uintptr, lastErr error)
+
+// Hook intercepts calls to an export. A hook may inspect the call before invoking next,
+// observe or replace the results afterwards, or return without calling next at all.
+// Pre and Stub build hooks that only run before the original or replace it outright.
+type Hook func(call *Call, next Next) (r1, r2 uintptr, lastErr error)
+
+// RunHook runs hook for call, ending in next, and releases the memory the Set methods
+// pinned for call once hook returns, or panics. Code that builds its own Call to run a
+// hook, such as a dispatcher for COM methods, must run it through RunHook, as pinned
+// memory that is never released crashes the process.
+func RunHook(hook Hook, call *Call, next Next) (r1, r2 uintptr, lastErr error) {
+	defer call.pinner.Unpin()
+	return hook(call, next)
+}
+
+// HookRegistry is the part of Manager that installs hooks. Hook packs can take a
+// HookRegistry instead of a *Manager, so they can be tested against proxytest.Manager.
+type HookRegistry interface {
+	RegisterHook(funcName string, hook Hook)
+	UnregisterHook(funcName string)
+	Call(funcName string, args ...uintptr) (r1, r2 uintptr, lastErr error)
+}
+
+// SetArg replaces argument i, growing Args with zeros if it is too short.
+func (c *Call) SetArg(i int, v uintptr) {
+	if i >= len(c.Args) {
+		c.Args = append(c.Args, make([]uintptr, i+1-len(c.Args))...)
+	}
+	c.Args[i] = v
+}
+
+// Reentrant reports whether the call was made while another call through the Manager was
+// running on the same thread.
+func (c *Call) Reentrant() bool {
+	return c.Depth > 1
+}
+
+// Caller identifies the code that called an export of the proxy.
+type Caller struct {
+	// Address is the return address in the calling code.
+	Address uintptr `json:"address"`
+	// Module is the file name of the module containing Address, such as "game.exe",
+	// or empty if the address is not inside a loaded module.
+	Module string `json:"module,omitempty"`
+	// Offset is Address relative to the base of Module.
+	Offset uintptr `json:"offset,omitempty"`
+	// Symbol is the function containing Address, if symbols for Module were found.
+	Symbol string `json:"symbol,omitempty"`
+	// SymbolOffset is Address relative to the start of Symbol.
+	SymbolOffset uintptr `json:"symbolOffset,omitempty"`
+}
+
+// String formats the caller as "module!symbol+0xoffset" if its symbol is known, else as
+// "module+0xoffset", or as a bare address outside any module.
+func (c Caller) String() string {
+	switch {
+	case c.Symbol != "":
+		return fmt.Sprintf("%s!%s+%#x", c.Module, c.Symbol, c.SymbolOffset)
+	case c.Module != "":
+		return fmt.Sprintf("%s+%#x", c.Module, c.Offset)
+	case c.Address != 0:
+		return fmt.Sprintf("%#x", c.Address)
+	default:
+		return "unknown"
+	}
+}
+
+// Caller returns the code that called the export, capturing it on first use.
+// It must be called on the thread making the call, that is, from the hook itself.
+func (c *Call) Caller() Caller {
+	if !c.callerDone {
+		c.caller = captureCaller()
+		c.callerDone = true
+	}
+	return c.caller
+}
@@ -0,0 +1,200 @@
+//go:build windows
+
+package proxdll
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// ErrCallbackLimit is returned by NewCallback once every callback it may create is in use.
+var ErrCallbackLimit = errors.New("proxdll: callback limit reached")
+
+const (
+	// maxCallbacks bounds the trampolines NewCallback creates. The runtime allows about
+	// 2000 per process and never frees them, so some are left for the host's Go code.
+	maxCallbacks = 1024
+	// maxCallbackArgs is the most arguments a callback may take.
+	maxCallbackArgs = 16
+)
+
+// CallbackInfo describes a callback handed out by NewCallback and not yet released.
+type CallbackInfo struct {
+	Name    string
+	Args    int
+	CDecl   bool
+	Address uintptr
+	// Since is when the callback was handed out.
+	Since time.Time
+}
+
+// callbackKey identifies trampolines that can stand in for each other.
+type callbackKey struct {
+	args  int
+	cdecl bool
+}
+
+// callbackSlot is a trampoline and the function it currently calls.
+type callbackSlot struct {
+	addr uintptr
+	key  callbackKey
+	fn   atomic.Pointer[func(args []uintptr) uintptr]
+	// name and since describe the current use, guarded by callbacks.mu.
+	name  string
+	since time.Time
+}
+
+// invoke calls the slot's function. A trampoline called after its release returns 0.
+func (s *callbackSlot) invoke(args []uintptr) uintptr {
+	fn := s.fn.Load()
+	if fn == nil {
+		return 0
+	}
+	return (*fn)(args)
+}
+
+// callbacks holds every trampoline created, and those free for reuse by kind.
+var callbacks struct {
+	mu    sync.Mutex
+	all   []*callbackSlot
+	inUse map[*callbackSlot]bool
+	free  map[callbackKey][]*callbackSlot
+}
+
+// NewCallback returns the address of a stdcall (WINAPI) function taking args
+// pointer-sized arguments that calls fn, for hooks that pass Go functions to the
+// original DLL, such as an enumeration callback. Unlike syscall.NewCallback, the address
+// goes back to a pool when release is called, to be handed out again, so a long-lived
+// proxy does not run out of callbacks. Callers must release only once the original can
+// no longer call the address; calls after release return 0. name labels the callback in
+// Callbacks.
+func NewCallback(name string, args int, fn func(args []uintptr) uintptr) (addr uintptr, release func(), err error) {
+	return newCallback(name, callbackKey{args: args}, fn)
+}
+
+// NewCallbackCDecl is NewCallback for cdecl functions, which differ from stdcall ones on 386.
+func NewCallbackCDecl(name string, args int, fn func(args []uintptr) uintptr) (addr uintptr, release func(), err error) {
+	return newCallback(name, callbackKey{args: args, cdecl: true}, fn)
+}
+
+func newCallback(name string, key callbackKey, fn func(args []uintptr) uintptr) (uintptr, func(), error) {
+	if key.args < 0 || key.args > maxCallbackArgs {
+		return 0, nil, fmt.Errorf("callbacks take at most %d arguments, not %d", maxCallbackArgs, key.args)
+	}
+
+	callbacks.mu.Lock()
+	defer callbacks.mu.Unlock()
+	var s *callbackSlot
+	if free := callbacks.free[key]; len(free) > 0 {
+		s = free[len(free)-1]
+		callbacks.free[key] = free[:len(free)-1]
+	} else {
+		if len(callbacks.all) >= maxCallbacks {
+			return 0, nil, ErrCallbackLimit
+		}
+		s = &callbackSlot{key: key}
+		s.addr = s.trampoline()
+		callbacks.all = append(callbacks.all, s)
+	}
+	s.name, s.since = name, time.Now()
+	s.fn.Store(&fn)
+	if callbacks.inUse == nil {
+		callbacks.inUse = make(map[*callbackSlot]bool)
+		callbacks.free = make(map[callbackKey][]*callbackSlot)
+	}
+	callbacks.inUse[s] = true
+
+	var once sync.Once
+	return s.addr, func() {
+		once.Do(func() {
+			callbacks.mu.Lock()
+			defer callbacks.mu.Unlock()
+			s.fn.Store(nil)
+			delete(callbacks.inUse, s)
+			callbacks.free[s.key] = append(callbacks.free[s.key], s)
+		})
+	}, nil
+}
+
+// Callbacks returns the callbacks handed out by NewCallback that are still in use,
+// oldest first, so leaks show up in diagnostics.
+func Callbacks() []CallbackInfo {
+	callbacks.mu.Lock()
+	defer callbacks.mu.Unlock()
+	infos := make([]CallbackInfo, 0, len(callbacks.inUse))
+	for s := range callbacks.inUse {
+		infos = append(infos, CallbackInfo{Name: s.name, Args: s.key.args, CDecl: s.key.cdecl, Address: s.addr, Since: s.since})
+	}
+	slices.SortFunc(infos, func(a, b CallbackInfo) int { return a.Since.Compare(b.Since) })
+	return infos
+}
+
+// trampoline creates the runtime callback for s, which takes exactly s.key.args arguments.
+func (s *callbackSlot) trampoline() uintptr {
+	var fn any
+	switch s.key.args {
+	case 0:
+		fn = func() uintptr { return s.invoke([]uintptr{}) }
+	case 1:
+		fn = func(a0 uintptr) uintptr { return s.invoke([]uintptr{a0}) }
+	case 2:
+		fn = func(a0, a1 uintptr) uintptr { return s.invoke([]uintptr{a0, a1}) }
+	case 3:
+		fn = func(a0, a1, a2 uintptr) uintptr { return s.invoke([]uintptr{a0, a1, a2}) }
+	case 4:
+		fn = func(a0, a1, a2, a3 uintptr) uintptr { return s.invoke([]uintptr{a0, a1, a2, a3}) }
+	case 5:
+		fn = func(a0, a1, a2, a3, a4 uintptr) uintptr { return s.invoke([]uintptr{a0, a1, a2, a3, a4}) }
+	case 6:
+		fn = func(a0, a1, a2, a3, a4, a5 uintptr) uintptr { return s.invoke([]uintptr{a0, a1, a2, a3, a4, a5}) }
+	case 7:
+		fn = func(a0, a1, a2, a3, a4, a5, a6 uintptr) uintptr {
+			return s.invoke([]uintptr{a0, a1, a2, a3, a4, a5, a6})
+		}
+	case 8:
+		fn = func(a0, a1, a2, a3, a4, a5, a6, a7 uintptr) uintptr {
+			return s.invoke([]uintptr{a0, a1, a2, a3, a4, a5, a6, a7})
+		}
+	case 9:
+		fn = func(a0, a1, a2, a3, a4, a5, a6, a7, a8 uintptr) uintptr {
+			return s.invoke([]uintptr{a0, a1, a2, a3, a4, a5, a6, a7, a8})
+		}
+	case 10:
+		fn = func(a0, a1, a2, a3, a4, a5, a6, a7, a8, a9 uintptr) uintptr {
+			return s.invoke([]uintptr{a0, a1, a2, a3, a4, a5, a6, a7, a8, a9})
+		}
+	case 11:
+		fn = func(a0, a1, a2, a3, a4, a5, a6, a7, a8, a9, a10 uintptr) uintptr {
+			return s.invoke([]uintptr{a0, a1, a2, a3, a4, a5, a6, a7, a8, a9, a10})
+		}
+	case 12:
+		fn = func(a0, a1, a2, a3, a4, a5, a6, a7, a8, a9, a10, a11 uintptr) uintptr {
+			return s.invoke([]uintptr{a0, a1, a2, a3, a4, a5, a6, a7, a8, a9, a10, a11})
+		}
+	case 13:
+		fn = func(a0, a1, a2, a3, a4, a5, a6, a7, a8, a9, a10, a11, a12 uintptr) uintptr {
+			return s.invoke([]uintptr{a0, a1, a2, a3, a4, a5, a6, a7, a8, a9, a10, a11, a12})
+		}
+	case 14:
+		fn = func(a0, a1, a2, a3, a4, a5, a6, a7, a8, a9, a10, a11, a12, a13 uintptr) uintptr {
+			return s.invoke([]uintptr{a0, a1, a2, a3, a4, a5, a6, a7, a8, a9, a10, a11, a12, a13})
+		}
+	case 15:
+		fn = func(a0, a1, a2, a3, a4, a5, a6, a7, a8, a9, a10, a11, a12, a13, a14 uintptr) uintptr {
+			return s.invoke([]uintptr{a0, a1, a2, a3, a4, a5, a6, a7, a8, a9, a10, a11, a12, a13, a14})
+		}
+	case 16:
+		fn = func(a0, a1, a2, a3, a4, a5, a6, a7, a8, a9, a10, a11, a12, a13, a14, a15 uintptr) uintptr {
+			return s.invoke([]uintptr{a0, a1, a2, a3, a4, a5, a6, a7, a8, a9, a10, a11, a12, a13, a14, a15})
+		}
+	}
+	if s.key.cdecl {
+		return syscall.NewCallbackCDecl(fn)
+	}
+	return syscall.NewCallback(fn)
+}
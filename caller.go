@@ -0,0 +1,58 @@
+//go:build windows
+
+package proxdll
+
+import (
+	"path/filepath"
+	"sync"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// maxCallerFrames bounds the stack walk that looks for the caller of an export.
+const maxCallerFrames = 64
+
+// captureCaller walks the calling thread's stack and returns the first frame outside the
+// proxy's image, which is where the host called into the export. The walk relies on the
+// unwind data of each frame and is best effort: if it cannot leave the proxy, the zero
+// Caller is returned.
+func captureCaller() Caller {
+	lo, hi := selfImage()
+	if lo == hi {
+		return Caller{}
+	}
+
+	var frames [maxCallerFrames]uintptr
+	n, _, _ := procRtlCaptureStackBackTrace.Call(0, maxCallerFrames, uintptr(unsafe.Pointer(&frames[0])), 0)
+	for _, pc := range frames[:n] {
+		if pc < lo || pc >= hi {
+			return resolveCaller(pc)
+		}
+	}
+	return Caller{}
+}
+
+// moduleNames caches the file names of modules by base address.
+var moduleNames sync.Map
+
+// resolveCaller attributes pc to the module containing it.
+func resolveCaller(pc uintptr) Caller {
+	c := Caller{Address: pc}
+	var module windows.Handle
+	flags := uint32(windows.GET_MODULE_HANDLE_EX_FLAG_FROM_ADDRESS | windows.GET_MODULE_HANDLE_EX_FLAG_UNCHANGED_REFCOUNT)
+	if r, _, _ := procGetModuleHandleExW.Call(uintptr(flags), pc, uintptr(unsafe.Pointer(&module))); r == 0 {
+		return c
+	}
+
+	c.Offset = pc - uintptr(module)
+	if name, ok := moduleNames.Load(module); ok {
+		c.Module = name.(string)
+		return c
+	}
+	if path, err := modulePath(module); err == nil {
+		c.Module = filepath.Base(path)
+		moduleNames.Store(module, c.Module)
+	}
+	return c
+}
@@ -0,0 +1,8 @@
+//go:build !windows
+
+package proxdll
+
+// captureCaller cannot identify callers outside Windows.
+func captureCaller() Caller {
+	return Caller{}
+}
@@ -0,0 +1,69 @@
+// Command proxdll-ctl sends a command to the control channel of a running proxy.
+//
+// Usage:
+//
+//	proxdll-ctl -pid PID command [args...]
+//
+// Run "proxdll-ctl -pid PID help" to list the commands the proxy supports.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+func main() {
+	pid := flag.Int("pid", 0, "process ID of the host running the proxy")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: proxdll-ctl -pid PID command [args...]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if *pid == 0 || flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	ok, err := run(*pid, flag.Args(), os.Stdout)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "proxdll-ctl: %v\n", err)
+		os.Exit(1)
+	}
+	if !ok {
+		os.Exit(1)
+	}
+}
+
+// run sends one command and copies the response to w. It reports whether the proxy
+// answered "ok".
+func run(pid int, args []string, w io.Writer) (bool, error) {
+	// Keep in sync with proxdll.ControlPipeName, which cannot be imported off Windows.
+	name := fmt.Sprintf(`\\.\pipe\proxdll-%d`, pid)
+	pipe, err := os.OpenFile(name, os.O_RDWR, 0)
+	if err != nil {
+		return false, fmt.Errorf("failed to connect to %s: %w", name, err)
+	}
+	defer pipe.Close()
+
+	if _, err := fmt.Fprintln(pipe, strings.Join(args, " ")); err != nil {
+		return false, fmt.Errorf("failed to send command: %w", err)
+	}
+
+	r := bufio.NewReader(pipe)
+	status, err := r.ReadString('\n')
+	if err != nil && status == "" {
+		return false, fmt.Errorf("failed to read response: %w", err)
+	}
+	status = strings.TrimSpace(status)
+	if status != "ok" {
+		fmt.Fprintln(os.Stderr, status)
+	}
+	if _, err := io.Copy(w, r); err != nil {
+		return false, fmt.Errorf("failed to read response: %w", err)
+	}
+	return status == "ok", nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/nilssoncreative/proxdll/pefile"
+)
+
+// builtinFS holds the export lists of commonly proxied system DLLs, named after the DLL.
+//
+//go:embed builtin/*.txt
+var builtinFS embed.FS
+
+// builtinNames returns the DLLs with a built-in export list.
+func builtinNames() []string {
+	entries, _ := fs.ReadDir(builtinFS, "builtin")
+	var names []string
+	for _, e := range entries {
+		names = append(names, strings.TrimSuffix(e.Name(), ".txt"))
+	}
+	return names
+}
+
+// builtinExports returns the built-in export table of the DLL named name, such as
+// "winmm.dll", and the argument counts of the exports for which they are known,
+// keyed by lookup name.
+func builtinExports(name string) (*pefile.ExportTable, map[string]int, error) {
+	data, err := builtinFS.ReadFile(path.Join("builtin", strings.ToLower(name)+".txt"))
+	if err != nil {
+		return nil, nil, fmt.Errorf("no built-in export list for %s, only for %s", name, strings.Join(builtinNames(), ", "))
+	}
+
+	table := &pefile.ExportTable{DLLName: strings.ToLower(name)}
+	arities := make(map[string]int)
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; sc.Scan(); line++ {
+		text := strings.TrimSpace(sc.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Fields(text)
+		if len(fields) != 3 || fields[0] == "-" && fields[1] == "-" {
+			return nil, nil, fmt.Errorf("built-in export list of %s: line %d is malformed", name, line)
+		}
+		var exp pefile.Export
+		if fields[0] != "-" {
+			ordinal, err := strconv.ParseUint(fields[0], 10, 16)
+			if err != nil || ordinal == 0 {
+				return nil, nil, fmt.Errorf("built-in export list of %s: line %d has an invalid ordinal", name, line)
+			}
+			exp.Ordinal = uint16(ordinal)
+		}
+		if fields[1] != "-" {
+			exp.Name = fields[1]
+		}
+		if fields[2] != "-" {
+			n, err := strconv.Atoi(fields[2])
+			if err != nil || n < 0 || n > maxArgs {
+				return nil, nil, fmt.Errorf("built-in export list of %s: line %d has an invalid argument count", name, line)
+			}
+			arities[lookupName(exp)] = n
+		}
+		table.Exports = append(table.Exports, exp)
+	}
+	slices.SortStableFunc(table.Exports, func(a, b pefile.Export) int { return int(a.Ordinal) - int(b.Ordinal) })
+	return table, arities, nil
+}
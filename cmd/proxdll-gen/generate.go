@@ -0,0 +1,454 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/token"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"github.com/nilssoncreative/proxdll/pefile"
+)
+
+// stdcallFile holds the C wrappers giving 32-bit stdcall and fastcall exports their convention.
+const stdcallFile = "stdcall_windows_386.c"
+
+// maxArgs is the largest argument count proxdll.Manager.Call can forward.
+const maxArgs = 15
+
+// reserved lists identifiers used by the generated code that exports cannot shadow.
+var reserved = map[string]bool{
+	"main":         true,
+	"manager":      true,
+	"proxy":        true,
+	"originalPath": true,
+	"defaults":     true,
+	"proxdll":      true,
+	"lifecycle":    true,
+	"embed":        true,
+	"time":         true,
+	"thunkNames":   true,
+
+	"proxdllResolveThunk": true,
+}
+
+// config holds the generator settings.
+type config struct {
+	Target string
+	// Arch is the GOARCH matching the target's machine type, which the proxy is built for.
+	Arch     string
+	OutDir   string
+	Module   string
+	Original string
+	Args     int
+	// Fail is the value stubs return when they recover from a panic.
+	Fail uint64
+	// Forward lists path.Match patterns of exports forwarded by the loader instead of
+	// stubbed; ordinal-only exports are matched as "#N".
+	Forward []string
+	// Hook lists path.Match patterns of the exports to generate stubs for. When set, every
+	// other export is forwarded.
+	Hook []string
+	// Builtin reports whether the exports come from a built-in export list.
+	Builtin bool
+	// Arities holds the argument counts of the exports, by lookup name, for those the
+	// built-in export list gives. Their stubs take exactly these arguments.
+	Arities map[string]int
+	// Thunk lists path.Match patterns of the exports stubbed by assembly thunks, which
+	// forward any signature unchanged but run no hooks.
+	Thunk []string
+}
+
+// forwards reports whether the export looked up as name is forwarded rather than stubbed.
+func (cfg *config) forwards(name string) bool {
+	if matchAny(cfg.Forward, name) {
+		return true
+	}
+	return len(cfg.Hook) > 0 && !matchAny(cfg.Hook, name)
+}
+
+// stub describes one generated export.
+type stub struct {
+	// Name is the Go function and exported symbol name.
+	Name string
+	// Lookup is the name the stub resolves in the original DLL, "#N" for ordinals.
+	Lookup string
+	// Ordinal is the export ordinal of the original, kept by the proxy.
+	Ordinal uint16
+	// NoName reports whether the export is exported by ordinal only.
+	NoName bool
+	// Thunk reports whether the stub is an assembly thunk rather than Go code.
+	Thunk bool
+	// Index is the thunk's slot in the address table, set only for thunks.
+	Index int
+	// Params are the stub's parameters.
+	Params []string
+	// Stdcall reports whether the export is a 32-bit stdcall function, decorated as
+	// _Name@N or listed in a built-in export list, whose arguments the callee pops.
+	Stdcall bool
+	// Fastcall reports whether the export is a 32-bit fastcall function, decorated as
+	// @Name@N, which takes its first two arguments in ECX and EDX. Stdcall is set too,
+	// as the callee pops the rest.
+	Fastcall bool
+	// Mangled reports whether the export is an MSVC-mangled C++ name, such as
+	// ?Name@@YAHH@Z, exported under a synthetic Go name.
+	Mangled bool
+	// Export is the exported name, set only when it differs from Name.
+	Export string
+	// Symbol is the symbol exported as Export, set only when it differs from Name.
+	Symbol string
+}
+
+// stdcallName splits a stdcall-decorated name, such as "_Name@8", or a fastcall-decorated
+// one, such as "@Name@8", into the undecorated name and its argument count.
+func stdcallName(name string) (string, int, bool) {
+	rest := strings.TrimPrefix(name, "@")
+	if rest == name {
+		rest = strings.TrimPrefix(name, "_")
+	}
+	base, size, ok := strings.Cut(rest, "@")
+	if !ok {
+		return "", 0, false
+	}
+	n, err := strconv.Atoi(size)
+	if err != nil || n%4 != 0 || n/4 > maxArgs {
+		return "", 0, false
+	}
+	return base, n / 4, true
+}
+
+// mangledName returns the Go name of the stub for the MSVC-mangled export name: the
+// qualified name it declares, outermost scope first, such as proxdll_cpp_Math_Add for
+// "?Add@Math@@QAEHH@Z". Characters Go identifiers cannot hold become underscores.
+func mangledName(name string) string {
+	qualified, _, _ := strings.Cut(strings.TrimLeft(name, "?"), "@@")
+	parts := strings.Split(qualified, "@")
+	slices.Reverse(parts)
+	ident := []rune("proxdll_cpp_" + strings.Join(parts, "_"))
+	for i, r := range ident {
+		if r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			ident[i] = '_'
+		}
+	}
+	return string(ident)
+}
+
+// forwarder describes one export forwarded to the original by the loader.
+type forwarder struct {
+	// Name is the exported name, or the synthetic stub name for ordinal-only exports.
+	Name string
+	// Target is the export of the original, "#N" for ordinals.
+	Target string
+	// Ordinal is the export ordinal of the original, kept by the proxy.
+	Ordinal uint16
+	// NoName reports whether the export is exported by ordinal only.
+	NoName bool
+	// Data reports whether the export is a variable, which only a forwarder can proxy.
+	Data bool
+}
+
+// project is the data the templates are rendered from.
+type project struct {
+	config
+	Stubs    []stub
+	Thunks   []stub
+	Forwards []forwarder
+	// ForwardModule is the module name forwarders refer to the original by.
+	ForwardModule string
+	Params        []string
+	Warnings      []string
+}
+
+func newProject(table *pefile.ExportTable, cfg config) (*project, error) {
+	p := &project{config: cfg}
+	for i := range cfg.Args {
+		p.Params = append(p.Params, fmt.Sprintf("a%d", i))
+	}
+
+	p.ForwardModule = strings.TrimSuffix(filepath.Base(cfg.Original), filepath.Ext(cfg.Original))
+	if len(cfg.Forward)+len(cfg.Hook) > 0 && filepath.Base(cfg.Original) != cfg.Original {
+		p.Warnings = append(p.Warnings, fmt.Sprintf("forwarded exports load %s by name, not from %s", p.ForwardModule, cfg.Original))
+	}
+
+	seen := make(map[string]bool)
+	var unnamed []pefile.Export
+	for _, exp := range table.Exports {
+		switch {
+		case exp.Data:
+			// A stub cannot stand in for a variable: the host would read its code.
+			if matchAny(cfg.Hook, lookupName(exp)) || matchAny(cfg.Thunk, lookupName(exp)) {
+				p.Warnings = append(p.Warnings, fmt.Sprintf("forwarding export %s: it is data, not a function", lookupName(exp)))
+			}
+			fwd := forwarder{Name: exp.Name, Target: exp.Name, Data: true}
+			if exp.Name == "" {
+				fwd = forwarder{
+					Name:    fmt.Sprintf("Ordinal%d", exp.Ordinal),
+					Target:  fmt.Sprintf("#%d", exp.Ordinal),
+					Ordinal: exp.Ordinal,
+					NoName:  true,
+					Data:    true,
+				}
+			} else if seen[exp.Name] {
+				continue
+			}
+			seen[fwd.Name] = true
+			p.Forwards = append(p.Forwards, fwd)
+		case exp.Name == "" && cfg.forwards(lookupName(exp)):
+			p.Forwards = append(p.Forwards, forwarder{
+				Name:    fmt.Sprintf("Ordinal%d", exp.Ordinal),
+				Target:  fmt.Sprintf("#%d", exp.Ordinal),
+				Ordinal: exp.Ordinal,
+				NoName:  true,
+			})
+		case exp.Name != "" && cfg.forwards(exp.Name):
+			if !seen[exp.Name] {
+				seen[exp.Name] = true
+				p.Forwards = append(p.Forwards, forwarder{Name: exp.Name, Target: exp.Name})
+			}
+		case exp.Name == "":
+			unnamed = append(unnamed, exp)
+		case strings.HasPrefix(exp.Name, "?"):
+			name := mangledName(exp.Name)
+			for i := 2; reserved[name] || seen[name]; i++ {
+				name = fmt.Sprintf("%s_%d", mangledName(exp.Name), i)
+			}
+			seen[name] = true
+			p.Stubs = append(p.Stubs, stub{
+				Name:    name,
+				Lookup:  exp.Name,
+				Params:  p.Params,
+				Mangled: true,
+				Export:  exp.Name,
+				Symbol:  name,
+			})
+		case cfg.Arch == "386" && strings.Contains(exp.Name, "@"):
+			base, n, ok := stdcallName(exp.Name)
+			switch {
+			case !ok || !token.IsIdentifier(base):
+				p.Warnings = append(p.Warnings, fmt.Sprintf("skipping export %q: not a valid Go identifier", exp.Name))
+			case reserved[base] || seen[base]:
+				p.Warnings = append(p.Warnings, fmt.Sprintf("skipping export %q: stub name %s is already taken", exp.Name, base))
+			default:
+				seen[base] = true
+				p.Stubs = append(p.Stubs, stub{
+					Name:     base,
+					Lookup:   exp.Name,
+					Params:   p.Params[:0:0],
+					Stdcall:  true,
+					Fastcall: strings.HasPrefix(exp.Name, "@"),
+					Export:   exp.Name,
+				})
+				for i := range n {
+					last := &p.Stubs[len(p.Stubs)-1]
+					last.Params = append(last.Params, fmt.Sprintf("a%d", i))
+				}
+			}
+		case !token.IsIdentifier(exp.Name):
+			p.Warnings = append(p.Warnings, fmt.Sprintf("skipping export %q: not a valid Go identifier", exp.Name))
+		case reserved[exp.Name]:
+			p.Warnings = append(p.Warnings, fmt.Sprintf("skipping export %q: collides with generated code", exp.Name))
+		case seen[exp.Name]:
+		default:
+			seen[exp.Name] = true
+			p.Stubs = append(p.Stubs, stub{Name: exp.Name, Lookup: exp.Name, Params: p.Params})
+		}
+	}
+
+	// Ordinal-only exports get a synthetic symbol name, which the module-definition
+	// file hides again by exporting it under its ordinal with NONAME.
+	for _, exp := range unnamed {
+		name := fmt.Sprintf("Ordinal%d", exp.Ordinal)
+		if seen[name] {
+			p.Warnings = append(p.Warnings, fmt.Sprintf("skipping export #%d: stub name %s is already taken", exp.Ordinal, name))
+			continue
+		}
+		seen[name] = true
+		p.Stubs = append(p.Stubs, stub{
+			Name:    name,
+			Lookup:  fmt.Sprintf("#%d", exp.Ordinal),
+			Ordinal: exp.Ordinal,
+			NoName:  true,
+			Params:  p.Params,
+		})
+	}
+	for _, pattern := range cfg.Hook {
+		if !slices.ContainsFunc(table.Exports, func(exp pefile.Export) bool { return matchAny([]string{pattern}, lookupName(exp)) }) {
+			p.Warnings = append(p.Warnings, fmt.Sprintf("-hook pattern %q matches no export", pattern))
+		}
+	}
+	if len(p.Stubs) == 0 && len(p.Forwards) == 0 {
+		return nil, fmt.Errorf("%s has no exports that can be proxied", cfg.Target)
+	}
+	sort.Slice(p.Stubs, func(i, j int) bool { return p.Stubs[i].Name < p.Stubs[j].Name })
+	sort.Slice(p.Forwards, func(i, j int) bool { return p.Forwards[i].Name < p.Forwards[j].Name })
+	keepOrdinals(p, table)
+	for i := range p.Stubs {
+		s := &p.Stubs[i]
+		// The DLLs with built-in export lists use the stdcall convention throughout.
+		if n, ok := cfg.Arities[s.Lookup]; ok && !s.Stdcall && !s.Mangled {
+			s.Params = p.Params[:0:0]
+			for i := range n {
+				s.Params = append(s.Params, fmt.Sprintf("a%d", i))
+			}
+			if cfg.Arch == "386" {
+				s.Stdcall = true
+				s.Export = s.Name
+			}
+		}
+		if matchAny(cfg.Thunk, s.Lookup) {
+			s.Thunk = true
+			s.Index = len(p.Thunks)
+		}
+		switch {
+		case s.Stdcall && s.Thunk:
+			s.Symbol = s.Name
+		case s.Fastcall:
+			s.Symbol = fmt.Sprintf("@proxdll_fastcall_%s@%d", s.Name, 4*len(s.Params))
+		case s.Stdcall:
+			s.Symbol = fmt.Sprintf("proxdll_stdcall_%s@%d", s.Name, 4*len(s.Params))
+		}
+		if s.Thunk {
+			p.Thunks = append(p.Thunks, *s)
+		}
+	}
+	if len(p.Thunks) > 0 && thunkTemplates[cfg.Arch] == nil {
+		return nil, fmt.Errorf("-thunk is not supported for %s", cfg.Arch)
+	}
+	if cfg.Arch == "386" && slices.ContainsFunc(p.Stubs, func(s stub) bool { return s.Mangled && !s.Thunk }) {
+		p.Warnings = append(p.Warnings, "C++ exports are stubbed as cdecl; use -thunk for member functions, which are thiscall, and those that are stdcall")
+	}
+	if cfg.Arch == "386" && slices.ContainsFunc(p.Stubs, func(s stub) bool { return !s.Stdcall && !s.Mangled && !s.Thunk }) {
+		p.Warnings = append(p.Warnings, "undecorated 32-bit exports are stubbed as cdecl; use -thunk for those that are stdcall")
+	}
+
+	return p, nil
+}
+
+// keepOrdinals gives the named stubs and forwarders of p the ordinals of the original,
+// as hosts may import them by ordinal. A function exported under several names keeps its
+// ordinal for the first name only, since the proxy exports each name separately.
+func keepOrdinals(p *project, table *pefile.ExportTable) {
+	ordinals := table.NameOrdinals()
+	used := make(map[uint16]bool)
+	for _, s := range p.Stubs {
+		if s.NoName {
+			used[s.Ordinal] = true
+		}
+	}
+	for _, f := range p.Forwards {
+		if f.NoName {
+			used[f.Ordinal] = true
+		}
+	}
+	keep := func(name string, ordinal *uint16) {
+		if ord, ok := ordinals[name]; ok && ord != 0 && !used[ord] {
+			*ordinal = ord
+			used[ord] = true
+		}
+	}
+	for i := range p.Stubs {
+		if !p.Stubs[i].NoName {
+			keep(p.Stubs[i].Lookup, &p.Stubs[i].Ordinal)
+		}
+	}
+	for i := range p.Forwards {
+		if !p.Forwards[i].NoName {
+			keep(p.Forwards[i].Target, &p.Forwards[i].Ordinal)
+		}
+	}
+}
+
+// lookupName returns the name exp is matched and resolved by, "#N" for ordinal-only exports.
+func lookupName(exp pefile.Export) string {
+	if exp.Name == "" {
+		return fmt.Sprintf("#%d", exp.Ordinal)
+	}
+	return exp.Name
+}
+
+// matchAny reports whether name matches one of the path.Match patterns.
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// projectFile is a file of the generated project and the template it is rendered from.
+type projectFile struct {
+	name  string
+	tmpl  *template.Template
+	gofmt bool
+	// keep leaves an existing file alone, for files meant to be edited.
+	keep bool
+}
+
+// write renders every project file into the output directory.
+func (p *project) write() error {
+	if err := os.MkdirAll(p.OutDir, 0o755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	files := []projectFile{
+		{"go.mod", goModTemplate, false, false},
+		{"proxy.go", proxyTemplate, true, false},
+		{"exports.def", defTemplate, false, false},
+		{"README.md", readmeTemplate, false, false},
+		{"defaults.proxdll.json", defaultsTemplate, false, true},
+	}
+	// Architecture-specific files are only written when needed; stale ones are removed.
+	optional := map[string]bool{}
+	for arch := range thunkTemplates {
+		optional["thunks_windows_"+arch+".S"] = false
+	}
+	optional[stdcallFile] = false
+	if len(p.Thunks) > 0 {
+		name := "thunks_windows_" + p.Arch + ".S"
+		files = append(files, projectFile{name, thunkTemplates[p.Arch], false, false})
+		optional[name] = true
+	}
+	if slices.ContainsFunc(p.Stubs, func(s stub) bool { return s.Stdcall && !s.Thunk }) {
+		files = append(files, projectFile{stdcallFile, stdcallTemplate, false, false})
+		optional[stdcallFile] = true
+	}
+	for name, used := range optional {
+		if used {
+			continue
+		}
+		if err := os.Remove(filepath.Join(p.OutDir, name)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove %s: %w", name, err)
+		}
+	}
+	for _, f := range files {
+		path := filepath.Join(p.OutDir, f.name)
+		if _, err := os.Stat(path); f.keep && err == nil {
+			continue
+		}
+		var buf bytes.Buffer
+		if err := f.tmpl.Execute(&buf, p); err != nil {
+			return fmt.Errorf("failed to render %s: %w", f.name, err)
+		}
+		out := buf.Bytes()
+		if f.gofmt {
+			formatted, err := format.Source(out)
+			if err != nil {
+				return fmt.Errorf("failed to format %s: %w", f.name, err)
+			}
+			out = formatted
+		}
+		if err := os.WriteFile(path, out, 0o644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", f.name, err)
+		}
+	}
+	return nil
+}
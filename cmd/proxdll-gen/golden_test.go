@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// goldenFiles lists the generated files compared against testdata/golden. Each case
+// must produce exactly those of them it has a golden file for.
+var goldenFiles = []string{
+	"exports.def",
+	"proxy.go",
+	stdcallFile,
+	"thunks_windows_386.S",
+	"thunks_windows_amd64.S",
+	"thunks_windows_arm64.S",
+}
+
+func TestGolden(t *testing.T) {
+	for _, arch := range []string{"386", "amd64", "arm64"} {
+		for _, thunk := range []bool{false, true} {
+			name := arch
+			cfg := config{Builtin: true, Arch: arch, Args: 8}
+			if thunk {
+				name += "-thunk"
+				cfg.Thunk = []string{"GetFileVersionInfo*"}
+			}
+			t.Run(name, func(t *testing.T) {
+				cfg.OutDir = t.TempDir()
+				if err := run("version.dll", cfg); err != nil {
+					t.Fatal(err)
+				}
+				golden := filepath.Join("testdata", "golden", name)
+				for _, file := range goldenFiles {
+					checkGolden(t, filepath.Join(cfg.OutDir, file), filepath.Join(golden, file))
+				}
+			})
+		}
+	}
+}
+
+// checkGolden compares the generated file got with the golden file want, or with
+// -update makes it the golden file. A missing file must be missing from both.
+func checkGolden(t *testing.T, got, want string) {
+	t.Helper()
+	gotData, gotErr := os.ReadFile(got)
+	if gotErr != nil && !errors.Is(gotErr, fs.ErrNotExist) {
+		t.Fatal(gotErr)
+	}
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(want), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if gotErr != nil {
+			if err := os.Remove(want); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				t.Fatal(err)
+			}
+			return
+		}
+		if err := os.WriteFile(want, gotData, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	wantData, wantErr := os.ReadFile(want)
+	if wantErr != nil && !errors.Is(wantErr, fs.ErrNotExist) {
+		t.Fatal(wantErr)
+	}
+	switch {
+	case gotErr != nil && wantErr != nil:
+	case gotErr != nil:
+		t.Errorf("%s was not generated", filepath.Base(got))
+	case wantErr != nil:
+		t.Errorf("%s was generated but has no golden file; run go test -update", filepath.Base(got))
+	case !bytes.Equal(gotData, wantData):
+		t.Errorf("%s differs from %s; run go test -update if the change is intended", filepath.Base(got), want)
+	}
+}
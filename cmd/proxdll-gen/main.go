@@ -0,0 +1,151 @@
+// Command proxdll-gen generates a Go proxy DLL project from the export table of a target DLL.
+//
+// Usage:
+//
+//	proxdll-gen [flags] path\to\target.dll
+//	proxdll-gen -builtin [-arch 386] [flags] target.dll
+//	proxdll-gen resources [-o file.syso] path\to\target.dll
+//
+// The generated project contains a cgo //export stub for every named export, each forwarding
+// to the original DLL through a proxdll.Manager, and a README with build instructions.
+// With -hook, only the listed exports get stubs and the loader forwards all others to the
+// original, which suits large DLLs of which only a few functions are of interest.
+//
+// With -builtin, the exports are taken from a list built into the generator instead of a
+// copy of the target, for version.dll, winmm.dll, dinput8.dll, xinput1_3.dll, d3d9.dll and
+// dsound.dll. These lists also give the argument count of each export, so stubs take
+// exactly those arguments.
+//
+// The resources subcommand copies the version resource, manifest and icons of the target
+// into a .syso object. Placed in the proxy project, it is linked into the proxy, so installers
+// and version checks reading these resources see the same values as for the original.
+package main
+
+import (
+	"debug/pe"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/nilssoncreative/proxdll/pefile"
+)
+
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "resources" {
+		resourcesMain(os.Args[2:])
+		return
+	}
+
+	var cfg config
+	flag.StringVar(&cfg.OutDir, "o", "", "output directory (default: <name>-proxy)")
+	flag.StringVar(&cfg.Module, "module", "", "Go module path of the generated project (default: <name>-proxy)")
+	flag.StringVar(&cfg.Original, "original", "", "path the proxy loads the original DLL from, relative to the proxy (default: <name>_orig.dll)")
+	flag.IntVar(&cfg.Args, "args", 8, "number of uintptr arguments each stub accepts and forwards")
+	flag.BoolVar(&cfg.Builtin, "builtin", false, "generate from the built-in export list of target, a DLL name such as winmm.dll, instead of reading the DLL")
+	flag.StringVar(&cfg.Arch, "arch", "amd64", "GOARCH to generate the proxy for with -builtin")
+	flag.Uint64Var(&cfg.Fail, "fail", 0, "value stubs return after recovering from a panic, such as 0x80004005 for E_FAIL")
+	flag.Func("forward", "comma-separated exports, or path.Match patterns, for the loader to forward to the original instead of generating stubs; \"#N\" matches ordinal-only exports", func(s string) error {
+		cfg.Forward = append(cfg.Forward, strings.Split(s, ",")...)
+		return nil
+	})
+	flag.Func("hook", "comma-separated exports, or path.Match patterns, to generate stubs for; every other export is forwarded as with -forward", func(s string) error {
+		cfg.Hook = append(cfg.Hook, strings.Split(s, ",")...)
+		return nil
+	})
+	flag.Func("thunk", "comma-separated exports, or path.Match patterns, to stub with assembly thunks that forward any signature unchanged, without hooks", func(s string) error {
+		cfg.Thunk = append(cfg.Thunk, strings.Split(s, ",")...)
+		return nil
+	})
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: proxdll-gen [flags] target.dll\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err := run(flag.Arg(0), cfg); err != nil {
+		fmt.Fprintf(os.Stderr, "proxdll-gen: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(target string, cfg config) error {
+	var table *pefile.ExportTable
+	var err error
+	if cfg.Builtin {
+		if table, cfg.Arities, err = builtinExports(filepath.Base(target)); err != nil {
+			return err
+		}
+		if !slices.ContainsFunc(slices.Collect(maps.Values(objectArchs)), func(a objectArch) bool { return a.GOARCH == cfg.Arch }) {
+			return fmt.Errorf("unsupported -arch %s", cfg.Arch)
+		}
+	} else {
+		if table, err = readTarget(target, &cfg); err != nil {
+			return err
+		}
+	}
+
+	cfg.Target = filepath.Base(target)
+	name := strings.TrimSuffix(cfg.Target, filepath.Ext(cfg.Target))
+	if cfg.OutDir == "" {
+		cfg.OutDir = name + "-proxy"
+	}
+	if cfg.Module == "" {
+		cfg.Module = name + "-proxy"
+	}
+	if cfg.Original == "" {
+		cfg.Original = name + "_orig.dll"
+	}
+	if cfg.Args < 0 || cfg.Args > maxArgs {
+		return fmt.Errorf("-args must be between 0 and %d", maxArgs)
+	}
+
+	proj, err := newProject(table, cfg)
+	if err != nil {
+		return err
+	}
+	for _, w := range proj.Warnings {
+		fmt.Fprintf(os.Stderr, "proxdll-gen: warning: %s\n", w)
+	}
+	if err := proj.write(); err != nil {
+		return err
+	}
+
+	if len(proj.Forwards) > 0 {
+		fmt.Printf("Generated %d stubs and %d forwarders for %s in %s\n", len(proj.Stubs), len(proj.Forwards), cfg.Target, cfg.OutDir)
+		return nil
+	}
+	fmt.Printf("Generated %d stubs for %s in %s\n", len(proj.Stubs), cfg.Target, cfg.OutDir)
+	return nil
+}
+
+// readTarget reads the export table of the DLL at target and sets cfg.Arch to match it.
+func readTarget(target string, cfg *config) (*pefile.ExportTable, error) {
+	table, err := pefile.OpenExports(target)
+	if errors.Is(err, fs.ErrNotExist) && slices.Contains(builtinNames(), strings.ToLower(filepath.Base(target))) {
+		return nil, fmt.Errorf("failed to read exports of %s: %w; use -builtin to generate from the built-in export list", target, err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read exports of %s: %w", target, err)
+	}
+	f, err := pe.Open(target)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", target, err)
+	}
+	arch, ok := objectArchs[f.Machine]
+	f.Close()
+	if !ok {
+		return nil, fmt.Errorf("%s is built for unsupported machine %#x", target, f.Machine)
+	}
+	cfg.Arch = arch.GOARCH
+	return table, nil
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"bytes"
+	"debug/pe"
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/nilssoncreative/proxdll/pefile"
+)
+
+// clonedTypes are the resource types the resources subcommand copies to the proxy.
+var clonedTypes = map[uint16]bool{
+	pefile.ResourceIcon:      true,
+	pefile.ResourceGroupIcon: true,
+	pefile.ResourceVersion:   true,
+	pefile.ResourceManifest:  true,
+}
+
+// objectArch describes how to write a COFF object for a machine type.
+type objectArch struct {
+	GOARCH string
+	// Reloc is the relocation type storing a section-relative RVA (ADDR32NB).
+	Reloc uint16
+}
+
+var objectArchs = map[uint16]objectArch{
+	pe.IMAGE_FILE_MACHINE_I386:  {"386", 0x0007},
+	pe.IMAGE_FILE_MACHINE_AMD64: {"amd64", 0x0003},
+	pe.IMAGE_FILE_MACHINE_ARMNT: {"arm", 0x0002},
+	pe.IMAGE_FILE_MACHINE_ARM64: {"arm64", 0x0002},
+}
+
+// resourcesMain implements "proxdll-gen resources", which copies the version resource,
+// manifest and icons of a DLL into a .syso object the Go linker adds to the proxy.
+func resourcesMain(args []string) {
+	flags := flag.NewFlagSet("resources", flag.ExitOnError)
+	out := flags.String("o", "", "output file (default: rsrc_windows_<arch>.syso)")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: proxdll-gen resources [flags] target.dll\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	if err := runResources(flags.Arg(0), *out); err != nil {
+		fmt.Fprintf(os.Stderr, "proxdll-gen: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func runResources(target, out string) error {
+	f, err := pe.Open(target)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", target, err)
+	}
+	machine := f.Machine
+	f.Close()
+	arch, ok := objectArchs[machine]
+	if !ok {
+		return fmt.Errorf("%s is built for unsupported machine %#x", target, machine)
+	}
+
+	all, err := pefile.OpenResources(target)
+	if err != nil {
+		return fmt.Errorf("failed to read resources of %s: %w", target, err)
+	}
+	var resources []pefile.Resource
+	for _, res := range all {
+		if res.Type.Name == "" && clonedTypes[res.Type.ID] {
+			resources = append(resources, res)
+		}
+	}
+	if len(resources) == 0 {
+		return fmt.Errorf("%s has no version, manifest or icon resources", target)
+	}
+
+	if out == "" {
+		out = "rsrc_windows_" + arch.GOARCH + ".syso"
+	}
+	if err := os.WriteFile(out, resourceObject(machine, arch.Reloc, resources), 0o644); err != nil {
+		return err
+	}
+	fmt.Printf("Wrote %d resources from %s to %s\n", len(resources), target, out)
+	return nil
+}
+
+// resourceNode is a directory, or for leaves a language entry, of a resource tree.
+type resourceNode struct {
+	id       pefile.ResourceID
+	children []*resourceNode
+	res      *pefile.Resource
+	off      uint32
+}
+
+// child returns the child of n with the given id, adding it if needed.
+func (n *resourceNode) child(id pefile.ResourceID) *resourceNode {
+	for _, c := range n.children {
+		if c.id == id {
+			return c
+		}
+	}
+	c := &resourceNode{id: id}
+	n.children = append(n.children, c)
+	return c
+}
+
+// sort orders the children as the resource directory requires: named entries first,
+// by case-insensitive name, then numeric entries by ID.
+func (n *resourceNode) sort() {
+	slices.SortFunc(n.children, func(a, b *resourceNode) int {
+		switch {
+		case a.id.Name != "" && b.id.Name != "":
+			return strings.Compare(strings.ToUpper(a.id.Name), strings.ToUpper(b.id.Name))
+		case a.id.Name != "":
+			return -1
+		case b.id.Name != "":
+			return 1
+		}
+		return int(a.id.ID) - int(b.id.ID)
+	})
+	for _, c := range n.children {
+		c.sort()
+	}
+}
+
+// resourceObject returns a COFF object holding resources in a .rsrc section.
+func resourceObject(machine, reloc uint16, resources []pefile.Resource) []byte {
+	root := &resourceNode{}
+	for i := range resources {
+		res := &resources[i]
+		root.child(res.Type).child(res.Name).child(pefile.ResourceID{ID: res.Lang}).res = res
+	}
+	root.sort()
+
+	// Directories come first, breadth first, then data entries, names and data.
+	dirs := []*resourceNode{root}
+	var leaves []*resourceNode
+	for i := 0; i < len(dirs); i++ {
+		for _, c := range dirs[i].children {
+			if c.res != nil {
+				leaves = append(leaves, c)
+			} else {
+				dirs = append(dirs, c)
+			}
+		}
+	}
+	var off uint32
+	for _, d := range dirs {
+		d.off = off
+		off += 16 + 8*uint32(len(d.children))
+	}
+	for _, l := range leaves {
+		l.off = off
+		off += 16
+	}
+	names := make(map[string]uint32)
+	for _, d := range dirs[1:] {
+		if d.id.Name != "" {
+			if _, ok := names[d.id.Name]; !ok {
+				names[d.id.Name] = off
+				off += 2 + 2*uint32(len(utf16.Encode([]rune(d.id.Name))))
+			}
+		}
+	}
+	dataOffs := make([]uint32, len(leaves))
+	for i, l := range leaves {
+		off = align8(off)
+		dataOffs[i] = off
+		off += uint32(len(l.res.Data))
+	}
+	section := make([]byte, align8(off))
+
+	le := binary.LittleEndian
+	for _, d := range dirs {
+		var named uint16
+		for _, c := range d.children {
+			if c.id.Name != "" {
+				named++
+			}
+		}
+		le.PutUint16(section[d.off+12:], named)
+		le.PutUint16(section[d.off+14:], uint16(len(d.children))-named)
+		for i, c := range d.children {
+			entry := d.off + 16 + 8*uint32(i)
+			if c.id.Name != "" {
+				le.PutUint32(section[entry:], names[c.id.Name]|1<<31)
+			} else {
+				le.PutUint32(section[entry:], uint32(c.id.ID))
+			}
+			if c.res != nil {
+				le.PutUint32(section[entry+4:], c.off)
+			} else {
+				le.PutUint32(section[entry+4:], c.off|1<<31)
+			}
+		}
+	}
+	for name, at := range names {
+		units := utf16.Encode([]rune(name))
+		le.PutUint16(section[at:], uint16(len(units)))
+		for i, u := range units {
+			le.PutUint16(section[at+2+2*uint32(i):], u)
+		}
+	}
+	for i, l := range leaves {
+		le.PutUint32(section[l.off:], dataOffs[i])
+		le.PutUint32(section[l.off+4:], uint32(len(l.res.Data)))
+		le.PutUint32(section[l.off+8:], l.res.CodePage)
+		copy(section[dataOffs[i]:], l.res.Data)
+	}
+
+	// The file header, one section header, the section, its relocations, which make
+	// each data entry's offset an RVA once linked, and a symbol table with the section symbol.
+	const headers = 20 + 40
+	relocs := headers + uint32(len(section))
+	symbols := relocs + 10*uint32(len(leaves))
+
+	var b bytes.Buffer
+	binary.Write(&b, le, pe.FileHeader{
+		Machine:              machine,
+		NumberOfSections:     1,
+		PointerToSymbolTable: symbols,
+		NumberOfSymbols:      1,
+	})
+	sh := pe.SectionHeader32{
+		SizeOfRawData:        uint32(len(section)),
+		PointerToRawData:     headers,
+		PointerToRelocations: relocs,
+		NumberOfRelocations:  uint16(len(leaves)),
+		Characteristics:      pe.IMAGE_SCN_CNT_INITIALIZED_DATA | pe.IMAGE_SCN_MEM_READ,
+	}
+	copy(sh.Name[:], ".rsrc")
+	binary.Write(&b, le, sh)
+	b.Write(section)
+	for _, l := range leaves {
+		binary.Write(&b, le, pe.Reloc{VirtualAddress: l.off, SymbolTableIndex: 0, Type: reloc})
+	}
+	sym := pe.COFFSymbol{SectionNumber: 1, StorageClass: 3} // IMAGE_SYM_CLASS_STATIC
+	copy(sym.Name[:], ".rsrc")
+	binary.Write(&b, le, sym)
+	// An empty string table holds only its own size.
+	binary.Write(&b, le, uint32(4))
+	return b.Bytes()
+}
+
+func align8(n uint32) uint32 {
+	return (n + 7) &^ 7
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"debug/pe"
+	"encoding/binary"
+	"reflect"
+	"testing"
+
+	"github.com/nilssoncreative/proxdll/pefile"
+)
+
+// linkResources does what the linker does with a resource object: it places the .rsrc
+// section of obj at rva, applies its relocations and returns a PE image holding it as
+// the resource directory.
+func linkResources(t *testing.T, obj []byte, rva uint32) []byte {
+	t.Helper()
+	f, err := pe.NewFile(bytes.NewReader(obj))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := f.Section(".rsrc")
+	if s == nil {
+		t.Fatal("object has no .rsrc section")
+	}
+	section, err := s.Data()
+	if err != nil {
+		t.Fatal(err)
+	}
+	le := binary.LittleEndian
+	for _, r := range s.Relocs {
+		le.PutUint32(section[r.VirtualAddress:], le.Uint32(section[r.VirtualAddress:])+rva)
+	}
+
+	const (
+		lfanew    = 0x40
+		fileAlign = 0x200
+	)
+	var dirs [16]pe.DataDirectory
+	dirs[pe.IMAGE_DIRECTORY_ENTRY_RESOURCE] = pe.DataDirectory{VirtualAddress: rva, Size: uint32(len(section))}
+	var b bytes.Buffer
+	dos := make([]byte, lfanew)
+	copy(dos, "MZ")
+	le.PutUint32(dos[0x3c:], lfanew)
+	b.Write(dos)
+	b.WriteString("PE\x00\x00")
+	binary.Write(&b, le, pe.FileHeader{
+		Machine:              f.Machine,
+		NumberOfSections:     1,
+		SizeOfOptionalHeader: uint16(binary.Size(pe.OptionalHeader64{})),
+		Characteristics:      pe.IMAGE_FILE_EXECUTABLE_IMAGE | pe.IMAGE_FILE_DLL,
+	})
+	binary.Write(&b, le, pe.OptionalHeader64{Magic: 0x20b, SectionAlignment: 0x1000, FileAlignment: fileAlign, NumberOfRvaAndSizes: 16, DataDirectory: dirs})
+	sh := pe.SectionHeader32{
+		VirtualSize:      uint32(len(section)),
+		VirtualAddress:   rva,
+		SizeOfRawData:    uint32(len(section)+fileAlign-1) &^ (fileAlign - 1),
+		PointerToRawData: uint32(b.Len()+40+fileAlign-1) &^ (fileAlign - 1),
+		Characteristics:  s.Characteristics,
+	}
+	copy(sh.Name[:], ".rsrc")
+	binary.Write(&b, le, sh)
+	b.Write(make([]byte, int(sh.PointerToRawData)-b.Len()))
+	b.Write(section)
+	b.Write(make([]byte, int(sh.PointerToRawData+sh.SizeOfRawData)-b.Len()))
+	return b.Bytes()
+}
+
+func TestResourceObjectRoundTrip(t *testing.T) {
+	id := func(n uint16) pefile.ResourceID { return pefile.ResourceID{ID: n} }
+	// Given out of order, as Resources from several sources may be.
+	resources := []pefile.Resource{
+		{Type: id(pefile.ResourceManifest), Name: id(2), Lang: 0, Data: []byte("<assembly/>")},
+		{Type: id(pefile.ResourceVersion), Name: id(1), Lang: 0x409, Data: []byte("version en")},
+		{Type: id(pefile.ResourceVersion), Name: id(1), Lang: 0x407, Data: []byte("version de")},
+		{Type: id(pefile.ResourceIcon), Name: id(1), Lang: 0x409, CodePage: 1252, Data: []byte{1, 2, 3}},
+		{Type: pefile.ResourceID{Name: "custom"}, Name: pefile.ResourceID{Name: "Beta"}, Lang: 0x409, Data: []byte("b")},
+		{Type: pefile.ResourceID{Name: "custom"}, Name: pefile.ResourceID{Name: "alpha"}, Lang: 0x409, Data: []byte("a")},
+	}
+	want := []pefile.Resource{resources[5], resources[4], resources[3], resources[2], resources[1], resources[0]}
+
+	for machine, arch := range objectArchs {
+		t.Run(arch.GOARCH, func(t *testing.T) {
+			obj := resourceObject(machine, arch.Reloc, resources)
+			got, err := pefile.ReadResources(bytes.NewReader(linkResources(t, obj, 0x5000)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("resources = %+v\nwant %+v", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,411 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+var funcs = template.FuncMap{
+	"join":  strings.Join,
+	"stem":  func(name string) string { return strings.TrimSuffix(name, filepath.Ext(name)) },
+	"mul":   func(a, b int) int { return a * b },
+	"low32": func(v uint64) uint64 { return v & 0xFFFFFFFF },
+	"cparams": func(params []string) string {
+		if len(params) == 0 {
+			return "void"
+		}
+		return "uintptr_t " + strings.Join(params, ", uintptr_t ")
+	},
+}
+
+var goModTemplate = template.Must(template.New("go.mod").Parse(`module {{.Module}}
+
+go 1.25
+`))
+
+var proxyTemplate = template.Must(template.New("proxy.go").Funcs(funcs).Parse(`// Code generated by proxdll-gen from {{.Target}}. DO NOT EDIT.
+
+package main
+
+// #cgo LDFLAGS: ${SRCDIR}/exports.def
+import "C"
+
+import (
+	"embed"
+	"time"
+
+	"github.com/nilssoncreative/proxdll"
+	"github.com/nilssoncreative/proxdll/lifecycle"
+)
+
+// defaults is the config used when no config file, registry key or environment override exists.
+//
+//go:embed defaults.proxdll.json
+var defaults embed.FS
+
+// originalPath is where the proxy loads the original {{.Target}} from,
+// relative to the directory containing the proxy.
+const originalPath = {{printf "%q" .Original}}
+
+// proxy loads the original DLL on the first forwarded call, outside the loader lock.
+var proxy = proxdll.NewInitOnce(func() (*proxdll.Manager, error) {
+	m, err := proxdll.New(originalPath,
+		proxdll.WithResolver(proxdll.NextToSelf(nil)),
+		proxdll.WithAutoConfig(),
+		proxdll.WithConfigReload(),
+		proxdll.WithEnvOverrides(),
+		proxdll.WithDefaultConfig(defaults, "defaults.proxdll.json"),
+		proxdll.WithFlushInterval(time.Second),
+	)
+	if err != nil {
+		return nil, err
+	}
+	lifecycle.OnThreadAttach(m.NotifyThreadAttach)
+	lifecycle.OnThreadDetach(m.NotifyThreadDetach)
+	lifecycle.OnProcessDetach(func() { m.ShutdownAtDetach() })
+	return m, nil
+})
+
+// manager returns the proxy's Manager, panicking if it could not be created.
+func manager() *proxdll.Manager {
+	return proxy.MustManager()
+}
+{{- if .Thunks}}
+
+// thunkNames lists the exports forwarded by the assembly thunks in thunks_windows_{{.Arch}}.S,
+// by thunk index.
+var thunkNames = [...]string{
+{{- range .Thunks}}
+	{{printf "%q" .Lookup}},
+{{- end}}
+}
+
+// proxdllResolveThunk returns the address of the original export on a thunk's first call,
+// or zero to make the thunk return the failure value.
+//
+//export proxdllResolveThunk
+func proxdllResolveThunk(index int32) (addr uintptr) {
+	defer proxy.Recover(thunkNames[index], &addr, 0)
+	proc, err := manager().GetOriginalFunc(thunkNames[index])
+	if err != nil {
+		return 0
+	}
+	return proc.Addr()
+}
+{{- end}}
+{{range .Stubs}}{{if not .Thunk}}
+//export {{.Name}}
+func {{.Name}}({{join .Params ", "}}{{if .Params}} uintptr{{end}}) (r1 uintptr) {
+	defer proxy.Recover({{printf "%q" .Lookup}}, &r1, {{printf "%#x" $.Fail}})
+	r1, _, _ = manager().Call({{printf "%q" .Lookup}}{{range .Params}}, {{.}}{{end}})
+	return r1
+}
+{{end}}{{end}}
+func main() {}
+`))
+
+var defTemplate = template.Must(template.New("exports.def").Parse(`; Code generated by proxdll-gen from {{.Target}}. DO NOT EDIT.
+EXPORTS
+{{- range .Stubs}}
+	{{if .Symbol}}{{.Export}}={{.Symbol}}{{else}}{{.Name}}{{end}}{{if .Ordinal}} @{{.Ordinal}}{{end}}{{if .NoName}} NONAME{{end}}
+{{- end}}
+{{- range .Forwards}}
+	{{.Name}}={{$.ForwardModule}}.{{.Target}}{{if .Ordinal}} @{{.Ordinal}}{{end}}{{if .NoName}} NONAME{{end}}{{if .Data}} DATA{{end}}
+{{- end}}
+`))
+
+// thunkTemplates holds the thunk assembly for each architecture -thunk supports.
+var thunkTemplates = map[string]*template.Template{
+	"386":   thunk386Template,
+	"amd64": thunkAMD64Template,
+	"arm64": thunkARM64Template,
+}
+
+var thunkAMD64Template = template.Must(template.New("thunks_amd64.S").Funcs(funcs).Parse(`// Code generated by proxdll-gen from {{.Target}}. DO NOT EDIT.
+
+// Each thunk jumps to the original export with every register and the stack as the host
+// left them, so arguments of any type and number reach the original unchanged. The first
+// call resolves the original through proxdllResolveThunk, preserving the argument
+// registers RCX, RDX, R8, R9 and XMM0-XMM3 around it.
+
+	.text
+{{- range .Thunks}}
+
+	.globl	{{.Name}}
+	.p2align 4
+{{.Name}}:
+	movq	proxdll_thunk_addrs+{{mul .Index 8}}(%rip), %rax
+	testq	%rax, %rax
+	jz	1f
+	jmp	*%rax
+1:	movl	${{.Index}}, %eax
+	jmp	proxdll_thunk_resolve
+{{- end}}
+
+// proxdll_thunk_resolve resolves the thunk with index EAX, caches the address and jumps to it.
+	.p2align 4
+proxdll_thunk_resolve:
+	pushq	%rcx
+	pushq	%rdx
+	pushq	%r8
+	pushq	%r9
+	// 32 bytes of shadow space, 64 for XMM0-XMM3, 8 for the index: RSP is 16-byte aligned.
+	subq	$104, %rsp
+	movdqu	%xmm0, 32(%rsp)
+	movdqu	%xmm1, 48(%rsp)
+	movdqu	%xmm2, 64(%rsp)
+	movdqu	%xmm3, 80(%rsp)
+	movl	%eax, 96(%rsp)
+	movl	%eax, %ecx
+	call	proxdllResolveThunk
+	testq	%rax, %rax
+	jz	1f
+	leaq	proxdll_thunk_addrs(%rip), %r10
+	movl	96(%rsp), %r11d
+	movq	%rax, (%r10,%r11,8)
+1:	movdqu	32(%rsp), %xmm0
+	movdqu	48(%rsp), %xmm1
+	movdqu	64(%rsp), %xmm2
+	movdqu	80(%rsp), %xmm3
+	addq	$104, %rsp
+	popq	%r9
+	popq	%r8
+	popq	%rdx
+	popq	%rcx
+	testq	%rax, %rax
+	jz	proxdll_thunk_fail
+	jmp	*%rax
+
+// proxdll_thunk_fail returns the failure value for an export that cannot be resolved.
+proxdll_thunk_fail:
+	movabsq	${{printf "%#x" .Fail}}, %rax
+	ret
+
+	.data
+	.p2align 3
+proxdll_thunk_addrs:
+	.zero	{{mul (len .Thunks) 8}}
+`))
+
+var thunk386Template = template.Must(template.New("thunks_386.S").Funcs(funcs).Parse(`// Code generated by proxdll-gen from {{.Target}}. DO NOT EDIT.
+
+// Each thunk jumps to the original export with every register and the stack as the host
+// left them, so arguments reach the original unchanged whether it is stdcall, cdecl,
+// fastcall or thiscall. The first call resolves the original through proxdllResolveThunk,
+// preserving ECX and EDX, which carry arguments in fastcall and thiscall, around it.
+
+	.text
+{{- range .Thunks}}
+
+	.globl	_{{.Name}}
+	.p2align 4
+_{{.Name}}:
+	movl	_proxdll_thunk_addrs+{{mul .Index 4}}, %eax
+	testl	%eax, %eax
+	jz	1f
+	jmp	*%eax
+1:	movl	${{.Index}}, %eax
+	jmp	proxdll_thunk_resolve
+{{- end}}
+
+// proxdll_thunk_resolve resolves the thunk with index EAX, caches the address and jumps to it.
+	.p2align 4
+proxdll_thunk_resolve:
+	pushl	%ecx
+	pushl	%edx
+	pushl	%eax
+	call	_proxdllResolveThunk
+	popl	%ecx
+	testl	%eax, %eax
+	jz	1f
+	movl	%eax, _proxdll_thunk_addrs(,%ecx,4)
+1:	popl	%edx
+	popl	%ecx
+	testl	%eax, %eax
+	jz	proxdll_thunk_fail
+	jmp	*%eax
+
+// proxdll_thunk_fail returns the failure value for an export that cannot be resolved.
+// It leaves the arguments on the stack, which is only correct for cdecl exports.
+proxdll_thunk_fail:
+	movl	${{printf "%#x" (low32 .Fail)}}, %eax
+	ret
+
+	.data
+	.p2align 2
+_proxdll_thunk_addrs:
+	.zero	{{mul (len .Thunks) 4}}
+`))
+
+var thunkARM64Template = template.Must(template.New("thunks_arm64.S").Funcs(funcs).Parse(`// Code generated by proxdll-gen from {{.Target}}. DO NOT EDIT.
+
+// Each thunk branches to the original export with every register and the stack as the
+// host left them, so arguments of any type and number reach the original unchanged. The
+// first call resolves the original through proxdllResolveThunk, preserving the argument
+// registers X0-X8 and Q0-Q7 around it. Thunks only use X9, X16 and X17, which hold no
+// arguments.
+
+	.text
+{{- range .Thunks}}
+
+	.globl	{{.Name}}
+	.p2align 2
+{{.Name}}:
+	mov	x9, #{{.Index}}
+	adrp	x16, proxdll_thunk_addrs
+	add	x16, x16, :lo12:proxdll_thunk_addrs
+	ldr	x17, [x16, x9, lsl #3]
+	cbz	x17, proxdll_thunk_resolve
+	br	x17
+{{- end}}
+
+// proxdll_thunk_resolve resolves the thunk with index X9, caches the address and branches to it.
+	.p2align 2
+proxdll_thunk_resolve:
+	stp	x29, x30, [sp, #-224]!
+	mov	x29, sp
+	stp	x0, x1, [sp, #16]
+	stp	x2, x3, [sp, #32]
+	stp	x4, x5, [sp, #48]
+	stp	x6, x7, [sp, #64]
+	stp	x8, x9, [sp, #80]
+	stp	q0, q1, [sp, #96]
+	stp	q2, q3, [sp, #128]
+	stp	q4, q5, [sp, #160]
+	stp	q6, q7, [sp, #192]
+	mov	w0, w9
+	bl	proxdllResolveThunk
+	mov	x17, x0
+	ldp	x8, x9, [sp, #80]
+	cbz	x17, 1f
+	adrp	x16, proxdll_thunk_addrs
+	add	x16, x16, :lo12:proxdll_thunk_addrs
+	str	x17, [x16, x9, lsl #3]
+1:	ldp	q6, q7, [sp, #192]
+	ldp	q4, q5, [sp, #160]
+	ldp	q2, q3, [sp, #128]
+	ldp	q0, q1, [sp, #96]
+	ldp	x6, x7, [sp, #64]
+	ldp	x4, x5, [sp, #48]
+	ldp	x2, x3, [sp, #32]
+	ldp	x0, x1, [sp, #16]
+	ldp	x29, x30, [sp], #224
+	cbz	x17, proxdll_thunk_fail
+	br	x17
+
+// proxdll_thunk_fail returns the failure value for an export that cannot be resolved.
+proxdll_thunk_fail:
+	ldr	x0, 2f
+	ret
+	.p2align 3
+2:	.quad	{{printf "%#x" .Fail}}
+
+	.data
+	.p2align 3
+proxdll_thunk_addrs:
+	.zero	{{mul (len .Thunks) 8}}
+`))
+
+var stdcallTemplate = template.Must(template.New("stdcall.c").Funcs(funcs).Parse(`// Code generated by proxdll-gen from {{.Target}}. DO NOT EDIT.
+
+// The Go stubs have the cdecl convention of cgo exports. These wrappers give the exports
+// decorated as stdcall or fastcall that convention, so they take and pop their arguments
+// as callers expect.
+
+#include <stdint.h>
+{{range .Stubs}}{{if and .Stdcall (not .Thunk)}}
+extern uintptr_t {{.Name}}({{cparams .Params}});
+
+uintptr_t {{if .Fastcall}}__fastcall proxdll_fastcall_{{else}}__stdcall proxdll_stdcall_{{end}}{{.Name}}({{cparams .Params}}) {
+	return {{.Name}}({{join .Params ", "}});
+}
+{{end}}{{end}}`))
+
+var defaultsTemplate = template.Must(template.New("defaults.proxdll.json").Parse(`{
+  "trace": ["*"],
+  "sinks": []
+}
+`))
+
+var readmeTemplate = template.Must(template.New("README.md").Funcs(funcs).Parse("# {{.Module}}\n" + `
+Proxy for ` + "`{{.Target}}`" + ` generated by proxdll-gen with {{len .Stubs}} forwarding stubs.
+{{- if .Forwards}} Another {{len .Forwards}} exports
+are forwarded to ` + "`{{.ForwardModule}}`" + ` by the Windows loader, without running any Go code.
+{{- end}}
+{{- if .Builtin}}
+
+The exports were taken from the export list built into proxdll-gen rather than a copy of
+` + "`{{.Target}}`" + `. Regenerate from the original to cover exports of other Windows versions.
+{{- end}}
+
+## Building
+
+The proxy must be built as a C shared library with cgo enabled and a MinGW-w64 toolchain on PATH:
+
+` + "```" + `
+go mod tidy
+set CGO_ENABLED=1
+go build -buildmode=c-shared -o {{.Target}} .
+` + "```" + `
+
+The linker picks up ` + "`exports.def`" + ` through a cgo directive in ` + "`proxy.go`" + `. It exports
+every function under its original ordinal, for hosts that import by ordinal, and stubs for
+ordinal-only functions without a name. Stubs for C++ functions are named after them, as
+` + "`proxdll_cpp_Class_Method`" + `, and exported under their exact mangled names.
+
+To give the proxy the version resource, manifest and icons of the original, run
+` + "`proxdll-gen resources path\\to\\{{.Target}}`" + ` in this directory before building.
+
+## Installing
+
+1. Rename the original ` + "`{{.Target}}`" + ` to ` + "`{{.Original}}`" + `.
+2. Copy the built ` + "`{{.Target}}`" + ` next to it. The proxy loads the original from its own directory.
+{{- if .Forwards}}
+
+The loader resolves forwarded exports by loading ` + "`{{.ForwardModule}}`" + ` through the normal DLL
+search order, which starts at the host's directory rather than the proxy's. Keep both DLLs in
+the host's directory, or regenerate without ` + "`-forward`" + ` and ` + "`-hook`" + `.
+Exported variables are forwarded either way, as no stub can stand in for data.
+{{- end}}
+
+{{if eq .Arch "386" -}}
+The proxy must be built with ` + "`GOARCH=386`" + `. Exports decorated as stdcall, such as ` + "`_Name@8`" + `,
+or fastcall, such as ` + "`@Name@8`" + `, are stubbed with exactly their arguments, through the wrappers
+in ` + "`stdcall_windows_386.c`" + `.
+Other stubs forward {{len .Params}} pointer-sized arguments as cdecl functions, which corrupts
+the stack of stdcall exports without a decoration; regenerate those with ` + "`-thunk`" + `.
+{{- else -}}
+The proxy must be built with ` + "`GOARCH={{.Arch}}`" + `. Each stub forwards {{len .Params}} pointer-sized
+integer arguments in registers and on the stack. Edit the generated stubs for exports taking
+more, or floating-point, arguments, or regenerate them with ` + "`-thunk`" + `.
+{{- end}}
+{{- if .Thunks}}
+
+{{len .Thunks}} exports are stubbed by assembly thunks in ` + "`thunks_windows_{{.Arch}}.S`" + ` instead,
+which forward floating-point and any number of arguments unchanged, but run no hooks,
+tracing or config.
+{{- end}}
+
+## Configuration
+
+Tracing is configured without rebuilding, or restarting the host, by placing ` + "`{{stem .Target}}.proxdll.json`" + `
+(or ` + "`.yaml`" + `) next to the proxy, for example:
+
+` + "```json" + `
+{
+  "trace": ["*"],
+  "argDepth": 4,
+  "deny": [],
+  "sinks": [{"type": "jsonl", "path": "{{stem .Target}}.calls.jsonl"}]
+}
+` + "```" + `
+
+Without a config file, the settings in ` + "`defaults.proxdll.json`" + `, embedded when the proxy
+is built, apply. proxdll-gen does not overwrite that file once it exists.
+
+Environment variables override the file when the host starts: ` + "`PROXDLL_LOG`" + `,
+` + "`PROXDLL_ORIGINAL_PATH`" + `, ` + "`PROXDLL_CONFIG`" + `, ` + "`PROXDLL_TRACE_FUNCS`" + ` and
+` + "`PROXDLL_DENY_FUNCS`" + `. For example, ` + "`PROXDLL_TRACE_FUNCS=*`" + ` sends every call
+to the debugger output.
+`))
@@ -0,0 +1,26 @@
+// Package com helps proxy DLLs that are in-process COM servers.
+//
+// Such DLLs hand out objects through DllGetClassObject rather than plain exports, so
+// hooks on exports never see the calls clients make on them. A Server installs hooks on
+// DllGetClassObject and DllCanUnloadNow that wrap the class factories of selected
+// classes, letting a CreateHook see or replace each object they create, and that keep
+// the DLL loaded while any wrapper or object made by a hook is alive:
+//
+//	s := com.NewServer()
+//	s.OnCreateInstance(clsid, func(clsid, iid windows.GUID, obj uintptr) uintptr {
+//		log.Printf("created %v as %v", clsid, iid)
+//		return obj
+//	})
+//	s.Install(m)
+//
+// Methods of the objects themselves are hooked with an Interceptor, which takes the
+// same Hook functions as exports do, keyed by method name:
+//
+//	device := com.Unknown.Extend("IDirect3DDevice9", methods...)
+//	ic := com.NewInterceptor(device)
+//	ic.RegisterHook("Present", func(call *proxdll.Call, next proxdll.Next) (uintptr, uintptr, error) {
+//		frames++
+//		return next()
+//	})
+//	ic.Intercept(obj)
+package com
@@ -0,0 +1,254 @@
+//go:build windows
+
+package com
+
+import (
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+
+	"github.com/nilssoncreative/proxdll"
+)
+
+// HRESULT values used by the wrappers.
+const (
+	sOK          = 0
+	sFalse       = 1
+	eNoInterface = 0x80004002
+)
+
+var (
+	// IIDUnknown is the interface ID of IUnknown.
+	IIDUnknown = windows.GUID{Data1: 0x00000000, Data4: [8]byte{0xc0, 0, 0, 0, 0, 0, 0, 0x46}}
+	// IIDClassFactory is the interface ID of IClassFactory.
+	IIDClassFactory = windows.GUID{Data1: 0x00000001, Data4: [8]byte{0xc0, 0, 0, 0, 0, 0, 0, 0x46}}
+)
+
+// AnyClass registers a CreateHook for every class without a hook of its own.
+var AnyClass windows.GUID
+
+// CreateHook is called with each object a wrapped class factory creates and the
+// interface the client asked for. It returns the pointer handed to the client: obj
+// itself, or an object standing in for it that takes over the reference obj carries.
+// Objects that outlive the call should hold the server with Server.Lock.
+type CreateHook func(clsid, iid windows.GUID, obj uintptr) uintptr
+
+// Server wraps the class factories of a proxied COM server. It is safe for concurrent use.
+type Server struct {
+	mu    sync.RWMutex
+	hooks map[windows.GUID]CreateHook
+	// locks counts live factory wrappers, LockServer locks and Lock calls.
+	locks atomic.Int64
+}
+
+// NewServer returns a Server with no hooks.
+func NewServer() *Server {
+	return &Server{hooks: make(map[windows.GUID]CreateHook)}
+}
+
+// OnCreateInstance sets the hook for objects of class clsid, or with AnyClass, of every
+// class without its own hook. Only factories handed out after the hook is set are wrapped.
+func (s *Server) OnCreateInstance(clsid windows.GUID, hook CreateHook) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.hooks[clsid] = hook
+}
+
+func (s *Server) hook(clsid windows.GUID) CreateHook {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if hook, ok := s.hooks[clsid]; ok {
+		return hook
+	}
+	return s.hooks[AnyClass]
+}
+
+// Install hooks DllGetClassObject and DllCanUnloadNow on reg. If reg is a
+// *proxdll.Manager, it also registers the signature of DllGetClassObject, so traces
+// show the class and interface requested by name.
+func (s *Server) Install(reg proxdll.HookRegistry) {
+	reg.RegisterHook("DllGetClassObject", s.getClassObject)
+	reg.RegisterHook("DllCanUnloadNow", s.canUnloadNow)
+	if m, ok := reg.(*proxdll.Manager); ok {
+		m.RegisterSignature("DllGetClassObject", &proxdll.Signature{
+			Params: []proxdll.Param{
+				{Name: "rclsid", Decode: proxdll.GUIDPtr},
+				{Name: "riid", Decode: proxdll.GUIDPtr},
+				{Name: "ppv", Decode: proxdll.Pointer},
+			},
+			Result: proxdll.Hex,
+		})
+	}
+}
+
+// Lock keeps DllCanUnloadNow from reporting that the DLL can be unloaded, until a
+// matching Unlock. Objects a CreateHook makes hold a lock while they are alive.
+func (s *Server) Lock() {
+	s.locks.Add(1)
+}
+
+// Unlock releases a lock taken with Lock.
+func (s *Server) Unlock() {
+	s.locks.Add(-1)
+}
+
+// getClassObject wraps the factory returned by DllGetClassObject(rclsid, riid, ppv) if
+// its class has a hook.
+func (s *Server) getClassObject(call *proxdll.Call, next proxdll.Next) (uintptr, uintptr, error) {
+	r1, r2, err := next()
+	if int32(r1) < 0 || len(call.Args) < 3 || call.Args[0] == 0 || call.Args[1] == 0 || call.Args[2] == 0 {
+		return r1, r2, err
+	}
+	clsid, iid := readGUID(call.Args[0]), readGUID(call.Args[1])
+	hook := s.hook(clsid)
+	if hook == nil || (iid != IIDClassFactory && iid != IIDUnknown) {
+		return r1, r2, err
+	}
+	inner := load(call.Args[2])
+	if inner == 0 {
+		return r1, r2, err
+	}
+	store(call.Args[2], s.wrapFactory(clsid, inner, hook))
+	return r1, r2, err
+}
+
+// canUnloadNow reports S_FALSE while the proxy holds locks, and asks the original otherwise.
+func (s *Server) canUnloadNow(call *proxdll.Call, next proxdll.Next) (uintptr, uintptr, error) {
+	if s.locks.Load() > 0 {
+		return sFalse, 0, nil
+	}
+	return next()
+}
+
+// factory is a wrapper around an IClassFactory of the original DLL. vtbl must be the
+// first field, as the address of a factory is the COM object handed to clients.
+type factory struct {
+	vtbl   uintptr
+	refs   atomic.Int32
+	inner  uintptr
+	clsid  windows.GUID
+	hook   CreateHook
+	server *Server
+}
+
+// factories keeps wrappers alive, and finds them by address, while clients hold them.
+var factories sync.Map // uintptr -> *factory
+
+// IClassFactory method indexes, after the three IUnknown methods.
+const (
+	methodQueryInterface = 0
+	methodAddRef         = 1
+	methodRelease        = 2
+	methodCreateInstance = 3
+	methodLockServer     = 4
+)
+
+// factoryVtbl is the IClassFactory vtable shared by every wrapper.
+var factoryVtbl = sync.OnceValue(func() *[5]uintptr {
+	return &[5]uintptr{
+		syscall.NewCallback(factoryQueryInterface),
+		syscall.NewCallback(factoryAddRef),
+		syscall.NewCallback(factoryRelease),
+		syscall.NewCallback(factoryCreateInstance),
+		syscall.NewCallback(factoryLockServer),
+	}
+})
+
+// wrapFactory returns a wrapper taking over the reference held on inner.
+func (s *Server) wrapFactory(clsid windows.GUID, inner uintptr, hook CreateHook) uintptr {
+	f := &factory{vtbl: uintptr(unsafe.Pointer(factoryVtbl())), inner: inner, clsid: clsid, hook: hook, server: s}
+	f.refs.Store(1)
+	this := uintptr(unsafe.Pointer(f))
+	factories.Store(this, f)
+	s.Lock()
+	return this
+}
+
+func lookupFactory(this uintptr) *factory {
+	f, _ := factories.Load(this)
+	return f.(*factory)
+}
+
+func factoryQueryInterface(this, riid, ppv uintptr) uintptr {
+	if ppv == 0 {
+		return eNoInterface
+	}
+	f := lookupFactory(this)
+	if riid != 0 {
+		if iid := readGUID(riid); iid == IIDUnknown || iid == IIDClassFactory {
+			f.refs.Add(1)
+			store(ppv, this)
+			return sOK
+		}
+	}
+	// Other interfaces, such as IClassFactory2, are the original's; calls on them
+	// are not intercepted.
+	return Invoke(f.inner, methodQueryInterface, riid, ppv)
+}
+
+func factoryAddRef(this uintptr) uintptr {
+	return uintptr(lookupFactory(this).refs.Add(1))
+}
+
+func factoryRelease(this uintptr) uintptr {
+	f := lookupFactory(this)
+	refs := f.refs.Add(-1)
+	if refs == 0 {
+		Invoke(f.inner, methodRelease)
+		factories.Delete(this)
+		f.server.Unlock()
+	}
+	return uintptr(refs)
+}
+
+func factoryCreateInstance(this, outer, riid, ppv uintptr) uintptr {
+	f := lookupFactory(this)
+	hr := Invoke(f.inner, methodCreateInstance, outer, riid, ppv)
+	// Aggregated objects must stay as created, since their identity belongs to outer.
+	if int32(hr) < 0 || outer != 0 || riid == 0 || ppv == 0 {
+		return hr
+	}
+	if obj := load(ppv); obj != 0 {
+		store(ppv, f.hook(f.clsid, readGUID(riid), obj))
+	}
+	return hr
+}
+
+func factoryLockServer(this, lock uintptr) uintptr {
+	f := lookupFactory(this)
+	hr := Invoke(f.inner, methodLockServer, lock)
+	if int32(hr) >= 0 {
+		if lock != 0 {
+			f.server.Lock()
+		} else {
+			f.server.Unlock()
+		}
+	}
+	return hr
+}
+
+// Invoke calls method index of the COM object obj, passing obj as the this pointer,
+// and returns the HRESULT or other value it returns.
+func Invoke(obj uintptr, index int, args ...uintptr) uintptr {
+	vtbl := load(obj)
+	r, _, _ := syscall.SyscallN(load(vtbl+uintptr(index)*ptrSize), append([]uintptr{obj}, args...)...)
+	return r
+}
+
+// load reads the pointer-sized value at addr, which is memory owned by COM.
+func load(addr uintptr) uintptr {
+	return *(*uintptr)(unsafe.Add(unsafe.Pointer(nil), addr))
+}
+
+// store writes v at addr, which is memory owned by COM.
+func store(addr, v uintptr) {
+	*(*uintptr)(unsafe.Add(unsafe.Pointer(nil), addr)) = v
+}
+
+// readGUID copies the GUID at addr.
+func readGUID(addr uintptr) windows.GUID {
+	return *(*windows.GUID)(unsafe.Add(unsafe.Pointer(nil), addr))
+}
@@ -0,0 +1,209 @@
+//go:build windows
+
+package com
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"unsafe"
+
+	"github.com/nilssoncreative/proxdll"
+)
+
+// maxMethodArgs is the most arguments after the this pointer a hooked method may take.
+const maxMethodArgs = 15
+
+// Method describes a method of a COM interface.
+type Method struct {
+	Name string
+	// Args is the number of arguments after the this pointer. It must be exact, since on
+	// 386 methods are stdcall and pop their own arguments.
+	Args int
+}
+
+// Interface describes a COM interface as the methods of its vtable, in order, starting
+// with those of IUnknown.
+type Interface struct {
+	Name    string
+	Methods []Method
+	// Extra is the number of vtable slots after Methods that intercepted objects get
+	// copied unchanged, for objects whose vtable is longer than the interface's, such as
+	// an IDirect3DDevice9Ex intercepted as IDirect3DDevice9. Extend does not copy it.
+	Extra int
+}
+
+// Unknown is IUnknown, which every interface extends.
+var Unknown = &Interface{Name: "IUnknown", Methods: []Method{
+	{"QueryInterface", 2},
+	{"AddRef", 0},
+	{"Release", 0},
+}}
+
+// Extend returns the interface name, which adds methods to the vtable of i.
+func (i *Interface) Extend(name string, methods ...Method) *Interface {
+	return &Interface{Name: name, Methods: append(append([]Method(nil), i.Methods...), methods...)}
+}
+
+// Interceptor hooks methods of COM objects implementing one interface. Intercept gives
+// an object a copy of its vtable in which hooked methods run their hooks, leaving the
+// object itself, its identity and reference count unchanged. It implements
+// proxdll.HookRegistry with method names in place of exports, so hooks written for
+// exports work on methods too; Args[0] of a Call is the this pointer.
+//
+// The copy has the slots of the interface's Methods and Extra, and no more. An object
+// whose real vtable is longer, reached as a derived interface through QueryInterface
+// returning the same pointer, would have its host read past the copy; give Extra the
+// number of slots the most derived interface adds, or Restore such objects.
+//
+// Hooks run on the thread that calls the method. Methods with hooks when an object is
+// intercepted keep running them: hooks may be replaced or unregistered later, but only
+// objects intercepted afterwards see hooks for new methods.
+type Interceptor struct {
+	iface *Interface
+	mu    sync.Mutex
+	hooks []atomic.Pointer[proxdll.Hook]
+	// thunks holds the callback for each hooked method, made once per method, as
+	// callbacks are never freed.
+	thunks []uintptr
+	// tables maps each original vtable to its copy, and each copy to its original.
+	tables map[uintptr]*shimTable
+	byShim sync.Map // shim vtable -> *shimTable
+}
+
+var _ proxdll.HookRegistry = (*Interceptor)(nil)
+
+// shimTable is a copy of an original vtable with hooked methods replaced.
+type shimTable struct {
+	orig    uintptr
+	methods []uintptr
+}
+
+// NewInterceptor returns an Interceptor for objects implementing iface.
+func NewInterceptor(iface *Interface) *Interceptor {
+	return &Interceptor{
+		iface:  iface,
+		hooks:  make([]atomic.Pointer[proxdll.Hook], len(iface.Methods)),
+		thunks: make([]uintptr, len(iface.Methods)),
+		tables: make(map[uintptr]*shimTable),
+	}
+}
+
+func (ic *Interceptor) method(name string) int {
+	for i, m := range ic.iface.Methods {
+		if m.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// RegisterHook sets the hook for method name of the interface. It panics if the
+// interface has no such method or the method takes too many arguments to hook.
+func (ic *Interceptor) RegisterHook(name string, hook proxdll.Hook) {
+	i := ic.method(name)
+	if i < 0 {
+		panic(fmt.Sprintf("com: %s has no method %s", ic.iface.Name, name))
+	}
+	if ic.iface.Methods[i].Args > maxMethodArgs {
+		panic(fmt.Sprintf("com: %s::%s takes more than %d arguments", ic.iface.Name, name, maxMethodArgs))
+	}
+	ic.hooks[i].Store(&hook)
+}
+
+// UnregisterHook removes the hook for method name, if any.
+func (ic *Interceptor) UnregisterHook(name string) {
+	if i := ic.method(name); i >= 0 {
+		ic.hooks[i].Store(nil)
+	}
+}
+
+// Call calls method name on the object args[0] through its hook, as a client would.
+func (ic *Interceptor) Call(name string, args ...uintptr) (r1, r2 uintptr, lastErr error) {
+	i := ic.method(name)
+	if i < 0 || len(args) == 0 {
+		return 0, 0, fmt.Errorf("com: cannot call %s::%s", ic.iface.Name, name)
+	}
+	return ic.dispatch(i, args), 0, nil
+}
+
+// Intercept makes the methods of obj, which must point at the interface, run their
+// hooks, and returns obj, so Intercept can serve as a CreateHook body. Objects sharing
+// a vtable share its copy; intercepting an object twice has no further effect.
+func (ic *Interceptor) Intercept(obj uintptr) uintptr {
+	vtbl := load(obj)
+	if _, ok := ic.byShim.Load(vtbl); ok {
+		return obj
+	}
+
+	ic.mu.Lock()
+	t, ok := ic.tables[vtbl]
+	if !ok {
+		t = &shimTable{orig: vtbl, methods: make([]uintptr, len(ic.iface.Methods)+max(ic.iface.Extra, 0))}
+		for i := range t.methods {
+			t.methods[i] = load(vtbl + uintptr(i)*ptrSize)
+			if i < len(ic.hooks) && ic.hooks[i].Load() != nil {
+				t.methods[i] = ic.thunk(i, t.methods[i])
+			}
+		}
+		ic.tables[vtbl] = t
+		ic.byShim.Store(uintptr(unsafe.Pointer(&t.methods[0])), t)
+	}
+	ic.mu.Unlock()
+
+	store(obj, uintptr(unsafe.Pointer(&t.methods[0])))
+	return obj
+}
+
+// Restore gives obj back its original vtable.
+func (ic *Interceptor) Restore(obj uintptr) {
+	if t, ok := ic.byShim.Load(load(obj)); ok {
+		store(obj, t.(*shimTable).orig)
+	}
+}
+
+// original returns the original implementation of method i for the object this.
+func (ic *Interceptor) original(this uintptr, i int) uintptr {
+	vtbl := load(this)
+	if t, ok := ic.byShim.Load(vtbl); ok {
+		vtbl = t.(*shimTable).orig
+	}
+	return load(vtbl + uintptr(i)*ptrSize)
+}
+
+// dispatch runs the hook of method i, if any, ending in the original method.
+func (ic *Interceptor) dispatch(i int, args []uintptr) uintptr {
+	orig := ic.original(args[0], i)
+	hook := ic.hooks[i].Load()
+	if hook == nil {
+		r, _, _ := syscall.SyscallN(orig, args...)
+		return r
+	}
+	call := &proxdll.Call{Name: ic.iface.Methods[i].Name, Args: args, Depth: 1}
+	r1, _, _ := proxdll.RunHook(*hook, call, func() (uintptr, uintptr, error) {
+		r, _, _ := syscall.SyscallN(orig, call.Args...)
+		return r, 0, nil
+	})
+	return r1
+}
+
+// thunk returns the callback standing in for method i, or the original method if no
+// callback is left. Thunks are made once per method and kept, as objects may hold them
+// for the life of the process. The caller must hold ic.mu.
+func (ic *Interceptor) thunk(i int, orig uintptr) uintptr {
+	if ic.thunks[i] == 0 {
+		m := ic.iface.Methods[i]
+		addr, _, err := proxdll.NewCallback(ic.iface.Name+"::"+m.Name, m.Args+1, func(args []uintptr) uintptr {
+			return ic.dispatch(i, args)
+		})
+		if err != nil {
+			return orig
+		}
+		ic.thunks[i] = addr
+	}
+	return ic.thunks[i]
+}
+
+// ptrSize is the size of a vtable slot.
+const ptrSize = unsafe.Sizeof(uintptr(0))
@@ -0,0 +1,523 @@
+//go:build windows
+
+package proxdll
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/windows"
+	"gopkg.in/yaml.v3"
+)
+
+// Config declares how a Manager traces and filters calls, so logging can be changed
+// by editing a file next to the proxy instead of rebuilding it.
+type Config struct {
+	// LogLevel sets the level of the LevelVar registered with WithLogLevel, such as "debug".
+	LogLevel string `json:"logLevel,omitempty" yaml:"logLevel,omitempty"`
+	// Trace lists the exports whose calls are sent to Sinks. Empty or "*" traces every export.
+	Trace []string `json:"trace,omitempty" yaml:"trace,omitempty"`
+	// ArgDepth limits how many arguments are captured in traced events. Zero captures all of them.
+	ArgDepth int `json:"argDepth,omitempty" yaml:"argDepth,omitempty"`
+	// Hooks enables or disables registered hooks by export name or pattern, as SetHookEnabled does.
+	Hooks map[string]bool `json:"hooks,omitempty" yaml:"hooks,omitempty"`
+	// Deny lists exports that fail with ERROR_ACCESS_DENIED instead of reaching the original.
+	Deny []string `json:"deny,omitempty" yaml:"deny,omitempty"`
+	// Aliases maps exports of the proxy to differently named functions of the original,
+	// or to ordinals of the form "#N", as SetAlias does.
+	Aliases map[string]string `json:"aliases,omitempty" yaml:"aliases,omitempty"`
+	// Stubs makes the listed exports return fixed results instead of reaching the original,
+	// for example to make a telemetry initializer fail. Deny takes precedence.
+	Stubs map[string]StubConfig `json:"stubs,omitempty" yaml:"stubs,omitempty"`
+	// Faults makes calls of the listed exports fail at random or on given calls,
+	// as InjectFault does. Registered hooks still run for calls that do not fail.
+	Faults map[string]FaultConfig `json:"faults,omitempty" yaml:"faults,omitempty"`
+	// Delays slows calls of the listed exports down, as InjectDelay does.
+	Delays map[string]DelayConfig `json:"delays,omitempty" yaml:"delays,omitempty"`
+	// AllowCallers restricts calls to those made from the listed modules, such as "game.exe",
+	// compared case-insensitively. Calls from other modules fail with CallerError instead of
+	// reaching the original, and are logged. Empty allows every caller.
+	AllowCallers []string `json:"allowCallers,omitempty" yaml:"allowCallers,omitempty"`
+	// CallerError is the Win32 error code returned to callers rejected by AllowCallers.
+	// Zero means ERROR_ACCESS_DENIED.
+	CallerError uint32 `json:"callerError,omitempty" yaml:"callerError,omitempty"`
+	// Sinks lists where traced events are written.
+	Sinks []SinkConfig `json:"sinks,omitempty" yaml:"sinks,omitempty"`
+
+	// path is the file the config was read from, or empty for configs built in code.
+	path string
+	// dir is the directory relative sink paths are resolved against.
+	dir string
+}
+
+// SinkConfig declares one destination for traced events.
+type SinkConfig struct {
+	// Type is one of "debug", "log", "jsonl", "chrome" or "etw".
+	Type string `json:"type" yaml:"type"`
+	// Path is the output file of "jsonl" and "chrome" sinks, relative to the config file.
+	Path string `json:"path,omitempty" yaml:"path,omitempty"`
+	// Name is the provider name of an "etw" sink.
+	Name string `json:"name,omitempty" yaml:"name,omitempty"`
+}
+
+// StubConfig declares the results of an export replaced by a stub.
+type StubConfig struct {
+	// Return is the value returned to the host. Negative values are sign-extended,
+	// so -1 returns INVALID_HANDLE_VALUE.
+	Return int64 `json:"return" yaml:"return"`
+	// LastError is the Win32 error code the call reports, or zero for none.
+	LastError uint32 `json:"lastError,omitempty" yaml:"lastError,omitempty"`
+}
+
+// FaultConfig declares the failures injected into an export, as in Fault.
+type FaultConfig struct {
+	// Probability is the chance, from 0 to 1, that any one call fails.
+	Probability float64 `json:"probability,omitempty" yaml:"probability,omitempty"`
+	// Nth fails only the Nth call, counting from 1.
+	Nth int `json:"nth,omitempty" yaml:"nth,omitempty"`
+	// Every fails every Every-th call.
+	Every int `json:"every,omitempty" yaml:"every,omitempty"`
+	// Return is the value a failed call returns, sign-extended as in StubConfig.
+	Return int64 `json:"return,omitempty" yaml:"return,omitempty"`
+	// LastError is the Win32 error code a failed call reports, such as 1460 for ERROR_TIMEOUT.
+	LastError uint32 `json:"lastError,omitempty" yaml:"lastError,omitempty"`
+}
+
+// DelayConfig declares the latency injected into an export, as in Delay.
+// Durations use the syntax of time.ParseDuration, such as "250ms".
+type DelayConfig struct {
+	Before string `json:"before,omitempty" yaml:"before,omitempty"`
+	After  string `json:"after,omitempty" yaml:"after,omitempty"`
+	Jitter string `json:"jitter,omitempty" yaml:"jitter,omitempty"`
+}
+
+// parse converts the config to a Delay.
+func (c DelayConfig) parse() (Delay, error) {
+	var d Delay
+	for _, f := range []struct {
+		text string
+		dst  *time.Duration
+	}{{c.Before, &d.Before}, {c.After, &d.After}, {c.Jitter, &d.Jitter}} {
+		if f.text == "" {
+			continue
+		}
+		v, err := time.ParseDuration(f.text)
+		if err != nil {
+			return Delay{}, err
+		}
+		if v < 0 {
+			return Delay{}, fmt.Errorf("negative duration %s", f.text)
+		}
+		*f.dst = v
+	}
+	return d, nil
+}
+
+// configExts are the config file extensions searched by FindConfig, in order.
+var configExts = []string{".json", ".yaml", ".yml"}
+
+// LoadConfig reads a config file. Files ending in .yaml or .yml are parsed as YAML,
+// anything else as JSON. Unknown fields are rejected to catch typos.
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+
+	ext := strings.ToLower(filepath.Ext(path))
+	cfg, err := parseConfig(data, ext == ".yaml" || ext == ".yml")
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config %s: %w", path, err)
+	}
+	cfg.path = path
+	cfg.dir = filepath.Dir(path)
+	return cfg, nil
+}
+
+// readConfigFS reads a config file from fsys, such as an embed.FS, choosing the format
+// by extension as LoadConfig does. Relative sink paths are resolved against the proxy's directory.
+func readConfigFS(fsys fs.FS, name string) (*Config, error) {
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+
+	ext := strings.ToLower(path.Ext(name))
+	cfg, err := parseConfig(data, ext == ".yaml" || ext == ".yml")
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config %s: %w", name, err)
+	}
+	if self, err := SelfPath(); err == nil {
+		cfg.dir = filepath.Dir(self)
+	}
+	return cfg, nil
+}
+
+// parseConfig decodes a JSON or YAML config document.
+func parseConfig(data []byte, isYAML bool) (*Config, error) {
+	cfg := &Config{}
+	if isYAML {
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+			return nil, err
+		}
+		return cfg, nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// FindConfig returns the path of the config file next to the proxy DLL, named after it
+// with a .proxdll.json, .proxdll.yaml or .proxdll.yml extension, for example
+// version.proxdll.json beside version.dll. It returns an error wrapping fs.ErrNotExist
+// if there is none.
+func FindConfig() (string, error) {
+	paths, err := configCandidates()
+	if err != nil {
+		return "", err
+	}
+	for _, path := range paths {
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("no config file found at %s: %w", strings.Join(paths, ", "), fs.ErrNotExist)
+}
+
+// configCandidates returns the paths FindConfig looks for, in order.
+func configCandidates() ([]string, error) {
+	self, err := SelfPath()
+	if err != nil {
+		return nil, err
+	}
+	base := strings.TrimSuffix(self, filepath.Ext(self)) + ".proxdll"
+	paths := make([]string, len(configExts))
+	for i, ext := range configExts {
+		paths[i] = base + ext
+	}
+	return paths, nil
+}
+
+// configState is the compiled form of a Config, read on every call.
+type configState struct {
+	logLevel *slog.Level
+	hooks    map[string]bool
+	traceAll bool
+	trace    map[string]bool
+	argDepth int
+	deny     map[string]bool
+	aliases  map[string]string
+	stubs    map[string]Hook
+	inject   map[string]Hook
+	callers  map[string]bool
+	reject   Hook
+	sinks    multiTracer
+	remove   func()
+}
+
+// traces reports whether calls to funcName are sent to the config's sinks.
+func (s *configState) traces(funcName string) bool {
+	return s.traceAll || s.trace[funcName]
+}
+
+// ApplyConfig replaces the Manager's current config with cfg, opening its sinks.
+// Sinks of the previous config are closed, and hooks it disabled are enabled again
+// unless cfg disables them too. A nil cfg removes the current config.
+// If cfg is invalid, the current config stays in effect.
+func (m *Manager) ApplyConfig(cfg *Config) error {
+	var next *configState
+	if cfg != nil {
+		var err error
+		if next, err = m.compileConfig(cfg); err != nil {
+			return err
+		}
+	}
+
+	m.configMu.Lock()
+	defer m.configMu.Unlock()
+
+	if next != nil && len(next.sinks) > 0 {
+		next.remove = m.AddTracer(&configTracer{state: next})
+	}
+	prev := m.config.Swap(next)
+	m.applyConfigHooks(prev, next)
+	m.evictAliases(prev, next)
+	if next != nil && next.logLevel != nil {
+		if m.logLevel != nil {
+			m.logLevel.Set(*next.logLevel)
+		} else {
+			m.logger.Warn("config log level ignored, see WithLogLevel", "level", next.logLevel.String())
+		}
+	}
+	if prev != nil {
+		if prev.remove != nil {
+			prev.remove()
+		}
+		if err := prev.sinks.Close(); err != nil {
+			m.logger.Warn("failed to close config sinks", "error", err)
+		}
+	}
+	return nil
+}
+
+// compileConfig validates cfg and opens its sinks.
+func (m *Manager) compileConfig(cfg *Config) (*configState, error) {
+	if m.fuzzyConfig {
+		cfg = m.fuzzyNames(cfg)
+	}
+	var logLevel *slog.Level
+	if cfg.LogLevel != "" {
+		logLevel = new(slog.Level)
+		if err := logLevel.UnmarshalText([]byte(cfg.LogLevel)); err != nil {
+			return nil, fmt.Errorf("invalid log level in config: %w", err)
+		}
+	}
+	if cfg.ArgDepth < 0 {
+		return nil, fmt.Errorf("invalid argument depth in config: %d", cfg.ArgDepth)
+	}
+
+	state := &configState{
+		logLevel: logLevel,
+		hooks:    cfg.Hooks,
+		traceAll: len(cfg.Trace) == 0,
+		trace:    make(map[string]bool, len(cfg.Trace)),
+		argDepth: cfg.ArgDepth,
+		deny:     make(map[string]bool, len(cfg.Deny)),
+		aliases:  cfg.Aliases,
+	}
+	for _, name := range cfg.Trace {
+		if name == "*" {
+			state.traceAll = true
+		}
+		state.trace[name] = true
+	}
+	for _, name := range cfg.Deny {
+		state.deny[name] = true
+	}
+	if len(cfg.Stubs) > 0 {
+		state.stubs = make(map[string]Hook, len(cfg.Stubs))
+		for name, stub := range cfg.Stubs {
+			var lastErr error
+			if stub.LastError != 0 {
+				lastErr = windows.Errno(stub.LastError)
+			}
+			state.stubs[name] = Stub(uintptr(stub.Return), 0, lastErr)
+		}
+	}
+	if len(cfg.Faults) > 0 || len(cfg.Delays) > 0 {
+		state.inject = make(map[string]Hook, len(cfg.Faults)+len(cfg.Delays))
+	}
+	for name, c := range cfg.Delays {
+		d, err := c.parse()
+		if err != nil {
+			return nil, fmt.Errorf("invalid delay for %s in config: %w", name, err)
+		}
+		state.inject[name] = InjectDelay(d)
+	}
+	for name, f := range cfg.Faults {
+		if f.Probability < 0 || f.Probability > 1 || f.Nth < 0 || f.Every < 0 {
+			return nil, fmt.Errorf("invalid fault for %s in config", name)
+		}
+		var lastErr error
+		if f.LastError != 0 {
+			lastErr = windows.Errno(f.LastError)
+		}
+		// The delay runs first, so failed calls are slow too.
+		state.inject[name] = wrapHook(state.inject[name], InjectFault(Fault{
+			Probability: f.Probability,
+			Nth:         f.Nth,
+			Every:       f.Every,
+			R1:          uintptr(f.Return),
+			LastErr:     lastErr,
+		}))
+	}
+	if len(cfg.AllowCallers) > 0 {
+		state.callers = make(map[string]bool, len(cfg.AllowCallers))
+		for _, module := range cfg.AllowCallers {
+			state.callers[strings.ToLower(module)] = true
+		}
+		code := windows.ERROR_ACCESS_DENIED
+		if cfg.CallerError != 0 {
+			code = windows.Errno(cfg.CallerError)
+		}
+		state.reject = Stub(0, 0, code)
+	}
+
+	for _, sink := range cfg.Sinks {
+		tracer, err := m.openSink(sink, cfg.dir)
+		if err != nil {
+			state.sinks.Close()
+			return nil, err
+		}
+		state.sinks = append(state.sinks, tracer)
+	}
+	return state, nil
+}
+
+// applyConfigHooks moves hook enablement from the prev config to next.
+// Hooks need not be registered yet, so a disabled hook registered later starts disabled.
+func (m *Manager) applyConfigHooks(prev, next *configState) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if prev != nil {
+		for name, enabled := range prev.hooks {
+			if !enabled {
+				delete(m.disabledHooks, name)
+			}
+		}
+	}
+	if next != nil {
+		for name, enabled := range next.hooks {
+			if enabled {
+				delete(m.disabledHooks, name)
+			} else {
+				m.disabledHooks[name] = true
+			}
+		}
+	}
+	m.invalidateHooks()
+}
+
+// evictAliases drops resolved exports aliased by the prev config or next, so they resolve again.
+func (m *Manager) evictAliases(prev, next *configState) {
+	var names []string
+	for _, s := range []*configState{prev, next} {
+		if s == nil {
+			continue
+		}
+		for name := range s.aliases {
+			names = append(names, name)
+		}
+	}
+	if len(names) > 0 {
+		m.procs.evict(names...)
+	}
+}
+
+// openSink creates the tracer described by sink.
+func (m *Manager) openSink(sink SinkConfig, dir string) (Tracer, error) {
+	path := sink.Path
+	if path != "" && !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+
+	switch sink.Type {
+	case "debug":
+		return DebugTracer(), nil
+	case "log":
+		return LogTracer(m.logger, slog.LevelInfo), nil
+	case "jsonl", "chrome":
+		if sink.Path == "" {
+			return nil, fmt.Errorf("%s sink in config requires a path", sink.Type)
+		}
+		if sink.Type == "jsonl" {
+			return CreateJSONLTracer(path)
+		}
+		return CreateChromeTracer(path)
+	case "etw":
+		if sink.Name == "" {
+			return nil, errors.New("etw sink in config requires a name")
+		}
+		return NewETWTracer(sink.Name)
+	default:
+		return nil, fmt.Errorf("unknown sink type in config: %q", sink.Type)
+	}
+}
+
+// configTracer forwards the events selected by a config to its sinks.
+type configTracer struct {
+	state *configState
+}
+
+func (t *configTracer) TraceStart(ev *Event) {
+	if t.state.traces(ev.Func) {
+		t.state.sinks.TraceStart(t.capture(ev))
+	}
+}
+
+func (t *configTracer) Trace(ev *Event) {
+	if t.state.traces(ev.Func) {
+		t.state.sinks.Trace(t.capture(ev))
+	}
+}
+
+func (t *configTracer) ThreadAttached(threadID uint32) {
+	t.state.sinks.ThreadAttached(threadID)
+}
+
+func (t *configTracer) ThreadDetached(threadID uint32) {
+	t.state.sinks.ThreadDetached(threadID)
+}
+
+// capture returns ev with its arguments limited to the configured depth. The copy is
+// made once per event and refreshed from ev on each use, so TraceStart and Trace
+// receive the same event, as StartTracer promises.
+func (t *configTracer) capture(ev *Event) *Event {
+	if t.state.argDepth == 0 || len(ev.Args) <= t.state.argDepth {
+		return ev
+	}
+	limited := ev.limited
+	if limited == nil {
+		limited = new(Event)
+		ev.limited = limited
+	}
+	*limited = *ev
+	limited.limited = nil
+	limited.Args = ev.Args[:t.state.argDepth]
+	return limited
+}
+
+// configHook returns the hook that replaces funcName under the current config,
+// because it is deny-listed or stubbed, or nil if the call may proceed.
+func (m *Manager) configHook(funcName string) Hook {
+	s := m.config.Load()
+	if s == nil {
+		return nil
+	}
+	if s.deny[funcName] {
+		return denyHook
+	}
+	return s.stubs[funcName]
+}
+
+// injectHook returns the delays and faults injected into funcName by the current config, or nil.
+func (m *Manager) injectHook(funcName string) Hook {
+	if s := m.config.Load(); s != nil {
+		return s.inject[funcName]
+	}
+	return nil
+}
+
+// callerHook returns the hook that fails funcName when the current config does not allow
+// its caller, or nil if the call may proceed. Calls whose caller cannot be identified are
+// allowed, so a failed stack walk cannot break the host.
+func (m *Manager) callerHook(funcName string) Hook {
+	s := m.config.Load()
+	if s == nil || s.callers == nil {
+		return nil
+	}
+	caller := captureCaller()
+	if caller.Module == "" || s.callers[strings.ToLower(caller.Module)] {
+		return nil
+	}
+	m.logger.Warn("call rejected from caller not allowed by config", "func", funcName, "caller", caller.String())
+	return s.reject
+}
+
+// denyHook fails a deny-listed call without reaching the original.
+var denyHook = Stub(0, 0, windows.ERROR_ACCESS_DENIED)
@@ -0,0 +1,83 @@
+//go:build windows
+
+package proxdll
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/sys/windows/registry"
+)
+
+// RegistryConfigKey returns the registry key path, under HKEY_CURRENT_USER or
+// HKEY_LOCAL_MACHINE, that holds the config for the proxy named dllName.
+func RegistryConfigKey(dllName string) string {
+	return `Software\proxdll\` + strings.TrimSuffix(filepath.Base(dllName), filepath.Ext(dllName))
+}
+
+// LoadRegistryConfig reads the config for dllName from the key given by RegistryConfigKey,
+// preferring HKEY_CURRENT_USER over HKEY_LOCAL_MACHINE. The two keys are not merged.
+//
+// A "Config" string value holds a complete JSON document, as in a config file. The string
+// value "LogLevel", the multi-string values "Trace" and "Deny" and the DWORD value "ArgDepth"
+// override the corresponding fields of that document, or stand alone without it.
+// Relative sink paths are resolved against the proxy's directory.
+// It returns an error wrapping fs.ErrNotExist if neither key exists.
+func LoadRegistryConfig(dllName string) (*Config, error) {
+	path := RegistryConfigKey(dllName)
+	for _, root := range []registry.Key{registry.CURRENT_USER, registry.LOCAL_MACHINE} {
+		key, err := registry.OpenKey(root, path, registry.QUERY_VALUE)
+		if errors.Is(err, registry.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to open registry key %s: %w", path, err)
+		}
+		cfg, err := readRegistryConfig(key)
+		key.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config from registry key %s: %w", path, err)
+		}
+		if self, err := SelfPath(); err == nil {
+			cfg.dir = filepath.Dir(self)
+		}
+		return cfg, nil
+	}
+	return nil, fmt.Errorf("no registry config at %s: %w", path, fs.ErrNotExist)
+}
+
+func readRegistryConfig(key registry.Key) (*Config, error) {
+	cfg := &Config{}
+	if doc, _, err := key.GetStringValue("Config"); err == nil {
+		if cfg, err = parseConfig([]byte(doc), false); err != nil {
+			return nil, fmt.Errorf("invalid Config value: %w", err)
+		}
+	} else if !errors.Is(err, registry.ErrNotExist) {
+		return nil, err
+	}
+
+	if level, _, err := key.GetStringValue("LogLevel"); err == nil {
+		cfg.LogLevel = level
+	} else if !errors.Is(err, registry.ErrNotExist) {
+		return nil, err
+	}
+	if trace, _, err := key.GetStringsValue("Trace"); err == nil {
+		cfg.Trace = trace
+	} else if !errors.Is(err, registry.ErrNotExist) {
+		return nil, err
+	}
+	if deny, _, err := key.GetStringsValue("Deny"); err == nil {
+		cfg.Deny = deny
+	} else if !errors.Is(err, registry.ErrNotExist) {
+		return nil, err
+	}
+	if depth, _, err := key.GetIntegerValue("ArgDepth"); err == nil {
+		cfg.ArgDepth = int(depth)
+	} else if !errors.Is(err, registry.ErrNotExist) {
+		return nil, err
+	}
+	return cfg, nil
+}
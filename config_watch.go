@@ -0,0 +1,134 @@
+//go:build windows
+
+package proxdll
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"golang.org/x/sys/windows"
+)
+
+// configSettleDelay is how long the watcher waits after a change notification before
+// reading the file, since editors often write a file in several steps.
+const configSettleDelay = 100 * time.Millisecond
+
+// ConfigWatcher reapplies a config file to a Manager whenever the file changes.
+type ConfigWatcher struct {
+	m *Manager
+	// paths are the files watched, in order of preference; the first that exists is applied.
+	paths     []string
+	stop      windows.Handle
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// WatchConfig watches the config file at path and applies it with ApplyConfig each time
+// it is written, so log levels, traced exports and hook enablement can be tuned while the
+// host keeps running. A change that fails to load or apply is logged and the previous
+// config stays in effect. The file is not applied when watching starts, and need not
+// exist yet: it is applied once it is created.
+func (m *Manager) WatchConfig(path string) (*ConfigWatcher, error) {
+	return m.watchConfig([]string{path})
+}
+
+// watchConfig watches paths, which must share a directory, applying the first of them
+// that exists whenever it changes.
+func (m *Manager) watchConfig(paths []string) (*ConfigWatcher, error) {
+	abs := make([]string, len(paths))
+	for i, path := range paths {
+		var err error
+		if abs[i], err = filepath.Abs(path); err != nil {
+			return nil, fmt.Errorf("failed to resolve config path: %w", err)
+		}
+	}
+	path := abs[0]
+
+	// Watch the directory rather than the file, so editors that replace the file
+	// by renaming a temporary one, and files created later, are noticed too.
+	change, err := windows.FindFirstChangeNotification(filepath.Dir(path), false,
+		windows.FILE_NOTIFY_CHANGE_FILE_NAME|windows.FILE_NOTIFY_CHANGE_SIZE|windows.FILE_NOTIFY_CHANGE_LAST_WRITE)
+	if err != nil {
+		return nil, fmt.Errorf("failed to watch config directory of %s: %w", path, err)
+	}
+	stop, err := windows.CreateEvent(nil, 1, 0, nil)
+	if err != nil {
+		windows.FindCloseChangeNotification(change)
+		return nil, fmt.Errorf("failed to create config watcher event: %w", err)
+	}
+
+	w := &ConfigWatcher{m: m, paths: abs, stop: stop, done: make(chan struct{})}
+	go w.run(change)
+	m.logger.Debug("watching config file", "path", path, "alternatives", len(abs)-1)
+	return w, nil
+}
+
+// Close stops watching and waits for the watcher goroutine to exit.
+func (w *ConfigWatcher) Close() error {
+	w.closeOnce.Do(func() {
+		windows.SetEvent(w.stop)
+		<-w.done
+		windows.CloseHandle(w.stop)
+	})
+	return nil
+}
+
+func (w *ConfigWatcher) run(change windows.Handle) {
+	defer close(w.done)
+	defer windows.FindCloseChangeNotification(change)
+
+	lastPath, last := w.stat()
+	for {
+		event, err := windows.WaitForMultipleObjects([]windows.Handle{change, w.stop}, false, windows.INFINITE)
+		if err != nil {
+			w.m.logger.Error("config watcher failed", "path", w.paths[0], "error", err)
+			return
+		}
+		if event != windows.WAIT_OBJECT_0 {
+			return
+		}
+		if err := windows.FindNextChangeNotification(change); err != nil {
+			w.m.logger.Error("config watcher failed", "path", w.paths[0], "error", err)
+			return
+		}
+
+		// Let the writer finish, unless the watcher is closed in the meantime.
+		if event, _ := windows.WaitForSingleObject(w.stop, uint32(configSettleDelay/time.Millisecond)); event == windows.WAIT_OBJECT_0 {
+			return
+		}
+		// Other files in the directory change too; only reload when the config did.
+		// A deleted config leaves the one applied last in effect.
+		path, info := w.stat()
+		if info == nil || (path == lastPath && last != nil && info.ModTime().Equal(last.ModTime()) && info.Size() == last.Size()) {
+			continue
+		}
+		lastPath, last = path, info
+		w.reload(path)
+	}
+}
+
+// stat returns the first watched file that exists and its metadata, or a nil
+// FileInfo if none does.
+func (w *ConfigWatcher) stat() (string, os.FileInfo) {
+	for _, path := range w.paths {
+		if info, err := os.Stat(path); err == nil {
+			return path, info
+		}
+	}
+	return "", nil
+}
+
+func (w *ConfigWatcher) reload(path string) {
+	cfg, err := LoadConfig(path)
+	if err == nil {
+		err = w.m.ApplyConfig(w.m.overrideConfig(cfg))
+	}
+	if err != nil {
+		w.m.logger.Warn("failed to reload config, keeping previous", "path", path, "error", err)
+		return
+	}
+	w.m.logger.Info("reloaded config", "path", path)
+}
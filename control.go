@@ -0,0 +1,422 @@
+//go:build windows
+
+package proxdll
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// controlPipeBufferSize is the in and out buffer size of the control pipe.
+const controlPipeBufferSize = 4096
+
+// controlConnTimeout bounds how long a client may take to send its command and read
+// the response, so a stalled client cannot keep others from being served.
+const controlConnTimeout = 5 * time.Second
+
+// controlCloseTimeout bounds how long Close waits for a running command to finish.
+const controlCloseTimeout = 10 * time.Second
+
+var (
+	errControlClosed  = errors.New("control server closed")
+	errControlTimeout = errors.New("control client timed out")
+)
+
+// ControlPipeName returns the name of the control pipe served by the process with the given ID.
+func ControlPipeName(pid int) string {
+	return fmt.Sprintf(`\\.\pipe\proxdll-%d`, pid)
+}
+
+// ControlCommand handles one control command and returns the text sent back to the client.
+type ControlCommand func(args []string) (string, error)
+
+// ControlServer accepts commands on a named pipe, one command per connection.
+// A request is a single line holding the command name and its space-separated arguments;
+// the response starts with "ok" or "error: <message>", followed by the command output.
+type ControlServer struct {
+	m        *Manager
+	name     string
+	mu       sync.Mutex
+	commands map[string]ControlCommand
+	closed   bool
+	// stop is a manual-reset event set by Close, which wakes pending pipe operations.
+	stop windows.Handle
+	done chan struct{}
+}
+
+// ServeControl starts a control server on ControlPipeName(os.Getpid()).
+// Only local clients running as the same user can connect. Built-in
+// commands are help, list-hooks, toggle-hook NAME, list-groups, enable-group NAME,
+// disable-group NAME, dump-stats, dump-recent and set-log-level LEVEL; Handle adds more.
+func (m *Manager) ServeControl() (*ControlServer, error) {
+	s := &ControlServer{
+		m:        m,
+		name:     ControlPipeName(os.Getpid()),
+		commands: make(map[string]ControlCommand),
+		done:     make(chan struct{}),
+	}
+	s.commands["help"] = s.help
+	s.commands["list-hooks"] = s.listHooks
+	s.commands["toggle-hook"] = s.toggleHook
+	s.commands["list-groups"] = s.listGroups
+	s.commands["enable-group"] = s.enableGroup
+	s.commands["disable-group"] = s.disableGroup
+	s.commands["dump-stats"] = s.dumpStats
+	s.commands["dump-recent"] = s.dumpRecent
+	s.commands["set-log-level"] = s.setLogLevel
+
+	stop, err := windows.CreateEvent(nil, 1, 0, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create control pipe event: %w", err)
+	}
+	s.stop = stop
+	// Create the first instance up front so setup errors reach the caller.
+	pipe, err := s.createPipe()
+	if err != nil {
+		windows.CloseHandle(stop)
+		return nil, err
+	}
+	go s.serve(pipe)
+	m.logger.Info("serving control channel", "pipe", s.name)
+	return s, nil
+}
+
+// Name returns the pipe name the server listens on.
+func (s *ControlServer) Name() string {
+	return s.name
+}
+
+// Handle registers cmd under name, replacing any existing command with that name.
+func (s *ControlServer) Handle(name string, cmd ControlCommand) {
+	s.mu.Lock()
+	s.commands[name] = cmd
+	s.mu.Unlock()
+}
+
+// Close stops the server and waits for the serving goroutine to exit. A client being
+// served is disconnected, but a command already running is waited for, up to a bound.
+func (s *ControlServer) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	windows.SetEvent(s.stop)
+	s.mu.Unlock()
+
+	select {
+	case <-s.done:
+		return nil
+	case <-time.After(controlCloseTimeout):
+		return fmt.Errorf("control server did not stop within %v", controlCloseTimeout)
+	}
+}
+
+func (s *ControlServer) createPipe() (windows.Handle, error) {
+	name, err := windows.UTF16PtrFromString(s.name)
+	if err != nil {
+		return 0, err
+	}
+	sa, err := controlPipeSecurity()
+	if err != nil {
+		return 0, fmt.Errorf("failed to secure control pipe %s: %w", s.name, err)
+	}
+	// The name is predictable, so the first instance flag makes creation fail if another
+	// process took it first, rather than joining its pipe.
+	pipe, err := windows.CreateNamedPipe(name,
+		windows.PIPE_ACCESS_DUPLEX|windows.FILE_FLAG_OVERLAPPED|windows.FILE_FLAG_FIRST_PIPE_INSTANCE,
+		windows.PIPE_TYPE_BYTE|windows.PIPE_READMODE_BYTE|windows.PIPE_WAIT|windows.PIPE_REJECT_REMOTE_CLIENTS,
+		1, controlPipeBufferSize, controlPipeBufferSize, 0, sa)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create control pipe %s: %w", s.name, err)
+	}
+	return pipe, nil
+}
+
+// controlPipeSecurity returns security attributes granting access to the control pipe
+// only to the user the process runs as.
+func controlPipeSecurity() (*windows.SecurityAttributes, error) {
+	user, err := windows.GetCurrentProcessToken().GetTokenUser()
+	if err != nil {
+		return nil, err
+	}
+	sd, err := windows.SecurityDescriptorFromString("D:P(A;;GA;;;" + user.User.Sid.String() + ")")
+	if err != nil {
+		return nil, err
+	}
+	return &windows.SecurityAttributes{
+		Length:             uint32(unsafe.Sizeof(windows.SecurityAttributes{})),
+		SecurityDescriptor: sd,
+	}, nil
+}
+
+func (s *ControlServer) serve(pipe windows.Handle) {
+	defer close(s.done)
+	defer windows.CloseHandle(s.stop)
+	defer windows.CloseHandle(pipe)
+
+	event, err := windows.CreateEvent(nil, 1, 0, nil)
+	if err != nil {
+		s.m.logger.Warn("control pipe stopped", "error", err)
+		return
+	}
+	defer windows.CloseHandle(event)
+
+	for {
+		err := s.connect(pipe, event)
+		if errors.Is(err, errControlClosed) {
+			return
+		}
+		if err == nil {
+			s.handle(&pipeConn{s: s, pipe: pipe, event: event, deadline: time.Now().Add(controlConnTimeout)})
+		}
+		windows.DisconnectNamedPipe(pipe)
+
+		if err != nil && !errors.Is(err, windows.ERROR_NO_DATA) {
+			s.m.logger.Warn("control pipe connection failed", "error", err)
+			// Avoid spinning if the pipe is persistently broken.
+			time.Sleep(100 * time.Millisecond)
+		}
+	}
+}
+
+// connect waits for a client to connect to pipe, or for Close.
+func (s *ControlServer) connect(pipe, event windows.Handle) error {
+	ov := &windows.Overlapped{HEvent: event}
+	err := windows.ConnectNamedPipe(pipe, ov)
+	switch {
+	case err == nil, errors.Is(err, windows.ERROR_PIPE_CONNECTED):
+		return nil
+	case errors.Is(err, windows.ERROR_IO_PENDING):
+		_, err = s.wait(pipe, ov, windows.INFINITE)
+		return err
+	}
+	return err
+}
+
+// wait waits up to timeout milliseconds for the overlapped operation ov on pipe to
+// complete, cancelling it if the time runs out or the server is closed first.
+func (s *ControlServer) wait(pipe windows.Handle, ov *windows.Overlapped, timeout uint32) (uint32, error) {
+	event, err := windows.WaitForMultipleObjects([]windows.Handle{ov.HEvent, s.stop}, false, timeout)
+	if err != nil {
+		return 0, err
+	}
+	if event != windows.WAIT_OBJECT_0 {
+		windows.CancelIoEx(pipe, ov)
+	}
+	// Wait for the operation, or its cancellation, so ov is no longer in use.
+	var n uint32
+	err = windows.GetOverlappedResult(pipe, ov, &n, true)
+	switch event {
+	case windows.WAIT_OBJECT_0:
+		return n, err
+	case windows.WAIT_OBJECT_0 + 1:
+		return n, errControlClosed
+	}
+	return n, errControlTimeout
+}
+
+// handle reads one command from a connected client and writes the response.
+func (s *ControlServer) handle(conn *pipeConn) {
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil && line == "" {
+		return
+	}
+
+	out, err := s.run(strings.Fields(line))
+	var b strings.Builder
+	if err != nil {
+		fmt.Fprintf(&b, "error: %v\n", err)
+	} else {
+		b.WriteString("ok\n")
+	}
+	b.WriteString(out)
+	if out != "" && !strings.HasSuffix(out, "\n") {
+		b.WriteString("\n")
+	}
+	if _, err := io.WriteString(conn, b.String()); err != nil {
+		return
+	}
+	// Disconnecting discards what the client has not read yet, so wait for it to close
+	// its end, within the deadline, rather than flush, which a stalled client would block.
+	io.Copy(io.Discard, conn)
+}
+
+// pipeConn reads and writes a pipe handle it does not own, unlike os.File, which would
+// close the handle when finalized. Operations fail once deadline passes or the server
+// is closed.
+type pipeConn struct {
+	s        *ControlServer
+	pipe     windows.Handle
+	event    windows.Handle
+	deadline time.Time
+}
+
+func (c *pipeConn) Read(p []byte) (int, error) {
+	n, err := c.do(func(ov *windows.Overlapped, n *uint32) error {
+		return windows.ReadFile(c.pipe, p, n, ov)
+	})
+	if errors.Is(err, windows.ERROR_BROKEN_PIPE) {
+		return n, io.EOF
+	}
+	if err == nil && n == 0 && len(p) > 0 {
+		return 0, io.EOF
+	}
+	return n, err
+}
+
+func (c *pipeConn) Write(p []byte) (int, error) {
+	return c.do(func(ov *windows.Overlapped, n *uint32) error {
+		return windows.WriteFile(c.pipe, p, n, ov)
+	})
+}
+
+// do starts an overlapped operation with start and waits for it until the deadline.
+func (c *pipeConn) do(start func(ov *windows.Overlapped, n *uint32) error) (int, error) {
+	left := time.Until(c.deadline)
+	if left <= 0 {
+		return 0, errControlTimeout
+	}
+	ov := &windows.Overlapped{HEvent: c.event}
+	var n uint32
+	err := start(ov, &n)
+	if errors.Is(err, windows.ERROR_IO_PENDING) {
+		n, err = c.s.wait(c.pipe, ov, uint32(left.Milliseconds())+1)
+	}
+	return int(n), err
+}
+
+// run dispatches a parsed command line.
+func (s *ControlServer) run(fields []string) (string, error) {
+	if len(fields) == 0 {
+		return "", errors.New("empty command")
+	}
+	s.mu.Lock()
+	cmd, ok := s.commands[fields[0]]
+	s.mu.Unlock()
+	if !ok {
+		return "", fmt.Errorf("unknown command %q, try help", fields[0])
+	}
+	return cmd(fields[1:])
+}
+
+func (s *ControlServer) help(args []string) (string, error) {
+	s.mu.Lock()
+	names := make([]string, 0, len(s.commands))
+	for name := range s.commands {
+		names = append(names, name)
+	}
+	s.mu.Unlock()
+	slices.Sort(names)
+	return strings.Join(names, "\n"), nil
+}
+
+func (s *ControlServer) listHooks(args []string) (string, error) {
+	var b strings.Builder
+	for _, name := range s.m.Hooks() {
+		state := "enabled"
+		if !s.m.HookEnabled(name) {
+			state = "disabled"
+		}
+		fmt.Fprintf(&b, "%s\t%s\n", name, state)
+	}
+	return b.String(), nil
+}
+
+func (s *ControlServer) toggleHook(args []string) (string, error) {
+	if len(args) != 1 {
+		return "", errors.New("usage: toggle-hook NAME")
+	}
+	enabled := !s.m.HookEnabled(args[0])
+	if err := s.m.SetHookEnabled(args[0], enabled); err != nil {
+		return "", err
+	}
+	if enabled {
+		return args[0] + " enabled", nil
+	}
+	return args[0] + " disabled", nil
+}
+
+func (s *ControlServer) listGroups(args []string) (string, error) {
+	var b strings.Builder
+	for _, group := range s.m.Groups() {
+		state := "enabled"
+		if !s.m.GroupEnabled(group) {
+			state = "disabled"
+		}
+		fmt.Fprintf(&b, "%s\t%s\n", group, state)
+	}
+	return b.String(), nil
+}
+
+func (s *ControlServer) enableGroup(args []string) (string, error) {
+	if len(args) != 1 {
+		return "", errors.New("usage: enable-group NAME")
+	}
+	s.m.EnableGroup(args[0])
+	return args[0] + " enabled", nil
+}
+
+func (s *ControlServer) disableGroup(args []string) (string, error) {
+	if len(args) != 1 {
+		return "", errors.New("usage: disable-group NAME")
+	}
+	s.m.DisableGroup(args[0])
+	return args[0] + " disabled", nil
+}
+
+func (s *ControlServer) dumpStats(args []string) (string, error) {
+	stats := s.m.Stats()
+	if stats == nil {
+		return "", errors.New("statistics are not enabled, see WithStats")
+	}
+	names := make([]string, 0, len(stats))
+	for name := range stats {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%-40s %10s %10s %12s %12s\n", "FUNC", "CALLS", "ERRORS", "MEAN", "MAX")
+	for _, name := range names {
+		st := stats[name]
+		fmt.Fprintf(&b, "%-40s %10d %10d %12s %12s\n", name, st.Calls, st.Errors, st.Mean, st.Max)
+	}
+	return b.String(), nil
+}
+
+func (s *ControlServer) dumpRecent(args []string) (string, error) {
+	if s.m.recorder == nil {
+		return "", errors.New("flight recorder is not enabled, see WithFlightRecorder")
+	}
+	var b strings.Builder
+	s.m.DumpRecentCalls(&b)
+	return b.String(), nil
+}
+
+func (s *ControlServer) setLogLevel(args []string) (string, error) {
+	if len(args) != 1 {
+		return "", errors.New("usage: set-log-level debug|info|warn|error")
+	}
+	if s.m.logLevel == nil {
+		return "", errors.New("log level is not adjustable, see WithLogLevel")
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(args[0])); err != nil {
+		return "", err
+	}
+	s.m.logLevel.Set(level)
+	return "log level set to " + level.String(), nil
+}
@@ -0,0 +1,104 @@
+//go:build windows
+
+package proxdll
+
+import (
+	"syscall"
+
+	"golang.org/x/sys/windows"
+
+	"github.com/nilssoncreative/proxdll/winret"
+)
+
+// Convention is how a function reports failure. Given r1 and the thread's last error
+// after a call, it returns nil if the call succeeded, or the error it failed with.
+// The last error is stale after most successful calls, so it is only meaningful once
+// the result says the call failed.
+type Convention func(r1 uintptr, lastErr error) error
+
+// Conventions of common Win32 result types.
+var (
+	// ReturnsBool is for functions returning a BOOL, FALSE on failure with the reason
+	// in the last error.
+	ReturnsBool Convention = func(r1 uintptr, lastErr error) error {
+		// Only the low 32 bits of a BOOL are defined.
+		if uint32(r1) != 0 {
+			return nil
+		}
+		return failedErr(lastErr)
+	}
+	// ReturnsPointer is for functions returning a pointer, NULL on failure with the
+	// reason in the last error.
+	ReturnsPointer Convention = func(r1 uintptr, lastErr error) error {
+		if r1 != 0 {
+			return nil
+		}
+		return failedErr(lastErr)
+	}
+	// ReturnsHandle is for functions returning a HANDLE, NULL or INVALID_HANDLE_VALUE on
+	// failure with the reason in the last error.
+	ReturnsHandle Convention = func(r1 uintptr, lastErr error) error {
+		if r1 != 0 && windows.Handle(r1) != windows.InvalidHandle {
+			return nil
+		}
+		return failedErr(lastErr)
+	}
+	// ReturnsErrorCode is for functions returning a Win32 error code, such as the
+	// registry functions' LSTATUS, which is ERROR_SUCCESS on success.
+	ReturnsErrorCode Convention = func(r1 uintptr, _ error) error {
+		if uint32(r1) == 0 {
+			return nil
+		}
+		return syscall.Errno(uint32(r1))
+	}
+	// ReturnsHRESULT is for functions returning an HRESULT, which fails when negative.
+	ReturnsHRESULT Convention = func(r1 uintptr, _ error) error {
+		if hr := winret.HRESULTOf(r1); winret.Failed(hr) {
+			return hr
+		}
+		return nil
+	}
+	// ReturnsNTSTATUS is for functions returning an NTSTATUS, which fails when its
+	// severity is warning or error.
+	ReturnsNTSTATUS Convention = func(r1 uintptr, _ error) error {
+		if status := winret.NTSTATUSOf(r1); !winret.NTSuccess(status) {
+			return status
+		}
+		return nil
+	}
+)
+
+// failedErr returns lastErr for a call that failed, substituting a generic error for
+// ERROR_SUCCESS, which functions that fail without setting the last error leave behind.
+func failedErr(lastErr error) error {
+	if errno, ok := lastErr.(syscall.Errno); ok && errno == 0 {
+		return windows.ERROR_GEN_FAILURE
+	}
+	return lastErr
+}
+
+// CheckedCallOriginal invokes the original function like TryCallOriginal, but applies
+// the Convention of the Signature registered for funcName, so err is nil when the call
+// succeeded and holds the reason when it failed. Without a Convention, err is the last
+// error, or nil when it is zero, as for Bind. Errors reaching the original are returned
+// as by TryCallOriginal.
+func (m *Manager) CheckedCallOriginal(funcName string, args ...uintptr) (r1, r2 uintptr, err error) {
+	r1, r2, err = m.TryCallOriginal(funcName, args...)
+	return r1, r2, m.checkResult(funcName, r1, err)
+}
+
+// checkResult interprets err, the lastErr of a completed call to funcName, through its
+// Convention. Errors other than a syscall.Errno report failing to reach the original
+// and are returned unchanged.
+func (m *Manager) checkResult(funcName string, r1 uintptr, err error) error {
+	if err != nil && !isErrno(err) {
+		return err
+	}
+	if sig := m.Signature(funcName); sig != nil && sig.Convention != nil {
+		return sig.Convention(r1, err)
+	}
+	if errno, ok := err.(syscall.Errno); ok && errno == 0 {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,176 @@
+//go:build windows
+
+package proxdll
+
+import (
+	"fmt"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// Exception codes treated as crashes by the crash handler.
+var crashCodes = map[uint32]string{
+	0xC0000005: "EXCEPTION_ACCESS_VIOLATION",
+	0xC0000006: "EXCEPTION_IN_PAGE_ERROR",
+	0xC000001D: "EXCEPTION_ILLEGAL_INSTRUCTION",
+	0xC000008C: "EXCEPTION_ARRAY_BOUNDS_EXCEEDED",
+	0xC0000094: "EXCEPTION_INT_DIVIDE_BY_ZERO",
+	0xC0000096: "EXCEPTION_PRIV_INSTRUCTION",
+	0xC0000374: "STATUS_HEAP_CORRUPTION",
+	0xC0000409: "STATUS_STACK_BUFFER_OVERRUN",
+}
+
+// CrashReport describes a fatal exception raised while a proxied call was running: one
+// that no handler of the host or the original DLL handled, so the process is about to end.
+type CrashReport struct {
+	// Code is the exception code, such as 0xC0000005 for an access violation.
+	Code uint32
+	// CodeName is the symbolic name of Code.
+	CodeName string
+	// Address is the instruction address that raised the exception.
+	Address uintptr
+	// Module is the path of the module containing Address, if known.
+	Module string
+	// ThreadID identifies the faulting thread.
+	ThreadID uint32
+	// Func is the export that was running on the faulting thread.
+	Func string
+	// Recent lists the calls kept by the flight recorder, oldest first.
+	Recent []RecentCall
+	// Time is when the exception was raised.
+	Time time.Time
+	// DumpPath is the minidump written for the crash with WithCrashDumps, or empty.
+	DumpPath string
+}
+
+func (r *CrashReport) String() string {
+	return fmt.Sprintf("%s (%#x) at %#x in %s on thread %d during %s", r.CodeName, r.Code, r.Address, r.Module, r.ThreadID, r.Func)
+}
+
+// exceptionPointers mirrors EXCEPTION_POINTERS.
+type exceptionPointers struct {
+	record  *exceptionRecord
+	context uintptr
+}
+
+// exceptionRecord mirrors the head of EXCEPTION_RECORD.
+type exceptionRecord struct {
+	code    uint32
+	flags   uint32
+	next    *exceptionRecord
+	address uintptr
+}
+
+// crashHandler tracks in-flight calls and reports exceptions raised during them.
+type crashHandler struct {
+	m       *Manager
+	filter  uintptr
+	prev    uintptr
+	report  func(*CrashReport)
+	dumpDir string
+	mu      sync.Mutex
+	running map[uint32]string
+}
+
+// installCrashHandler installs an unhandled exception filter that passes crash reports to
+// report and then defers to the filter installed before it.
+func (m *Manager) installCrashHandler(report func(*CrashReport), dumpDir string) {
+	h := &crashHandler{m: m, report: report, dumpDir: dumpDir, running: make(map[uint32]string)}
+	h.filter = syscall.NewCallback(h.handle)
+	h.prev, _, _ = procSetUnhandledExceptionFilter.Call(h.filter)
+	m.crash = h
+}
+
+// remove restores the filter installed before the crash handler, unless another one has
+// replaced it since, which is left in place.
+func (h *crashHandler) remove() {
+	if current, _, _ := procSetUnhandledExceptionFilter.Call(h.prev); current != h.filter {
+		procSetUnhandledExceptionFilter.Call(current)
+	}
+}
+
+// enter records that funcName started on the calling thread and returns what was running before.
+func (h *crashHandler) enter(funcName string) (tid uint32, prev string) {
+	tid = windows.GetCurrentThreadId()
+	h.mu.Lock()
+	prev = h.running[tid]
+	h.running[tid] = funcName
+	h.mu.Unlock()
+	return tid, prev
+}
+
+// exit restores the call that was running on thread tid before enter.
+func (h *crashHandler) exit(tid uint32, prev string) {
+	h.mu.Lock()
+	if prev == "" {
+		delete(h.running, tid)
+	} else {
+		h.running[tid] = prev
+	}
+	h.mu.Unlock()
+}
+
+// handle is the unhandled exception filter, which Windows runs only for exceptions no
+// handler took care of. It only observes: the result of the previous filter, or the
+// default handling that ends the process, is returned unchanged.
+func (h *crashHandler) handle(ptrs *exceptionPointers) uintptr {
+	h.capture(ptrs)
+	if h.prev != 0 {
+		r, _, _ := syscall.SyscallN(h.prev, uintptr(unsafe.Pointer(ptrs)))
+		return r
+	}
+	return 0 // EXCEPTION_CONTINUE_SEARCH
+}
+
+// capture reports the exception described by ptrs if it was raised during a proxied call.
+func (h *crashHandler) capture(ptrs *exceptionPointers) {
+	rec := ptrs.record
+	name, fatal := crashCodes[rec.code]
+	if !fatal {
+		return
+	}
+
+	tid := windows.GetCurrentThreadId()
+	h.mu.Lock()
+	funcName, ok := h.running[tid]
+	if !ok {
+		h.mu.Unlock()
+		return
+	}
+	// Report each call at most once, however the exception is rethrown.
+	delete(h.running, tid)
+	h.mu.Unlock()
+
+	report := &CrashReport{
+		Code:     rec.code,
+		CodeName: name,
+		Address:  rec.address,
+		ThreadID: tid,
+		Func:     funcName,
+		Recent:   h.m.RecentCalls(),
+		Time:     time.Now(),
+	}
+	var module windows.Handle
+	flags := uint32(windows.GET_MODULE_HANDLE_EX_FLAG_FROM_ADDRESS | windows.GET_MODULE_HANDLE_EX_FLAG_UNCHANGED_REFCOUNT)
+	if r, _, _ := procGetModuleHandleExW.Call(uintptr(flags), rec.address, uintptr(unsafe.Pointer(&module))); r != 0 {
+		report.Module, _ = modulePath(module)
+	}
+
+	if h.dumpDir != "" {
+		path := crashDumpPath(h.dumpDir, report.Time)
+		if err := h.m.writeMinidump(path, ptrs, report); err != nil {
+			h.m.logger.Error("failed to write crash dump", "error", err)
+		} else {
+			report.DumpPath = path
+		}
+	}
+
+	h.m.logger.Error("exception during proxied call", "report", report.String(), "recent", len(report.Recent), "dump", report.DumpPath)
+	if h.report != nil {
+		h.report(report)
+	}
+}
@@ -0,0 +1,212 @@
+//go:build windows
+
+package proxdll
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// Flag names one bit, or group of bits, in a flags argument.
+type Flag struct {
+	Value uintptr
+	Name  string
+}
+
+// Enum returns a Decoder that renders values by name, falling back to hex.
+func Enum(names map[uintptr]string) Decoder {
+	return func(v uintptr) string {
+		if name, ok := names[v]; ok {
+			return name
+		}
+		return Hex(v)
+	}
+}
+
+// Flags returns a Decoder that renders a bit mask as NAME|NAME, with any bits not
+// covered by flags appended in hex. Flags are matched in order, so list multi-bit
+// values before the single bits they contain.
+func Flags(flags []Flag) Decoder {
+	return func(v uintptr) string {
+		if v == 0 {
+			return "0"
+		}
+		var parts []string
+		rest := v
+		for _, f := range flags {
+			if f.Value != 0 && rest&f.Value == f.Value {
+				parts = append(parts, f.Name)
+				rest &^= f.Value
+			}
+		}
+		if rest != 0 {
+			parts = append(parts, Hex(rest))
+		}
+		return strings.Join(parts, "|")
+	}
+}
+
+// Decoders for flag arguments of the file APIs.
+var (
+	// AccessMask decodes ACCESS_MASK arguments such as dwDesiredAccess.
+	AccessMask = Flags([]Flag{
+		{windows.GENERIC_ALL, "GENERIC_ALL"},
+		{windows.GENERIC_READ, "GENERIC_READ"},
+		{windows.GENERIC_WRITE, "GENERIC_WRITE"},
+		{windows.GENERIC_EXECUTE, "GENERIC_EXECUTE"},
+		{windows.MAXIMUM_ALLOWED, "MAXIMUM_ALLOWED"},
+		{windows.ACCESS_SYSTEM_SECURITY, "ACCESS_SYSTEM_SECURITY"},
+		{windows.SYNCHRONIZE, "SYNCHRONIZE"},
+		{windows.WRITE_OWNER, "WRITE_OWNER"},
+		{windows.WRITE_DAC, "WRITE_DAC"},
+		{windows.READ_CONTROL, "READ_CONTROL"},
+		{windows.DELETE, "DELETE"},
+	})
+
+	// ShareMode decodes the dwShareMode argument of CreateFile.
+	ShareMode = Flags([]Flag{
+		{windows.FILE_SHARE_READ, "FILE_SHARE_READ"},
+		{windows.FILE_SHARE_WRITE, "FILE_SHARE_WRITE"},
+		{windows.FILE_SHARE_DELETE, "FILE_SHARE_DELETE"},
+	})
+
+	// CreationDisposition decodes the dwCreationDisposition argument of CreateFile.
+	CreationDisposition = Enum(map[uintptr]string{
+		windows.CREATE_NEW:        "CREATE_NEW",
+		windows.CREATE_ALWAYS:     "CREATE_ALWAYS",
+		windows.OPEN_EXISTING:     "OPEN_EXISTING",
+		windows.OPEN_ALWAYS:       "OPEN_ALWAYS",
+		windows.TRUNCATE_EXISTING: "TRUNCATE_EXISTING",
+	})
+
+	// FileAttributes decodes FILE_ATTRIBUTE_* and FILE_FLAG_* arguments.
+	FileAttributes = Flags([]Flag{
+		{windows.FILE_ATTRIBUTE_READONLY, "FILE_ATTRIBUTE_READONLY"},
+		{windows.FILE_ATTRIBUTE_HIDDEN, "FILE_ATTRIBUTE_HIDDEN"},
+		{windows.FILE_ATTRIBUTE_SYSTEM, "FILE_ATTRIBUTE_SYSTEM"},
+		{windows.FILE_ATTRIBUTE_DIRECTORY, "FILE_ATTRIBUTE_DIRECTORY"},
+		{windows.FILE_ATTRIBUTE_ARCHIVE, "FILE_ATTRIBUTE_ARCHIVE"},
+		{windows.FILE_ATTRIBUTE_NORMAL, "FILE_ATTRIBUTE_NORMAL"},
+		{windows.FILE_ATTRIBUTE_TEMPORARY, "FILE_ATTRIBUTE_TEMPORARY"},
+		{windows.FILE_FLAG_WRITE_THROUGH, "FILE_FLAG_WRITE_THROUGH"},
+		{windows.FILE_FLAG_OVERLAPPED, "FILE_FLAG_OVERLAPPED"},
+		{windows.FILE_FLAG_NO_BUFFERING, "FILE_FLAG_NO_BUFFERING"},
+		{windows.FILE_FLAG_RANDOM_ACCESS, "FILE_FLAG_RANDOM_ACCESS"},
+		{windows.FILE_FLAG_SEQUENTIAL_SCAN, "FILE_FLAG_SEQUENTIAL_SCAN"},
+		{windows.FILE_FLAG_DELETE_ON_CLOSE, "FILE_FLAG_DELETE_ON_CLOSE"},
+		{windows.FILE_FLAG_BACKUP_SEMANTICS, "FILE_FLAG_BACKUP_SEMANTICS"},
+		{windows.FILE_FLAG_POSIX_SEMANTICS, "FILE_FLAG_POSIX_SEMANTICS"},
+		{windows.FILE_FLAG_OPEN_REPARSE_POINT, "FILE_FLAG_OPEN_REPARSE_POINT"},
+	})
+
+	// PageProtection decodes PAGE_* memory protection arguments.
+	PageProtection = Flags([]Flag{
+		{windows.PAGE_NOACCESS, "PAGE_NOACCESS"},
+		{windows.PAGE_READONLY, "PAGE_READONLY"},
+		{windows.PAGE_READWRITE, "PAGE_READWRITE"},
+		{windows.PAGE_WRITECOPY, "PAGE_WRITECOPY"},
+		{windows.PAGE_EXECUTE, "PAGE_EXECUTE"},
+		{windows.PAGE_EXECUTE_READ, "PAGE_EXECUTE_READ"},
+		{windows.PAGE_EXECUTE_READWRITE, "PAGE_EXECUTE_READWRITE"},
+		{windows.PAGE_EXECUTE_WRITECOPY, "PAGE_EXECUTE_WRITECOPY"},
+		{windows.PAGE_GUARD, "PAGE_GUARD"},
+		{windows.PAGE_NOCACHE, "PAGE_NOCACHE"},
+		{windows.PAGE_WRITECOMBINE, "PAGE_WRITECOMBINE"},
+	})
+)
+
+// RectPtr decodes a pointer to a RECT.
+func RectPtr(v uintptr) string {
+	var buf [16]byte
+	if ReadMemory(v, buf[:]) != nil {
+		return unreadableOrNull(v)
+	}
+	return fmt.Sprintf("{left=%d, top=%d, right=%d, bottom=%d}",
+		int32(binary.LittleEndian.Uint32(buf[0:])), int32(binary.LittleEndian.Uint32(buf[4:])),
+		int32(binary.LittleEndian.Uint32(buf[8:])), int32(binary.LittleEndian.Uint32(buf[12:])))
+}
+
+// PointPtr decodes a pointer to a POINT.
+func PointPtr(v uintptr) string {
+	var buf [8]byte
+	if ReadMemory(v, buf[:]) != nil {
+		return unreadableOrNull(v)
+	}
+	return fmt.Sprintf("{x=%d, y=%d}", int32(binary.LittleEndian.Uint32(buf[0:])), int32(binary.LittleEndian.Uint32(buf[4:])))
+}
+
+// Point decodes a POINT passed by value, which the x64 calling convention packs into one register.
+func Point(v uintptr) string {
+	u := uint64(v)
+	return fmt.Sprintf("{x=%d, y=%d}", int32(u), int32(u>>32))
+}
+
+// GUIDPtr decodes a pointer to a GUID, such as REFIID and REFCLSID arguments, with
+// the name registered for it by RegisterGUIDName.
+func GUIDPtr(v uintptr) string {
+	var buf [16]byte
+	if ReadMemory(v, buf[:]) != nil {
+		return unreadableOrNull(v)
+	}
+	g := windows.GUID{
+		Data1: binary.LittleEndian.Uint32(buf[0:]),
+		Data2: binary.LittleEndian.Uint16(buf[4:]),
+		Data3: binary.LittleEndian.Uint16(buf[6:]),
+	}
+	copy(g.Data4[:], buf[8:])
+	return formatGUID(g)
+}
+
+// FileTimePtr decodes a pointer to a FILETIME as a UTC timestamp.
+func FileTimePtr(v uintptr) string {
+	var buf [8]byte
+	if ReadMemory(v, buf[:]) != nil {
+		return unreadableOrNull(v)
+	}
+	ft := windows.Filetime{
+		LowDateTime:  binary.LittleEndian.Uint32(buf[0:]),
+		HighDateTime: binary.LittleEndian.Uint32(buf[4:]),
+	}
+	if ft == (windows.Filetime{}) {
+		return "{0}"
+	}
+	return time.Unix(0, ft.Nanoseconds()).UTC().Format(time.RFC3339Nano)
+}
+
+// LargeIntegerPtr decodes a pointer to a LARGE_INTEGER.
+func LargeIntegerPtr(v uintptr) string {
+	var buf [8]byte
+	if ReadMemory(v, buf[:]) != nil {
+		return unreadableOrNull(v)
+	}
+	return fmt.Sprint(int64(binary.LittleEndian.Uint64(buf[:])))
+}
+
+// LargeInteger decodes a LARGE_INTEGER passed by value, on 64-bit hosts.
+func LargeInteger(v uintptr) string {
+	return fmt.Sprint(int64(v))
+}
+
+// SecurityAttributesPtr decodes a pointer to SECURITY_ATTRIBUTES.
+func SecurityAttributesPtr(v uintptr) string {
+	// Decode from raw bytes, since copying a foreign pointer into a Go pointer field is unsafe.
+	var sa windows.SecurityAttributes
+	buf := make([]byte, unsafe.Sizeof(sa))
+	if ReadMemory(v, buf) != nil {
+		return unreadableOrNull(v)
+	}
+	length := binary.LittleEndian.Uint32(buf[unsafe.Offsetof(sa.Length):])
+	inherit := binary.LittleEndian.Uint32(buf[unsafe.Offsetof(sa.InheritHandle):])
+	var sd uintptr
+	if unsafe.Sizeof(sd) == 8 {
+		sd = uintptr(binary.LittleEndian.Uint64(buf[unsafe.Offsetof(sa.SecurityDescriptor):]))
+	} else {
+		sd = uintptr(binary.LittleEndian.Uint32(buf[unsafe.Offsetof(sa.SecurityDescriptor):]))
+	}
+	return fmt.Sprintf("{nLength=%d, lpSecurityDescriptor=%s, bInheritHandle=%s}", length, Pointer(sd), Bool(uintptr(inherit)))
+}
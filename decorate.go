@@ -0,0 +1,118 @@
+//go:build windows
+
+package proxdll
+
+import (
+	"encoding/binary"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+
+	"github.com/nilssoncreative/proxdll/pefile"
+)
+
+// undecorate strips the decoration 32-bit compilers give stdcall and fastcall functions,
+// returning "Name" for "_Name@8", "Name@8" and "@Name@8".
+func undecorate(name string) (string, bool) {
+	rest := strings.TrimPrefix(name, "@")
+	if rest == name {
+		rest = strings.TrimPrefix(name, "_")
+	}
+	base, size, ok := strings.Cut(rest, "@")
+	if !ok || base == "" || strings.ContainsAny(base, "?@") {
+		return "", false
+	}
+	if n, err := strconv.Atoi(size); err != nil || n < 0 || n%4 != 0 {
+		return "", false
+	}
+	return base, true
+}
+
+// decoratedAlternative returns the export of table that funcName refers to when it is
+// not exported under that exact name: the undecorated export for a decorated name, or
+// the single decorated export for an undecorated one. Callers can so use either form,
+// whichever way the original was built.
+func decoratedAlternative(table *pefile.ExportTable, funcName string) (string, bool) {
+	if base, ok := undecorate(funcName); ok {
+		if _, found := table.Lookup(base); found {
+			return base, true
+		}
+		return "", false
+	}
+
+	var match string
+	for _, exp := range table.Exports {
+		if base, ok := undecorate(exp.Name); ok && base == funcName {
+			if match != "" && match != exp.Name {
+				// Ambiguous, such as overloads with different argument sizes.
+				return "", false
+			}
+			match = exp.Name
+		}
+	}
+	return match, match != ""
+}
+
+// fastcallAdapterSize is the size of one adapter, padded for alignment.
+const fastcallAdapterSize = 16
+
+// fastcallAdapters holds the adapters made so far, by target address. They live in pages
+// that are never freed, as a Proc may be used after Reload or Free.
+var fastcallAdapters struct {
+	mu      sync.Mutex
+	byAddr  map[uintptr]uintptr
+	page    uintptr
+	used    uintptr
+	pageLen uintptr
+}
+
+// callable returns the address the Manager calls for the export named name at addr.
+// syscall.SyscallN passes every argument on the stack, so 32-bit fastcall exports,
+// decorated as @Name@N, are called through an adapter that moves the first two into
+// ECX and EDX. If no adapter can be made, addr is returned unchanged.
+func callable(name string, addr uintptr) uintptr {
+	if runtime.GOARCH != "386" || !strings.HasPrefix(name, "@") {
+		return addr
+	}
+	if _, ok := undecorate(name); !ok {
+		return addr
+	}
+
+	a := &fastcallAdapters
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if adapter, ok := a.byAddr[addr]; ok {
+		return adapter
+	}
+	if a.page == 0 || a.used+fastcallAdapterSize > a.pageLen {
+		page, err := windows.VirtualAlloc(0, 4096, windows.MEM_RESERVE|windows.MEM_COMMIT, windows.PAGE_READWRITE)
+		if err != nil {
+			return addr
+		}
+		a.page, a.used, a.pageLen = page, 0, 4096
+		if a.byAddr == nil {
+			a.byAddr = make(map[uintptr]uintptr)
+		}
+	}
+
+	// pop eax; pop ecx; pop edx; push eax; mov eax, addr; jmp eax
+	code := []byte{0x58, 0x59, 0x5a, 0x50, 0xb8, 0, 0, 0, 0, 0xff, 0xe0}
+	binary.LittleEndian.PutUint32(code[5:], uint32(addr))
+	adapter := a.page + a.used
+	var old uint32
+	if err := windows.VirtualProtect(a.page, a.pageLen, windows.PAGE_READWRITE, &old); err != nil {
+		return addr
+	}
+	copy(unsafe.Slice((*byte)(unsafe.Add(unsafe.Pointer(nil), adapter)), len(code)), code)
+	if err := windows.VirtualProtect(a.page, a.pageLen, windows.PAGE_EXECUTE_READ, &old); err != nil {
+		return addr
+	}
+	procFlushInstructionCache.Call(uintptr(windows.CurrentProcess()), adapter, uintptr(len(code)))
+	a.used += fastcallAdapterSize
+	a.byAddr[addr] = adapter
+	return adapter
+}
@@ -0,0 +1,53 @@
+//go:build windows
+
+package proxdll
+
+import (
+	"strings"
+	"sync"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// undecorated caches the results of Undecorate, by mangled name.
+var undecorated sync.Map
+
+// Undecorate returns the C++ declaration an MSVC-mangled export name such as
+// "?Add@Math@@QAEHH@Z" stands for, "public: int __thiscall Math::Add(int)", as
+// UnDecorateSymbolName renders it. Other names, and names dbghelp cannot undecorate,
+// are returned unchanged.
+func Undecorate(name string) string {
+	if !strings.HasPrefix(name, "?") {
+		return name
+	}
+	if v, ok := undecorated.Load(name); ok {
+		return v.(string)
+	}
+	in, err := windows.UTF16PtrFromString(name)
+	if err != nil {
+		return name
+	}
+
+	out := make([]uint16, maxSymbolName)
+	// dbghelp is not thread-safe, so this shares the symbolizer's lock.
+	symbols.mu.Lock()
+	n, _, _ := procUnDecorateSymbolNameW.Call(uintptr(unsafe.Pointer(in)), uintptr(unsafe.Pointer(&out[0])), uintptr(len(out)), 0) // UNDNAME_COMPLETE
+	symbols.mu.Unlock()
+
+	result := name
+	if n != 0 {
+		result = windows.UTF16ToString(out[:n])
+	}
+	undecorated.Store(name, result)
+	return result
+}
+
+// DisplayName returns the name tracers show for the call: Undecorated if it is set,
+// otherwise Func.
+func (ev *Event) DisplayName() string {
+	if ev.Undecorated != "" {
+		return ev.Undecorated
+	}
+	return ev.Func
+}
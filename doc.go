@@ -0,0 +1,6 @@
+// Package proxdll provides a generic framework for creating proxy DLLs.
+//
+// The package is Windows-only, apart from the types hooks are written against, such as
+// Call, Hook and Caller, which build everywhere so hook logic can be unit-tested with
+// package proxytest on any platform.
+package proxdll
@@ -0,0 +1,87 @@
+//go:build windows
+
+package proxdll
+
+import (
+	"os"
+	"strings"
+)
+
+// Environment variables read by WithEnvOverrides.
+const (
+	// EnvLog overrides the config's LogLevel, for example PROXDLL_LOG=debug.
+	EnvLog = "PROXDLL_LOG"
+	// EnvOriginalPath replaces the path given to New, bypassing any Resolver.
+	EnvOriginalPath = "PROXDLL_ORIGINAL_PATH"
+	// EnvConfig names the config file to load instead of the one from WithConfigFile or WithAutoConfig.
+	EnvConfig = "PROXDLL_CONFIG"
+	// EnvTraceFuncs is a comma-separated list of exports to trace, replacing the config's Trace.
+	// "*" traces every export.
+	EnvTraceFuncs = "PROXDLL_TRACE_FUNCS"
+	// EnvDenyFuncs is a comma-separated list of exports to deny, replacing the config's Deny.
+	EnvDenyFuncs = "PROXDLL_DENY_FUNCS"
+)
+
+// envSettings holds the overrides read from the environment when the Manager was created.
+type envSettings struct {
+	logLevel   string
+	traceFuncs []string
+	denyFuncs  []string
+}
+
+// readEnv reads the overrides and applies those that change how m is constructed.
+func (m *Manager) readEnv() {
+	if path := os.Getenv(EnvOriginalPath); path != "" {
+		m.path = path
+		m.resolver = nil
+	}
+	if path := os.Getenv(EnvConfig); path != "" {
+		m.configSource = func() (*Config, error) { return LoadConfig(path) }
+		m.configFiles = func() ([]string, error) { return []string{path}, nil }
+	}
+	m.env = &envSettings{
+		logLevel:   os.Getenv(EnvLog),
+		traceFuncs: splitList(os.Getenv(EnvTraceFuncs)),
+		denyFuncs:  splitList(os.Getenv(EnvDenyFuncs)),
+	}
+}
+
+// overrideConfig returns cfg with the environment overrides applied, leaving cfg unchanged.
+// Traced exports are sent to DebugTracer if no config sink is declared, so setting
+// PROXDLL_TRACE_FUNCS alone is enough to see calls in a debugger.
+func (m *Manager) overrideConfig(cfg *Config) *Config {
+	env := m.env
+	if env == nil || (env.logLevel == "" && env.traceFuncs == nil && env.denyFuncs == nil) {
+		return cfg
+	}
+
+	var out Config
+	if cfg != nil {
+		out = *cfg
+	}
+	if env.logLevel != "" {
+		out.LogLevel = env.logLevel
+	}
+	if env.traceFuncs != nil {
+		out.Trace = env.traceFuncs
+		if len(out.Sinks) == 0 {
+			out.Sinks = []SinkConfig{{Type: "debug"}}
+		}
+	}
+	if env.denyFuncs != nil {
+		out.Deny = env.denyFuncs
+	}
+	return &out
+}
+
+// splitList splits a comma-separated list, dropping empty entries.
+// It returns nil for an empty list.
+func splitList(s string) []string {
+	var out []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
@@ -0,0 +1,67 @@
+package proxdll
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Error categories, matched with errors.Is against the errors of the Manager.
+var (
+	// ErrManagerClosed matches the errors of calls made once the Manager is closed,
+	// ErrFreed and ErrShutdown.
+	ErrManagerClosed = errors.New("proxdll: manager is closed")
+	// ErrOriginalNotFound matches failures to load an original DLL that does not exist,
+	// or one of whose dependencies does not.
+	ErrOriginalNotFound = errors.New("proxdll: original DLL not found")
+	// ErrProcNotFound matches failures to find exports of the original DLL, including
+	// every *ProcNotFoundError.
+	ErrProcNotFound = errors.New("proxdll: export not found")
+	// ErrDataExport is returned when calling an export that is a variable rather than a
+	// function; DataExport returns its address instead.
+	ErrDataExport = errors.New("proxdll: export is data, not a function")
+)
+
+// ProcNotFoundError reports an export that could not be found in the original DLL.
+type ProcNotFoundError struct {
+	// Name is the export requested.
+	Name string
+	// Target is the export looked up for Name, which differs from Name for aliases.
+	Target string
+	// Err is the reason the export was not found.
+	Err error
+}
+
+func (e *ProcNotFoundError) Error() string {
+	if e.Target != e.Name {
+		return fmt.Sprintf("could not find function %s, aliased to %s, in original DLL: %v", e.Name, e.Target, e.Err)
+	}
+	return fmt.Sprintf("could not find function %s in original DLL: %v", e.Name, e.Err)
+}
+
+func (e *ProcNotFoundError) Unwrap() error {
+	return e.Err
+}
+
+// Is makes every ProcNotFoundError match ErrProcNotFound.
+func (e *ProcNotFoundError) Is(target error) bool {
+	return target == ErrProcNotFound
+}
+
+// categoryError places err in a category matched by errors.Is, keeping its message.
+type categoryError struct {
+	error
+	category error
+}
+
+func (e *categoryError) Unwrap() error {
+	return e.error
+}
+
+func (e *categoryError) Is(target error) bool {
+	return target == e.category
+}
+
+// withCategory returns err in category.
+func withCategory(err, category error) error {
+	return &categoryError{error: err, category: category}
+}
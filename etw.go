@@ -0,0 +1,255 @@
+//go:build windows
+
+package proxdll
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"unicode/utf16"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// ETW constants used by ETWTracer.
+const (
+	etwLevelInfo              = 4
+	etwOpcodeStart            = 1
+	etwOpcodeStop             = 2
+	etwChannelTraceLogging    = 11
+	etwProviderSetTraits      = 2
+	etwDataEventMetadata      = 1
+	etwDataProviderMetadata   = 2
+	tlgInANSISTRING           = 2
+	tlgInUINT32               = 8
+	tlgInUINT64               = 10
+	tlgInHEXINT64             = 21
+	etwProviderNamespaceBytes = "\x48\x2C\x2D\xB2\xC3\x90\x47\xC8\x87\xF8\x1A\x15\xBF\xC1\x30\xFB"
+)
+
+// eventDescriptor mirrors EVENT_DESCRIPTOR.
+type eventDescriptor struct {
+	ID      uint16
+	Version uint8
+	Channel uint8
+	Level   uint8
+	Opcode  uint8
+	Task    uint16
+	Keyword uint64
+}
+
+// eventDataDescriptor mirrors EVENT_DATA_DESCRIPTOR.
+type eventDataDescriptor struct {
+	Ptr  uint64
+	Size uint32
+	Type uint32
+}
+
+// ETWTracer emits a TraceLogging start and stop event for every call through a user-mode
+// ETW provider, so proxy activity appears in WPR, xperf and WPA captures next to the rest
+// of the system. Events are self-describing and need no manifest; when no session has
+// enabled the provider, tracing costs a single check per call.
+type ETWTracer struct {
+	handle    atomic.Uint64
+	name      string
+	guid      windows.GUID
+	traits    []byte
+	startMeta []byte
+	stopMeta  []byte
+	once      sync.Once
+}
+
+// NewETWTracer registers a TraceLogging provider with the given name.
+// The provider GUID is derived from the name like EventSource and TraceLogging do,
+// so sessions can enable it as "*name"; see ETWTracer.GUID.
+func NewETWTracer(name string) (*ETWTracer, error) {
+	t := &ETWTracer{
+		name: name,
+		guid: etwProviderGUID(name),
+	}
+	t.traits = etwTraits(name)
+	t.startMeta = etwEventMetadata("CallStart", []etwField{
+		{"Func", tlgInANSISTRING},
+		{"Args", tlgInANSISTRING},
+	})
+	t.stopMeta = etwEventMetadata("CallStop", []etwField{
+		{"Func", tlgInANSISTRING},
+		{"R1", tlgInHEXINT64},
+		{"R2", tlgInHEXINT64},
+		{"LastErr", tlgInUINT32},
+		{"DurationNs", tlgInUINT64},
+	})
+
+	var handle uint64
+	r0, _, _ := procEventRegister.Call(uintptr(unsafe.Pointer(&t.guid)), 0, 0, uintptr(unsafe.Pointer(&handle)))
+	if r0 != 0 {
+		return nil, fmt.Errorf("failed to register ETW provider %s: %w", name, windows.Errno(r0))
+	}
+	t.handle.Store(handle)
+	procEventSetInformation.Call(etwHandleArgs(handle, uintptr(etwProviderSetTraits), uintptr(unsafe.Pointer(&t.traits[0])), uintptr(len(t.traits)))...)
+
+	return t, nil
+}
+
+// GUID returns the provider GUID.
+func (t *ETWTracer) GUID() windows.GUID {
+	return t.guid
+}
+
+// TraceStart emits a CallStart event.
+func (t *ETWTracer) TraceStart(ev *Event) {
+	handle := t.handle.Load()
+	if !etwEnabled(handle) {
+		return
+	}
+	t.write(handle, etwOpcodeStart, t.startMeta, cstring(ev.Func), cstring(formatArgs(ev.Args)))
+}
+
+// Trace emits a CallStop event.
+func (t *ETWTracer) Trace(ev *Event) {
+	handle := t.handle.Load()
+	if !etwEnabled(handle) {
+		return
+	}
+	var errno windows.Errno
+	if e, ok := ev.LastErr.(windows.Errno); ok {
+		errno = e
+	}
+	t.write(handle, etwOpcodeStop, t.stopMeta,
+		cstring(ev.Func),
+		binary.LittleEndian.AppendUint64(nil, uint64(ev.R1)),
+		binary.LittleEndian.AppendUint64(nil, uint64(ev.R2)),
+		binary.LittleEndian.AppendUint32(nil, uint32(errno)),
+		binary.LittleEndian.AppendUint64(nil, uint64(ev.Duration.Nanoseconds())),
+	)
+}
+
+// Close unregisters the provider. Events traced after Close are dropped.
+func (t *ETWTracer) Close() error {
+	var err error
+	t.once.Do(func() {
+		handle := t.handle.Swap(0)
+		r0, _, _ := procEventUnregister.Call(etwHandleArgs(handle)...)
+		if r0 != 0 {
+			err = fmt.Errorf("failed to unregister ETW provider %s: %w", t.name, windows.Errno(r0))
+		}
+	})
+	return err
+}
+
+// etwEnabled reports whether any session is listening to handle at the informational level.
+func etwEnabled(handle uint64) bool {
+	if handle == 0 {
+		return false
+	}
+	args := etwHandleArgs(handle, etwLevelInfo)
+	args = append(args, etwUint64Args(0)...)
+	r0, _, _ := procEventProviderEnabled.Call(args...)
+	return byte(r0) != 0
+}
+
+// write emits one event with the given opcode, metadata and field payloads.
+func (t *ETWTracer) write(handle uint64, opcode uint8, meta []byte, fields ...[]byte) {
+	// The data descriptors carry addresses as integers, so everything they point to
+	// is pinned for the duration of the call.
+	var pinner runtime.Pinner
+	defer pinner.Unpin()
+	addr := func(b []byte) uint64 {
+		pinner.Pin(&b[0])
+		return uint64(uintptr(unsafe.Pointer(&b[0])))
+	}
+
+	desc := &eventDescriptor{
+		Channel: etwChannelTraceLogging,
+		Level:   etwLevelInfo,
+		Opcode:  opcode,
+	}
+	data := make([]eventDataDescriptor, 0, 2+len(fields))
+	data = append(data,
+		eventDataDescriptor{Ptr: addr(t.traits), Size: uint32(len(t.traits)), Type: etwDataProviderMetadata},
+		eventDataDescriptor{Ptr: addr(meta), Size: uint32(len(meta)), Type: etwDataEventMetadata},
+	)
+	for _, f := range fields {
+		data = append(data, eventDataDescriptor{Ptr: addr(f), Size: uint32(len(f))})
+	}
+	pinner.Pin(desc)
+	pinner.Pin(&data[0])
+
+	args := etwHandleArgs(handle, uintptr(unsafe.Pointer(desc)), 0, 0, uintptr(len(data)), uintptr(unsafe.Pointer(&data[0])))
+	procEventWriteTransfer.Call(args...)
+}
+
+// etwHandleArgs prepends a REGHANDLE to args, splitting it into two words on 32-bit platforms.
+func etwHandleArgs(handle uint64, args ...uintptr) []uintptr {
+	return append(etwUint64Args(handle), args...)
+}
+
+// etwUint64Args returns the call arguments for a 64-bit value.
+func etwUint64Args(v uint64) []uintptr {
+	if unsafe.Sizeof(uintptr(0)) == 8 {
+		return []uintptr{uintptr(v)}
+	}
+	return []uintptr{uintptr(uint32(v)), uintptr(v >> 32)}
+}
+
+// etwField describes a TraceLogging event field.
+type etwField struct {
+	name   string
+	inType uint8
+}
+
+// etwTraits encodes TraceLogging provider traits: a size prefix followed by the name.
+func etwTraits(name string) []byte {
+	var b bytes.Buffer
+	b.Write([]byte{0, 0})
+	b.WriteString(name)
+	b.WriteByte(0)
+	out := b.Bytes()
+	binary.LittleEndian.PutUint16(out, uint16(len(out)))
+	return out
+}
+
+// etwEventMetadata encodes TraceLogging event metadata: size, tags, name and field types.
+func etwEventMetadata(name string, fields []etwField) []byte {
+	var b bytes.Buffer
+	b.Write([]byte{0, 0, 0})
+	b.WriteString(name)
+	b.WriteByte(0)
+	for _, f := range fields {
+		b.WriteString(f.name)
+		b.WriteByte(0)
+		b.WriteByte(f.inType)
+	}
+	out := b.Bytes()
+	binary.LittleEndian.PutUint16(out, uint16(len(out)))
+	return out
+}
+
+// etwProviderGUID derives a provider GUID from its name with the EventSource algorithm.
+func etwProviderGUID(name string) windows.GUID {
+	h := sha1.New()
+	h.Write([]byte(etwProviderNamespaceBytes))
+	for _, c := range utf16.Encode([]rune(strings.ToUpper(name))) {
+		h.Write([]byte{byte(c >> 8), byte(c)})
+	}
+	sum := h.Sum(nil)
+	sum[7] = sum[7]&0x0F | 0x50
+
+	return windows.GUID{
+		Data1: binary.LittleEndian.Uint32(sum[0:4]),
+		Data2: binary.LittleEndian.Uint16(sum[4:6]),
+		Data3: binary.LittleEndian.Uint16(sum[6:8]),
+		Data4: [8]byte(sum[8:16]),
+	}
+}
+
+// cstring returns s as a NUL-terminated byte slice.
+func cstring(s string) []byte {
+	return append([]byte(s), 0)
+}
@@ -0,0 +1,138 @@
+//go:build windows
+
+package proxdll
+
+import (
+	"fmt"
+
+	"github.com/nilssoncreative/proxdll/pefile"
+	"golang.org/x/sys/windows"
+)
+
+// Export describes a single entry in the original DLL's export table.
+type Export = pefile.Export
+
+// ListExports returns every export of the original DLL, ordered by ordinal.
+// The table is parsed from the DLL file on disk and cached for later calls.
+func (m *Manager) ListExports() ([]Export, error) {
+	table, err := m.exportTable()
+	if err != nil {
+		return nil, err
+	}
+	return table.Exports, nil
+}
+
+// OrdinalOf returns the ordinal the original DLL exports funcName under, which a proxy
+// must export it under too for hosts importing by ordinal.
+func (m *Manager) OrdinalOf(funcName string) (uint16, error) {
+	table, err := m.exportTable()
+	if err != nil {
+		return 0, err
+	}
+	exp, ok := table.Lookup(funcName)
+	if !ok {
+		return 0, &ProcNotFoundError{Name: funcName, Target: funcName, Err: windows.ERROR_PROC_NOT_FOUND}
+	}
+	return exp.Ordinal, nil
+}
+
+// NamesOf returns the names the original DLL exports ordinal under: none for an export
+// by ordinal only, and more than one for a function exported under several names.
+func (m *Manager) NamesOf(ordinal uint16) ([]string, error) {
+	table, err := m.exportTable()
+	if err != nil {
+		return nil, err
+	}
+	names, ok := table.OrdinalNames()[ordinal]
+	if !ok {
+		name := ordinalName(ordinal)
+		return nil, &ProcNotFoundError{Name: name, Target: name, Err: windows.ERROR_PROC_NOT_FOUND}
+	}
+	return names, nil
+}
+
+// exportTable returns the cached export table, parsing it on first use.
+func (m *Manager) exportTable() (*pefile.ExportTable, error) {
+	m.mu.RLock()
+	table := m.exports
+	m.mu.RUnlock()
+
+	if table != nil {
+		return table, nil
+	}
+
+	dll, err := m.dll()
+	if err != nil {
+		return nil, err
+	}
+	table, err = readExportTable(dll)
+	if err != nil {
+		return nil, err
+	}
+
+	// Reload may have replaced the module while it was parsed, and the table of the old
+	// one must not be cached for the new. The table cached first wins.
+	m.mu.Lock()
+	switch {
+	case m.exports != nil:
+		table = m.exports
+	case m.originalDLL == dll:
+		m.exports = table
+	default:
+		m.mu.Unlock()
+		return m.exportTable()
+	}
+	m.mu.Unlock()
+
+	return table, nil
+}
+
+// readExportTable parses the export table of the file backing mod.
+// Modules mapped from memory provide the table themselves.
+func readExportTable(mod Module) (*pefile.ExportTable, error) {
+	if mm, ok := mod.(interface{ exportTable() *pefile.ExportTable }); ok {
+		return mm.exportTable(), nil
+	}
+	path, err := moduleFile(mod)
+	if err != nil {
+		return nil, err
+	}
+	table, err := pefile.OpenExports(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read export table of %s: %w", path, err)
+	}
+	return table, nil
+}
+
+// modulePath returns the full path of the file backing a loaded module.
+func modulePath(module windows.Handle) (string, error) {
+	buf := make([]uint16, windows.MAX_PATH)
+	for {
+		n, err := windows.GetModuleFileName(module, &buf[0], uint32(len(buf)))
+		if err != nil {
+			return "", fmt.Errorf("failed to get module file name: %w", err)
+		}
+		// A full buffer means the path was truncated.
+		if n < uint32(len(buf)) {
+			return windows.UTF16ToString(buf[:n]), nil
+		}
+		buf = make([]uint16, 2*len(buf))
+	}
+}
+
+// DataExport returns the address of the variable the original DLL exports as name,
+// following aliases and forwarders as GetOriginalFunc does. Unlike functions, data
+// exports are not cached, and addresses obtained before Reload point into the previous
+// original. Exports the export table does not mark as data are returned as well.
+func (m *Manager) DataExport(name string) (uintptr, error) {
+	dll, err := m.dll()
+	if err != nil {
+		return 0, err
+	}
+	target := m.originalName(name)
+	proc, _, err := m.resolve(dll, target)
+	if err != nil {
+		return 0, &ProcNotFoundError{Name: name, Target: target, Err: err}
+	}
+	return proc.addr, nil
+}
@@ -0,0 +1,45 @@
+//go:build windows
+
+package proxdll
+
+import (
+	"expvar"
+	"fmt"
+	"sync"
+)
+
+// expvarMu serializes PublishExpvar calls.
+var expvarMu sync.Mutex
+
+// PublishExpvar publishes the Manager's state as the expvar variable name, so it appears
+// in /debug/vars of any HTTP server using the expvar handler. The variable reports the
+// original DLL's path once loaded, the number of cached functions, the registered hooks
+// and, with WithStats, the per-export counters.
+func (m *Manager) PublishExpvar(name string) error {
+	// expvar.Publish panics on a duplicate, so the check and the publish must not race.
+	expvarMu.Lock()
+	defer expvarMu.Unlock()
+	if expvar.Get(name) != nil {
+		return fmt.Errorf("expvar %s is already published", name)
+	}
+	expvar.Publish(name, expvar.Func(m.expvarState))
+	return nil
+}
+
+// expvarState returns the value reported for the published variable.
+func (m *Manager) expvarState() any {
+	state := map[string]any{
+		"requested":   m.path,
+		"cachedProcs": m.procs.len(),
+	}
+	if dll := m.loadedDLL(); dll != nil {
+		state["path"] = dll.Name()
+	}
+
+	state["hooks"] = m.Hooks()
+
+	if stats := m.Stats(); stats != nil {
+		state["stats"] = stats
+	}
+	return state
+}
@@ -0,0 +1,53 @@
+//go:build windows
+
+package proxdll
+
+import "errors"
+
+// Fallback is the result an export returns when the original cannot be reached.
+type Fallback struct {
+	R1, R2  uintptr
+	LastErr error
+}
+
+// SetFallback registers the result funcName returns in place of failing outright, so a
+// proxy can degrade gracefully, such as by reporting a capability as absent. It is used
+// when the original cannot be reached, because the DLL fails to load, lacks the export,
+// or the Manager is freed or shut down, and when Recover catches a panic in the stub.
+// Failures reported by the original itself are returned as they are.
+func (m *Manager) SetFallback(funcName string, r1 uintptr, lastErr error) {
+	m.mu.Lock()
+	m.fallbacks[funcName] = Fallback{R1: r1, LastErr: lastErr}
+	m.mu.Unlock()
+}
+
+// Fallback returns the fallback registered for funcName, if any.
+func (m *Manager) Fallback(funcName string) (Fallback, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	fb, ok := m.fallbacks[funcName]
+	return fb, ok
+}
+
+// callOriginal forwards to the original like FastCallOriginal, returning funcName's
+// fallback if the original cannot be reached.
+func (m *Manager) callOriginal(funcName string, args ...uintptr) (r1, r2 uintptr, lastErr error) {
+	r1, r2, lastErr = m.FastCallOriginal(funcName, args...)
+	if lastErr != nil && !isErrno(lastErr) {
+		return m.fallback(funcName, r1, r2, lastErr)
+	}
+	return r1, r2, lastErr
+}
+
+// fallback returns funcName's fallback in place of the failure err, or the failure if
+// there is none.
+func (m *Manager) fallback(funcName string, r1, r2 uintptr, err error) (uintptr, uintptr, error) {
+	fb, ok := m.Fallback(funcName)
+	if !ok {
+		return r1, r2, err
+	}
+	if !errors.Is(err, ErrShutdown) {
+		m.logger.Debug("original unavailable, returning fallback", "func", funcName, "err", err)
+	}
+	return fb.R1, fb.R2, fb.LastErr
+}
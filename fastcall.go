@@ -0,0 +1,110 @@
+//go:build windows
+
+package proxdll
+
+import (
+	"maps"
+	"sync"
+	"sync/atomic"
+	"syscall"
+)
+
+// maxCachedErrnos bounds the number of distinct boxed errno values kept by errnoErr.
+const maxCachedErrnos = 1024
+
+// FastCallOriginal invokes the original function like TryCallOriginal, but does not allocate
+// once funcName has been resolved: the cache lookup takes no lock, the arguments are passed
+// straight to syscall.SyscallN, and lastErr values are boxed only the first time they occur.
+//
+// Unlike windows.Proc.Call, FastCallOriginal cannot keep Go memory referenced by its
+// arguments alive. Callers passing pointers to Go memory must keep it reachable, for
+// example with runtime.KeepAlive, until the call returns. Raw values received from the
+// host, as in proxy stubs, need no such care.
+func (m *Manager) FastCallOriginal(funcName string, args ...uintptr) (r1, r2 uintptr, lastErr error) {
+	gate, ok := m.enterCall()
+	if !ok {
+		return 0, 0, ErrFreed
+	}
+	proc, ok := m.procs.load(funcName)
+	if !ok {
+		var err error
+		if proc, err = m.GetOriginalFunc(funcName); err != nil {
+			gate.exit()
+			return 0, 0, err
+		}
+	}
+
+	r1, r2, errno := syscall.SyscallN(proc.Addr(), args...)
+	gate.exit()
+	return r1, r2, errnoErr(errno)
+}
+
+// RawCallOriginal invokes the original function like FastCallOriginal, but leaves the
+// thread's last error alone: it is neither boxed nor returned, which suits functions that
+// report failures through r1 only, such as those returning an HRESULT. err reports only
+// lookup failures and ErrFreed.
+func (m *Manager) RawCallOriginal(funcName string, args ...uintptr) (r1, r2 uintptr, err error) {
+	gate, ok := m.enterCall()
+	if !ok {
+		return 0, 0, ErrFreed
+	}
+	proc, ok := m.procs.load(funcName)
+	if !ok {
+		if proc, err = m.GetOriginalFunc(funcName); err != nil {
+			gate.exit()
+			return 0, 0, err
+		}
+	}
+
+	r1, r2, _ = syscall.SyscallN(proc.Addr(), args...)
+	gate.exit()
+	return r1, r2, nil
+}
+
+// OriginalAddr returns the address of the original function, resolving and caching it like
+// GetOriginalFunc, for callers invoking it through syscall.SyscallN themselves. Such calls
+// are not tracked, so the address must not be used once Free or Reload release the original;
+// WithPin rules that out.
+func (m *Manager) OriginalAddr(funcName string) (uintptr, error) {
+	if proc, ok := m.procs.load(funcName); ok {
+		return proc.Addr(), nil
+	}
+	proc, err := m.GetOriginalFunc(funcName)
+	if err != nil {
+		return 0, err
+	}
+	return proc.Addr(), nil
+}
+
+var (
+	errnos   atomic.Pointer[map[syscall.Errno]error]
+	errnosMu sync.Mutex // serializes stores to errnos
+)
+
+// errnoErr returns e as an error without allocating for values seen before.
+func errnoErr(e syscall.Errno) error {
+	// The runtime boxes values below 256 without allocating.
+	if e < 256 {
+		return e
+	}
+	if cached := errnos.Load(); cached != nil {
+		if err, ok := (*cached)[e]; ok {
+			return err
+		}
+	}
+
+	errnosMu.Lock()
+	defer errnosMu.Unlock()
+
+	next := make(map[syscall.Errno]error)
+	if cached := errnos.Load(); cached != nil {
+		if len(*cached) >= maxCachedErrnos {
+			return e
+		}
+		maps.Copy(next, *cached)
+	}
+	var err error = e
+	next[e] = err
+	errnos.Store(&next)
+	return err
+}
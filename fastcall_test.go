@@ -0,0 +1,72 @@
+//go:build windows
+
+package proxdll
+
+import (
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/sys/windows"
+)
+
+// newKernel32 returns a Manager proxying kernel32.dll, whose exports exist on every
+// Windows version.
+func newKernel32(tb testing.TB) *Manager {
+	tb.Helper()
+	dir, err := windows.GetSystemDirectory()
+	if err != nil {
+		tb.Fatal(err)
+	}
+	m, err := New(filepath.Join(dir, "kernel32.dll"))
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { m.Free() })
+	// Resolve once, as the zero-allocation promise starts after the first call.
+	if _, _, err := m.FastCallOriginal("GetCurrentProcessId"); err != nil {
+		tb.Fatal(err)
+	}
+	return m
+}
+
+func TestFastCallOriginalAllocs(t *testing.T) {
+	m := newKernel32(t)
+	allocs := testing.AllocsPerRun(100, func() {
+		m.FastCallOriginal("GetCurrentProcessId")
+	})
+	if allocs != 0 {
+		t.Errorf("FastCallOriginal allocates %v times per call, want 0", allocs)
+	}
+}
+
+func TestCallAllocs(t *testing.T) {
+	m := newKernel32(t)
+	allocs := testing.AllocsPerRun(100, func() {
+		m.Call("GetCurrentProcessId")
+	})
+	if allocs != 0 {
+		t.Errorf("Call without hooks or tracers allocates %v times per call, want 0", allocs)
+	}
+}
+
+func BenchmarkFastCallOriginal(b *testing.B) {
+	m := newKernel32(b)
+	if allocs := testing.AllocsPerRun(100, func() { m.FastCallOriginal("GetCurrentProcessId") }); allocs != 0 {
+		b.Fatalf("FastCallOriginal allocates %v times per call, want 0", allocs)
+	}
+	b.ReportAllocs()
+	for b.Loop() {
+		m.FastCallOriginal("GetCurrentProcessId")
+	}
+}
+
+func BenchmarkCall(b *testing.B) {
+	m := newKernel32(b)
+	if allocs := testing.AllocsPerRun(100, func() { m.Call("GetCurrentProcessId") }); allocs != 0 {
+		b.Fatalf("Call allocates %v times per call, want 0", allocs)
+	}
+	b.ReportAllocs()
+	for b.Loop() {
+		m.Call("GetCurrentProcessId")
+	}
+}
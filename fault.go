@@ -0,0 +1,50 @@
+package proxdll
+
+import (
+	"math/rand/v2"
+	"sync/atomic"
+)
+
+// Fault describes failures to inject into calls of an export, to exercise a host's
+// error handling against an unreliable API. A call fails if any of the triggers fires.
+type Fault struct {
+	// Probability is the chance, from 0 to 1, that any one call fails.
+	Probability float64
+	// Nth fails only the Nth call, counting from 1. Zero disables the trigger.
+	Nth int
+	// Every fails every Every-th call. Zero disables the trigger.
+	Every int
+	// R1 and LastErr are the results returned by a failed call.
+	R1      uintptr
+	LastErr error
+}
+
+// InjectFault returns a Hook that makes calls fail as described by f, returning its
+// results without calling the original, and forwards every other call unchanged.
+func InjectFault(f Fault) Hook {
+	var calls atomic.Int64
+	return func(call *Call, next Next) (uintptr, uintptr, error) {
+		n := calls.Add(1)
+		if f.Nth > 0 && n == int64(f.Nth) ||
+			f.Every > 0 && n%int64(f.Every) == 0 ||
+			f.Probability > 0 && rand.Float64() < f.Probability {
+			return f.R1, 0, f.LastErr
+		}
+		return next()
+	}
+}
+
+// wrapHook returns a hook that runs outer around inner, or either alone if the other is nil.
+func wrapHook(outer, inner Hook) Hook {
+	if inner == nil {
+		return outer
+	}
+	if outer == nil {
+		return inner
+	}
+	return func(call *Call, next Next) (uintptr, uintptr, error) {
+		return outer(call, func() (uintptr, uintptr, error) {
+			return inner(call, next)
+		})
+	}
+}
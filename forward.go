@@ -0,0 +1,145 @@
+//go:build windows
+
+package proxdll
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/nilssoncreative/proxdll/pefile"
+)
+
+// maxForwardHops bounds how many forwarders are followed, guarding against cycles.
+const maxForwardHops = 16
+
+// ForwardChain returns the forwarder strings followed to resolve funcName, in order,
+// such as ["NTDLL.RtlFoo"]. It is empty if the original DLL implements the export itself.
+func (m *Manager) ForwardChain(funcName string) ([]string, error) {
+	if _, err := m.GetOriginalFunc(funcName); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	chain := m.forwards[funcName]
+	m.mu.RUnlock()
+
+	return slices.Clone(chain), nil
+}
+
+// resolve finds funcName in dll. Forwarded exports are followed explicitly through the
+// export tables of the target modules rather than left to GetProcAddress.
+func (m *Manager) resolve(dll Module, funcName string) (*Proc, []string, error) {
+	table, err := m.exportTable()
+	if err != nil {
+		m.logger.Debug("export table unavailable, resolving directly", "func", funcName, "error", err)
+	}
+	return m.resolveExport(dll, table, funcName)
+}
+
+// resolveExport finds funcName in dll, whose export table is table.
+// Without an export table, it defers to the loader's own forwarder handling.
+// Decorated and undecorated names of 32-bit exports find each other.
+func (m *Manager) resolveExport(dll Module, table *pefile.ExportTable, funcName string) (*Proc, []string, error) {
+	if table == nil {
+		proc, err := findProc(dll, funcName)
+		if base, ok := undecorate(funcName); err != nil && ok {
+			if proc, err := findProc(dll, base); err == nil {
+				return proc, nil, nil
+			}
+		}
+		return proc, nil, err
+	}
+
+	exp, ok := lookupExport(table, funcName)
+	if !ok {
+		if alt, found := decoratedAlternative(table, funcName); found {
+			m.logger.Debug("resolved export by its decorated name", "func", funcName, "export", alt)
+			funcName = alt
+			exp, ok = lookupExport(table, funcName)
+		}
+	}
+	if !ok || exp.Forwarder == "" {
+		proc, err := findProc(dll, funcName)
+		if err == nil {
+			proc.data = exp.Data
+		}
+		return proc, nil, err
+	}
+
+	var chain []string
+	for exp.Forwarder != "" {
+		if len(chain) == maxForwardHops {
+			return nil, chain, fmt.Errorf("forwarder chain for %s exceeds %d hops", funcName, maxForwardHops)
+		}
+		chain = append(chain, exp.Forwarder)
+
+		module, target, ok := strings.Cut(exp.Forwarder, ".")
+		if !ok {
+			return nil, chain, fmt.Errorf("malformed forwarder %q for %s", exp.Forwarder, funcName)
+		}
+		var err error
+		dll, err = m.loadForwardTarget(module)
+		if err != nil {
+			return nil, chain, err
+		}
+		path, err := moduleFile(dll)
+		if err != nil {
+			return nil, chain, err
+		}
+		table, err := pefile.OpenExports(path)
+		if err != nil {
+			return nil, chain, fmt.Errorf("failed to read export table of %s: %w", path, err)
+		}
+		exp, ok = lookupExport(table, target)
+		if !ok {
+			return nil, chain, fmt.Errorf("forwarded export %s of %s not found in %s", target, funcName, path)
+		}
+		funcName = target
+	}
+
+	m.logger.Debug("resolved forwarded export", "func", funcName, "chain", chain)
+	proc, err := findProc(dll, funcName)
+	if err == nil {
+		proc.data = exp.Data
+	}
+	return proc, chain, err
+}
+
+// loadForwardTarget loads a module named by a forwarder, keeping it loaded until Free.
+func (m *Manager) loadForwardTarget(module string) (Module, error) {
+	if filepath.Ext(module) == "" {
+		module += ".dll"
+	}
+	key := strings.ToLower(module)
+
+	m.mu.RLock()
+	dll, ok := m.forwardDLLs[key]
+	m.mu.RUnlock()
+	if ok {
+		return dll, nil
+	}
+
+	dll, err := m.loader.Load(module, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load forwarder target %s: %w", module, err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if existing, ok := m.forwardDLLs[key]; ok {
+		dll.Release()
+		return existing, nil
+	}
+	m.forwardDLLs[key] = dll
+	return dll, nil
+}
+
+// lookupExport finds funcName in table, treating names of the form "#N" as ordinals.
+func lookupExport(table *pefile.ExportTable, funcName string) (pefile.Export, bool) {
+	if ordinal, ok := parseOrdinal(funcName); ok {
+		return table.LookupOrdinal(ordinal)
+	}
+	return table.Lookup(funcName)
+}
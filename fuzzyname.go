@@ -0,0 +1,105 @@
+//go:build windows
+
+package proxdll
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/nilssoncreative/proxdll/pefile"
+)
+
+// selfExports is the export table of the proxy DLL, read once for fuzzy config names.
+var selfExports = sync.OnceValues(func() (*pefile.ExportTable, error) {
+	self, err := SelfPath()
+	if err != nil {
+		return nil, err
+	}
+	return pefile.OpenExports(self)
+})
+
+// fuzzyExport returns the export of table that name means: the one of that name, or
+// failing that the only one equal to it ignoring case, surrounding space and a trailing
+// decoration such as "@8". It reports false if there is none, or more than one.
+func fuzzyExport(table *pefile.ExportTable, name string) (string, bool) {
+	if _, ok := lookupExport(table, name); ok {
+		return name, true
+	}
+	want := fuzzyKey(name)
+	match := ""
+	for _, exp := range table.Exports {
+		if exp.Name == "" || fuzzyKey(exp.Name) != want {
+			continue
+		}
+		if match != "" && match != exp.Name {
+			return "", false
+		}
+		match = exp.Name
+	}
+	return match, match != ""
+}
+
+// fuzzyKey returns the form of name fuzzyExport compares.
+func fuzzyKey(name string) string {
+	name = strings.TrimSpace(name)
+	if base, ok := undecorate(name); ok {
+		name = base
+	}
+	return strings.ToLower(name)
+}
+
+// fuzzyNames returns cfg with every export name that is not an export of the proxy
+// replaced by the export fuzzyExport finds for it, logging each replacement.
+// Patterns, ordinals and names matching nothing are kept as written.
+func (m *Manager) fuzzyNames(cfg *Config) *Config {
+	table, err := selfExports()
+	if err != nil {
+		m.logger.Warn("failed to read proxy exports for config names", "error", err)
+		return cfg
+	}
+	resolve := func(name string) string {
+		if name == "*" || strings.HasPrefix(name, "#") || strings.ContainsAny(name, "*[") {
+			return name
+		}
+		export, ok := fuzzyExport(table, name)
+		if !ok {
+			return name
+		}
+		if export != name {
+			m.logger.Warn("config name matched export loosely", "name", name, "export", export)
+		}
+		return export
+	}
+
+	out := *cfg
+	out.Trace = fuzzySlice(cfg.Trace, resolve)
+	out.Deny = fuzzySlice(cfg.Deny, resolve)
+	out.Hooks = fuzzyKeys(cfg.Hooks, resolve)
+	out.Aliases = fuzzyKeys(cfg.Aliases, resolve)
+	out.Stubs = fuzzyKeys(cfg.Stubs, resolve)
+	out.Faults = fuzzyKeys(cfg.Faults, resolve)
+	out.Delays = fuzzyKeys(cfg.Delays, resolve)
+	return &out
+}
+
+func fuzzySlice(names []string, resolve func(string) string) []string {
+	if names == nil {
+		return nil
+	}
+	out := make([]string, len(names))
+	for i, name := range names {
+		out[i] = resolve(name)
+	}
+	return out
+}
+
+func fuzzyKeys[V any](entries map[string]V, resolve func(string) string) map[string]V {
+	if entries == nil {
+		return nil
+	}
+	out := make(map[string]V, len(entries))
+	for name, v := range entries {
+		out[resolve(name)] = v
+	}
+	return out
+}
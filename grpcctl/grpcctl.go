@@ -0,0 +1,217 @@
+//go:build windows
+
+// Package grpcctl exposes a proxdll.Manager as a gRPC service for external tools.
+// The service, described in control.proto, provides hook management, statistics
+// snapshots and a live stream of call events. It only listens on loopback addresses.
+package grpcctl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync/atomic"
+
+	"github.com/nilssoncreative/proxdll"
+	"golang.org/x/sys/windows"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// streamBuffer is the number of events buffered per StreamCalls client.
+// Events are dropped rather than blocking host calls when a client falls behind.
+const streamBuffer = 1024
+
+// Server serves the Control service for a Manager.
+type Server struct {
+	m   *proxdll.Manager
+	srv *grpc.Server
+	ln  net.Listener
+}
+
+// Serve starts a gRPC server for m on addr, which must be a loopback address
+// such as "127.0.0.1:50551".
+func Serve(m *proxdll.Manager, addr string) (*Server, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid address %s: %w", addr, err)
+	}
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return nil, fmt.Errorf("refusing to serve on non-loopback address %s", addr)
+	}
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+	s := &Server{m: m, srv: grpc.NewServer(), ln: ln}
+	s.srv.RegisterService(&serviceDesc, s)
+	go s.srv.Serve(ln)
+	return s, nil
+}
+
+// Addr returns the address the server is listening on.
+func (s *Server) Addr() net.Addr {
+	return s.ln.Addr()
+}
+
+// Close stops the server, ending any active streams.
+func (s *Server) Close() error {
+	s.srv.Stop()
+	return nil
+}
+
+// service is the handler interface registered with gRPC.
+type service interface {
+	listHooks(ctx context.Context, req *emptypb.Empty) (*structpb.Struct, error)
+	setHookEnabled(ctx context.Context, req *structpb.Struct) (*emptypb.Empty, error)
+	getStats(ctx context.Context, req *emptypb.Empty) (*structpb.Struct, error)
+	streamCalls(req *emptypb.Empty, stream grpc.ServerStream) error
+}
+
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: "proxdll.v1.Control",
+	HandlerType: (*service)(nil),
+	Methods: []grpc.MethodDesc{
+		{MethodName: "ListHooks", Handler: unary(service.listHooks)},
+		{MethodName: "SetHookEnabled", Handler: unary(service.setHookEnabled)},
+		{MethodName: "GetStats", Handler: unary(service.getStats)},
+	},
+	Streams: []grpc.StreamDesc{
+		{StreamName: "StreamCalls", Handler: streamCallsHandler, ServerStreams: true},
+	},
+	Metadata: "control.proto",
+}
+
+// unary adapts a typed method to a grpc.MethodDesc handler.
+func unary[Req any, Resp any](method func(service, context.Context, *Req) (*Resp, error)) func(any, context.Context, func(any) error, grpc.UnaryServerInterceptor) (any, error) {
+	return func(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+		req := new(Req)
+		if err := dec(req); err != nil {
+			return nil, err
+		}
+		if interceptor == nil {
+			return method(srv.(service), ctx, req)
+		}
+		info := &grpc.UnaryServerInfo{Server: srv}
+		return interceptor(ctx, req, info, func(ctx context.Context, req any) (any, error) {
+			return method(srv.(service), ctx, req.(*Req))
+		})
+	}
+}
+
+func streamCallsHandler(srv any, stream grpc.ServerStream) error {
+	req := new(emptypb.Empty)
+	if err := stream.RecvMsg(req); err != nil {
+		return err
+	}
+	return srv.(service).streamCalls(req, stream)
+}
+
+func (s *Server) listHooks(ctx context.Context, req *emptypb.Empty) (*structpb.Struct, error) {
+	var hooks []any
+	for _, name := range s.m.Hooks() {
+		hooks = append(hooks, map[string]any{"func": name, "enabled": s.m.HookEnabled(name)})
+	}
+	return structpb.NewStruct(map[string]any{"hooks": hooks})
+}
+
+func (s *Server) setHookEnabled(ctx context.Context, req *structpb.Struct) (*emptypb.Empty, error) {
+	name := req.GetFields()["func"].GetStringValue()
+	if name == "" {
+		return nil, status.Error(codes.InvalidArgument, "func is required")
+	}
+	enabled := req.GetFields()["enabled"].GetBoolValue()
+	if err := s.m.SetHookEnabled(name, enabled); err != nil {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func (s *Server) getStats(ctx context.Context, req *emptypb.Empty) (*structpb.Struct, error) {
+	stats := s.m.Stats()
+	if stats == nil {
+		return nil, status.Error(codes.FailedPrecondition, "statistics are not enabled, see proxdll.WithStats")
+	}
+	out := make(map[string]any, len(stats))
+	for name, st := range stats {
+		out[name] = map[string]any{
+			"calls":   float64(st.Calls),
+			"errors":  float64(st.Errors),
+			"minNs":   float64(st.Min.Nanoseconds()),
+			"meanNs":  float64(st.Mean.Nanoseconds()),
+			"maxNs":   float64(st.Max.Nanoseconds()),
+			"totalNs": float64(st.Total.Nanoseconds()),
+		}
+	}
+	return structpb.NewStruct(out)
+}
+
+func (s *Server) streamCalls(req *emptypb.Empty, stream grpc.ServerStream) error {
+	events := make(chan proxdll.Event, streamBuffer)
+	var dropped atomic.Uint64
+	remove := s.m.AddTracer(proxdll.TracerFunc(func(ev *proxdll.Event) {
+		select {
+		case events <- *ev:
+		default:
+			dropped.Add(1)
+		}
+	}))
+	defer remove()
+
+	ctx := stream.Context()
+	for {
+		select {
+		case <-ctx.Done():
+			if n := dropped.Load(); n > 0 {
+				return status.Errorf(codes.ResourceExhausted, "client fell behind, %d events dropped", n)
+			}
+			return nil
+		case ev := <-events:
+			msg, err := eventStruct(&ev)
+			if err != nil {
+				return status.Error(codes.Internal, err.Error())
+			}
+			if err := stream.SendMsg(msg); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// eventStruct converts an event to its wire representation.
+func eventStruct(ev *proxdll.Event) (*structpb.Struct, error) {
+	args := make([]any, len(ev.Args))
+	for i, arg := range ev.Args {
+		args[i] = float64(arg)
+	}
+	fields := map[string]any{
+		"func":          ev.Func,
+		"args":          args,
+		"r1":            float64(ev.R1),
+		"r2":            float64(ev.R2),
+		"tid":           float64(ev.ThreadID),
+		"startUnixNano": float64(ev.Start.UnixNano()),
+		"durationNs":    float64(ev.Duration.Nanoseconds()),
+	}
+	if ev.Caller.Address != 0 {
+		fields["caller"] = ev.Caller.String()
+	}
+	if ev.Signature != nil {
+		params := make(map[string]any)
+		for _, arg := range ev.DecodedArgs() {
+			params[arg.Name] = arg.Value
+		}
+		fields["params"] = params
+	}
+	var errno windows.Errno
+	if errors.As(ev.LastErr, &errno) {
+		fields["lastErr"] = float64(errno)
+	} else if ev.LastErr != nil {
+		fields["lastErr"] = ev.LastErr.Error()
+	}
+	return structpb.NewStruct(fields)
+}
@@ -0,0 +1,95 @@
+//go:build windows
+
+package proxdll
+
+import (
+	"strings"
+	"sync"
+
+	"golang.org/x/sys/windows"
+)
+
+// guidNames maps GUIDs to the names GUIDPtr prints next to them.
+var (
+	guidMu    sync.RWMutex
+	guidNames = func() map[windows.GUID]string {
+		names := make(map[windows.GUID]string)
+		for s, name := range map[string]string{
+			"{00000000-0000-0000-0000-000000000000}": "GUID_NULL",
+			"{00000000-0000-0000-C000-000000000046}": "IUnknown",
+			"{00000001-0000-0000-C000-000000000046}": "IClassFactory",
+			"{00000003-0000-0000-C000-000000000046}": "IMarshal",
+			"{0000000B-0000-0000-C000-000000000046}": "IStorage",
+			"{0000000C-0000-0000-C000-000000000046}": "IStream",
+			"{00000018-0000-0000-C000-000000000046}": "IStdMarshalInfo",
+			"{00000019-0000-0000-C000-000000000046}": "IExternalConnection",
+			"{00000109-0000-0000-C000-000000000046}": "IPersistStream",
+			"{0000010C-0000-0000-C000-000000000046}": "IPersist",
+			"{0000010E-0000-0000-C000-000000000046}": "IDataObject",
+			"{00000112-0000-0000-C000-000000000046}": "IOleObject",
+			"{00020400-0000-0000-C000-000000000046}": "IDispatch",
+			"{00020401-0000-0000-C000-000000000046}": "ITypeInfo",
+			"{00020404-0000-0000-C000-000000000046}": "IEnumVARIANT",
+			"{B196B283-BAB4-101A-B69C-00AA00341D07}": "IProvideClassInfo",
+			"{B196B284-BAB4-101A-B69C-00AA00341D07}": "IConnectionPointContainer",
+			"{B196B28F-BAB4-101A-B69C-00AA00341D07}": "IClassFactory2",
+			"{94EA2B94-E9CC-49E0-C0FF-EE64CA8F5B90}": "IAgileObject",
+			"{ECC8691B-C1DB-4DC0-855E-65F6C551AF49}": "INoMarshal",
+		} {
+			g, err := windows.GUIDFromString(s)
+			if err != nil {
+				panic(err)
+			}
+			names[g] = name
+		}
+		return names
+	}()
+)
+
+// RegisterGUIDName sets the name traces show for g, such as the interface an IID
+// identifies or the class of a CLSID. Common COM interfaces are registered already.
+func RegisterGUIDName(g windows.GUID, name string) {
+	guidMu.Lock()
+	defer guidMu.Unlock()
+	guidNames[g] = name
+}
+
+// GUIDName returns the name registered for g, or "" if there is none.
+func GUIDName(g windows.GUID) string {
+	guidMu.RLock()
+	defer guidMu.RUnlock()
+	return guidNames[g]
+}
+
+// formatGUID renders g with its registered name, if any.
+func formatGUID(g windows.GUID) string {
+	if name := GUIDName(g); name != "" {
+		return name + " " + g.String()
+	}
+	return g.String()
+}
+
+// TypeDecoder returns the decoder for arguments of the C type typeName, such as REFIID
+// or LPCWSTR, or nil if it has none. Signatures built from a prototype can use it to
+// pick decoders by parameter type.
+func TypeDecoder(typeName string) Decoder {
+	switch strings.ToUpper(strings.Join(strings.Fields(typeName), " ")) {
+	case "REFIID", "REFCLSID", "REFGUID", "REFFMTID", "LPIID", "LPCLSID", "LPGUID", "IID *", "CLSID *", "GUID *", "IID*", "CLSID*", "GUID*":
+		return GUIDPtr
+	case "LPCWSTR", "LPWSTR", "PCWSTR", "PWSTR", "WCHAR *", "WCHAR*":
+		return WString
+	case "LPCSTR", "LPSTR", "PCSTR", "PSTR", "CHAR *", "CHAR*":
+		return String
+	case "HANDLE", "HMODULE", "HINSTANCE", "HKEY", "HWND":
+		return Handle
+	case "BOOL":
+		return Bool
+	case "DWORD", "UINT", "ULONG", "SIZE_T":
+		return Uint
+	case "INT", "LONG":
+		return Int32
+	case "HRESULT":
+		return Hex
+	}
+	return nil
+}
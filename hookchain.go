@@ -0,0 +1,198 @@
+//go:build windows
+
+package proxdll
+
+import (
+	"cmp"
+	"slices"
+)
+
+// HookOption configures a hook added with AddHook.
+type HookOption func(*hookEntry)
+
+// HookPriority sets the priority of a hook in its export's chain. Hooks with a higher
+// priority run first and see the call before hooks with a lower one; hooks of equal
+// priority run in the order they were added. The default priority is zero.
+func HookPriority(priority int) HookOption {
+	return func(e *hookEntry) {
+		e.priority = priority
+	}
+}
+
+// HookWhen makes a hook fire only when pred returns true for the raw arguments of a call.
+// Otherwise the call passes on to the rest of the chain as if the hook were absent, and
+// when no hook on an export fires, the call takes the same path as an unhooked one.
+// pred runs on every call to the export and must not retain args.
+func HookWhen(pred func(args []uintptr) bool) HookOption {
+	return func(e *hookEntry) {
+		e.when = pred
+	}
+}
+
+// hookEntry gives each hook in a chain an identity, since hooks are not comparable.
+type hookEntry struct {
+	hook     Hook
+	priority int
+	group    string
+	when     func(args []uintptr) bool
+	seq      uint64
+
+	skipReentrant bool
+}
+
+// run calls the entry's hook, or next if its predicate rejects the call.
+func (e *hookEntry) run(call *Call, next Next) (uintptr, uintptr, error) {
+	if e.when != nil && !e.when(call.Args) || e.skipReentrant && call.Reentrant() {
+		return next()
+	}
+	return e.hook(call, next)
+}
+
+// resolvedHook is the hook Call runs for an export, with the predicates gating it.
+type resolvedHook struct {
+	hook Hook
+	// when reports whether any hook fires for the arguments, or is nil if one always does.
+	when func(args []uintptr) bool
+}
+
+// compareHookEntries orders hook entries by descending priority, then by age.
+func compareHookEntries(a, b *hookEntry) int {
+	if c := cmp.Compare(b.priority, a.priority); c != 0 {
+		return c
+	}
+	return cmp.Compare(a.seq, b.seq)
+}
+
+// AddHook adds hook to the chain of hooks for funcName, configured by opts, leaving hooks
+// already registered for it in place. Each hook's next runs the following hook in the
+// chain, and the last hook's next runs the original, so independently written hook packs
+// can intercept the same export. funcName may be a pattern, as in RegisterHook.
+// It returns a function that removes the hook again.
+func (m *Manager) AddHook(funcName string, hook Hook, opts ...HookOption) (remove func()) {
+	entry := &hookEntry{hook: hook}
+	for _, opt := range opts {
+		opt(entry)
+	}
+	pattern := mustHookPattern(funcName)
+
+	m.mu.Lock()
+	m.hookSeq++
+	entry.seq = m.hookSeq
+	chain := append(m.hookChains[funcName], entry)
+	slices.SortFunc(chain, compareHookEntries)
+	m.hookChains[funcName] = chain
+	m.publishHooks(funcName, pattern)
+	m.mu.Unlock()
+
+	return func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		chain := m.hookChains[funcName]
+		if i := slices.Index(chain, entry); i >= 0 {
+			m.hookChains[funcName] = slices.Delete(chain, i, i+1)
+			m.publishHooks(funcName, pattern)
+		}
+	}
+}
+
+// publishHooks records a change to the chain registered under key, which is matched by
+// pattern unless that is nil. The caller must hold m.mu.
+func (m *Manager) publishHooks(key string, pattern *hookPattern) {
+	if len(m.hookChains[key]) == 0 {
+		delete(m.hookChains, key)
+		m.hookPatterns = slices.DeleteFunc(m.hookPatterns, func(p *hookPattern) bool {
+			return p.key == key
+		})
+	} else if pattern != nil && !slices.ContainsFunc(m.hookPatterns, func(p *hookPattern) bool {
+		return p.key == key
+	}) {
+		m.hookPatterns = append(m.hookPatterns, pattern)
+	}
+	m.invalidateHooks()
+}
+
+// invalidateHooks drops the hooks resolved for each export, so the next call to each
+// resolves them again. The caller must hold m.mu.
+func (m *Manager) invalidateHooks() {
+	clear(m.hooks)
+}
+
+// hook returns the hook Call runs for funcName with args, or nil if there is none
+// or none of its predicates accept args.
+func (m *Manager) hook(funcName string, args []uintptr) Hook {
+	m.mu.RLock()
+	r, ok := m.hooks[funcName]
+	m.mu.RUnlock()
+	if !ok {
+		m.mu.Lock()
+		if r, ok = m.hooks[funcName]; !ok {
+			r = m.resolveHook(funcName)
+			m.hooks[funcName] = r
+		}
+		m.mu.Unlock()
+	}
+
+	if r.when != nil && !r.when(args) {
+		return nil
+	}
+	return r.hook
+}
+
+// resolveHook composes the enabled hooks registered for funcName, by name or by a
+// matching pattern, into the single hook run by Call. The caller must hold m.mu.
+func (m *Manager) resolveHook(funcName string) resolvedHook {
+	var chain []*hookEntry
+	add := func(key string) {
+		if m.disabledHooks[key] {
+			return
+		}
+		for _, e := range m.hookChains[key] {
+			if !m.disabledGroups[e.group] {
+				chain = append(chain, e)
+			}
+		}
+	}
+	add(funcName)
+	for _, p := range m.hookPatterns {
+		if p.match(funcName) {
+			add(p.key)
+		}
+	}
+
+	if len(chain) == 0 {
+		return resolvedHook{}
+	}
+	slices.SortFunc(chain, compareHookEntries)
+
+	r := resolvedHook{hook: chainHooks(chain)}
+	if len(chain) == 1 && chain[0].when == nil && !chain[0].skipReentrant {
+		r.hook = chain[0].hook
+	}
+	if !slices.ContainsFunc(chain, func(e *hookEntry) bool { return e.when == nil }) {
+		r.when = func(args []uintptr) bool {
+			for _, e := range chain {
+				if e.when(args) {
+					return true
+				}
+			}
+			return false
+		}
+	}
+	return r
+}
+
+// chainHooks composes chain into a single hook that runs each entry in order.
+func chainHooks(chain []*hookEntry) Hook {
+	return func(call *Call, next Next) (uintptr, uintptr, error) {
+		var run func(i int) (uintptr, uintptr, error)
+		run = func(i int) (uintptr, uintptr, error) {
+			if i == len(chain) {
+				return next()
+			}
+			return chain[i].run(call, func() (uintptr, uintptr, error) {
+				return run(i + 1)
+			})
+		}
+		return run(0)
+	}
+}
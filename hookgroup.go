@@ -0,0 +1,61 @@
+//go:build windows
+
+package proxdll
+
+import (
+	"maps"
+	"slices"
+)
+
+// HookGroup labels a hook as part of the named group, so the hooks of a feature such as
+// "tracing" or "fps-overlay" can be switched on and off together with EnableGroup and
+// DisableGroup.
+func HookGroup(group string) HookOption {
+	return func(e *hookEntry) {
+		e.group = group
+	}
+}
+
+// EnableGroup enables the hooks labelled with group, undoing DisableGroup.
+func (m *Manager) EnableGroup(group string) {
+	m.setGroupEnabled(group, true)
+}
+
+// DisableGroup disables the hooks labelled with group without unregistering them.
+// Hooks added to the group later start disabled too.
+func (m *Manager) DisableGroup(group string) {
+	m.setGroupEnabled(group, false)
+}
+
+func (m *Manager) setGroupEnabled(group string, enabled bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if enabled {
+		delete(m.disabledGroups, group)
+	} else {
+		m.disabledGroups[group] = true
+	}
+	m.invalidateHooks()
+}
+
+// GroupEnabled reports whether the hooks labelled with group are enabled.
+func (m *Manager) GroupEnabled(group string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return !m.disabledGroups[group]
+}
+
+// Groups returns the groups that label at least one registered hook, sorted.
+func (m *Manager) Groups() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	groups := make(map[string]bool)
+	for _, chain := range m.hookChains {
+		for _, e := range chain {
+			if e.group != "" {
+				groups[e.group] = true
+			}
+		}
+	}
+	return slices.Sorted(maps.Keys(groups))
+}
@@ -0,0 +1,58 @@
+//go:build windows
+
+package proxdll
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// hookPattern is a registered hook key that matches a family of exports.
+type hookPattern struct {
+	key   string
+	match func(funcName string) bool
+}
+
+// isHookPattern reports whether a hook key is a pattern rather than an export name.
+func isHookPattern(key string) bool {
+	return isRegexpKey(key) || strings.ContainsAny(key, "*?[")
+}
+
+// isRegexpKey reports whether a hook key is a regular expression delimited by slashes.
+func isRegexpKey(key string) bool {
+	return len(key) >= 2 && key[0] == '/' && key[len(key)-1] == '/'
+}
+
+// compileHookPattern returns the matcher for a pattern key: a regular expression for keys
+// of the form "/expr/", and a path.Match glob such as "Direct3DCreate*" otherwise.
+func compileHookPattern(key string) (*hookPattern, error) {
+	if isRegexpKey(key) {
+		re, err := regexp.Compile(key[1 : len(key)-1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid hook pattern %s: %w", key, err)
+		}
+		return &hookPattern{key: key, match: re.MatchString}, nil
+	}
+	if _, err := path.Match(key, ""); err != nil {
+		return nil, fmt.Errorf("invalid hook pattern %s: %w", key, err)
+	}
+	return &hookPattern{key: key, match: func(funcName string) bool {
+		ok, _ := path.Match(key, funcName)
+		return ok
+	}}, nil
+}
+
+// mustHookPattern compiles key if it is a pattern, or returns nil for an export name.
+// It panics on an invalid pattern, as patterns are fixed when a proxy is written.
+func mustHookPattern(key string) *hookPattern {
+	if !isHookPattern(key) {
+		return nil
+	}
+	p, err := compileHookPattern(key)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
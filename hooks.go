@@ -0,0 +1,200 @@
+//go:build windows
+
+package proxdll
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"golang.org/x/sys/windows"
+)
+
+// RegisterHook installs hook for funcName, replacing any hooks already registered for it.
+// The hook runs whenever the export is invoked through Call. Use AddHook to chain
+// several hooks on the same export instead.
+//
+// funcName may also be a pattern covering a family of exports: a glob in the syntax of
+// path.Match, such as "Direct3DCreate*", or a regular expression between slashes, such as
+// "/^Reg(Open|Create)Key/". Hooks registered by name and by matching patterns all run, in
+// priority order. RegisterHook panics if funcName is not a valid pattern.
+func (m *Manager) RegisterHook(funcName string, hook Hook) {
+	pattern := mustHookPattern(funcName)
+
+	m.mu.Lock()
+	m.hookSeq++
+	m.hookChains[funcName] = []*hookEntry{{hook: hook, seq: m.hookSeq}}
+	m.publishHooks(funcName, pattern)
+	m.mu.Unlock()
+}
+
+// RegisterPreHook installs pre for funcName, as RegisterHook(funcName, Pre(pre)) does.
+func (m *Manager) RegisterPreHook(funcName string, pre PreHook) {
+	m.RegisterHook(funcName, Pre(pre))
+}
+
+// UnregisterHook removes every hook registered under funcName, if any.
+func (m *Manager) UnregisterHook(funcName string) {
+	m.mu.Lock()
+	delete(m.hookChains, funcName)
+	delete(m.disabledHooks, funcName)
+	m.publishHooks(funcName, nil)
+	m.mu.Unlock()
+}
+
+// Hooks returns the names and patterns that have hooks registered, sorted.
+func (m *Manager) Hooks() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return slices.Sorted(maps.Keys(m.hookChains))
+}
+
+// SetHookEnabled enables or disables the hooks registered under funcName, which may be a
+// pattern, without unregistering them. Calls to an export with no enabled hooks go
+// straight to the original.
+func (m *Manager) SetHookEnabled(funcName string, enabled bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.hookChains[funcName]; !ok {
+		return fmt.Errorf("no hook registered for %s", funcName)
+	}
+	if enabled {
+		delete(m.disabledHooks, funcName)
+	} else {
+		m.disabledHooks[funcName] = true
+	}
+	m.invalidateHooks()
+	return nil
+}
+
+// HookEnabled reports whether funcName has hooks registered under it and enabled.
+func (m *Manager) HookEnabled(funcName string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	_, ok := m.hookChains[funcName]
+	return ok && !m.disabledHooks[funcName]
+}
+
+// Call invokes funcName on behalf of the host, running its hook if one is registered
+// and reporting the call to the Tracer, if any.
+// Exports deny-listed by the current Config fail with ERROR_ACCESS_DENIED without reaching the
+// original, as do calls from modules its AllowCallers list does not include; exports it
+// stubs return the configured results, and those it injects delays or faults into are
+// slowed down or fail as configured.
+// Lookup failures are reported as in TryCallOriginal rather than by panicking.
+// After Shutdown, Call returns ErrShutdown without calling anything.
+// Proxy stubs should use Call rather than calling the original directly so hooks take effect.
+//
+// Without a hook or tracer, Call forwards through FastCallOriginal and does not allocate
+// once the export has been seen; its argument keep-alive rules apply.
+// Otherwise Call allocates a copy of args.
+func (m *Manager) Call(funcName string, args ...uintptr) (r1, r2 uintptr, lastErr error) {
+	if m.shutdown.Load() {
+		return m.fallback(funcName, 0, 0, ErrShutdown)
+	}
+
+	hook := m.hook(funcName, args)
+	if replace := m.configHook(funcName); replace != nil {
+		hook = replace
+	} else if reject := m.callerHook(funcName); reject != nil {
+		hook = reject
+	} else if inject := m.injectHook(funcName); inject != nil {
+		hook = wrapHook(inject, hook)
+	}
+
+	var start time.Time
+	if m.stats != nil {
+		start = time.Now()
+	}
+	if m.recorder != nil {
+		m.recorder.record(funcName, m.Signature(funcName).redactArgs(args))
+	}
+	if m.crash != nil {
+		tid, prev := m.crash.enter(funcName)
+		defer m.crash.exit(tid, prev)
+	}
+
+	if hook == nil && m.tracers.Load() == nil && m.replay == nil {
+		r1, r2, lastErr = m.callOriginal(funcName, args...)
+	} else {
+		// Copying args keeps the caller's slice from escaping on the fast path.
+		r1, r2, lastErr = m.dispatch(funcName, slices.Clone(args), hook)
+	}
+
+	if m.stats != nil {
+		m.stats.record(funcName, time.Since(start), lastErr)
+	}
+	return r1, r2, lastErr
+}
+
+// dispatch runs hook, if any, around the original function and traces the result.
+func (m *Manager) dispatch(funcName string, args []uintptr, hook Hook) (r1, r2 uintptr, lastErr error) {
+	depth := m.depth.enter()
+	defer m.depth.exit()
+
+	tracer := m.tracer()
+	var ev *Event
+	// bufArgs are the arguments as called, which buffers are read through both before
+	// and after the call; only ev.Args is redacted.
+	var bufArgs []uintptr
+	if tracer != nil {
+		sig := m.Signature(funcName)
+		ev = &Event{
+			Func:      funcName,
+			Args:      sig.redactArgs(slices.Clone(args)),
+			Start:     time.Now(),
+			ThreadID:  windows.GetCurrentThreadId(),
+			Signature: sig,
+		}
+		if m.undecorate {
+			if name := Undecorate(funcName); name != funcName {
+				ev.Undecorated = name
+			}
+		}
+		if m.wantsStack(funcName) {
+			ev.Stack = captureStack(m.stackDepth)
+			if len(ev.Stack) > 0 {
+				ev.Caller = ev.Stack[0]
+			}
+		} else if m.callers {
+			ev.Caller = captureCaller()
+			if m.symbolPath != "" {
+				symbols.symbolize(&ev.Caller)
+			}
+		}
+		if ev.Signature != nil && ev.Signature.hasBuffers() {
+			bufArgs = slices.Clone(args)
+			ev.Buffers = ev.Signature.captureBuffers(bufArgs, nil)
+		}
+		if st, ok := tracer.(StartTracer); ok {
+			st.TraceStart(ev)
+		}
+	}
+
+	forward := m.callOriginal
+	if m.replay != nil {
+		forward = m.replay.call
+	}
+	if hook == nil {
+		r1, r2, lastErr = forward(funcName, args...)
+	} else {
+		call := &Call{Name: funcName, Args: args, Depth: depth}
+		if ev != nil && m.callers {
+			call.caller, call.callerDone = ev.Caller, true
+		}
+		r1, r2, lastErr = RunHook(hook, call, func() (uintptr, uintptr, error) {
+			return forward(call.Name, call.Args...)
+		})
+	}
+
+	if ev != nil {
+		ev.Duration = time.Since(ev.Start)
+		if ev.Buffers != nil {
+			ev.Buffers = ev.Signature.captureBuffers(bufArgs, ev.Buffers)
+		}
+		ev.R1, ev.R2, ev.LastErr = r1, r2, lastErr
+		tracer.Trace(ev)
+	}
+	return r1, r2, lastErr
+}
@@ -0,0 +1,92 @@
+//go:build windows
+
+package proxdll
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrFreed is returned by calls to the original DLL made after Free. It matches ErrManagerClosed.
+var ErrFreed = withCategory(errors.New("proxdll: original DLL is freed"), ErrManagerClosed)
+
+// gateClosed marks a callGate that no longer admits calls, above the bits counting them.
+const gateClosed = 1 << 62
+
+// callGate counts the calls running in a module, so the module is only released once
+// they have returned. It takes no lock, to keep FastCallOriginal allocation- and lock-free.
+type callGate struct {
+	state    atomic.Int64
+	closing  sync.Once
+	signaled sync.Once
+	drained  chan struct{}
+}
+
+// closed reports whether the gate no longer admits calls.
+func (g *callGate) closed() bool {
+	return g.state.Load()&gateClosed != 0
+}
+
+// enter admits a call, reporting false once the gate is closed.
+func (g *callGate) enter() bool {
+	if g.state.Add(1)&gateClosed != 0 {
+		g.exit()
+		return false
+	}
+	return true
+}
+
+// exit ends a call admitted by enter.
+func (g *callGate) exit() {
+	if g.state.Add(-1) == gateClosed {
+		g.signal()
+	}
+}
+
+// signal reports that the last call has returned from a closed gate.
+func (g *callGate) signal() {
+	g.signaled.Do(func() { close(g.drained) })
+}
+
+// close stops admitting calls and waits up to timeout, or without limit if timeout is zero,
+// for the calls already running to return.
+func (g *callGate) close(timeout time.Duration) error {
+	g.closing.Do(func() {
+		// drained exists before any exit can observe the closed gate.
+		g.drained = make(chan struct{})
+		if g.state.Or(gateClosed)&^gateClosed == 0 {
+			g.signal()
+		}
+	})
+
+	if timeout == 0 {
+		<-g.drained
+		return nil
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-g.drained:
+		return nil
+	case <-timer.C:
+		return fmt.Errorf("%d calls to the original DLL still running after %v", g.state.Load()&^gateClosed, timeout)
+	}
+}
+
+// enterCall admits a call to the current original DLL, returning the gate to exit once
+// the call returns. It reports false after Free. A gate closed by Reload is retried with
+// the gate of the new original.
+func (m *Manager) enterCall() (*callGate, bool) {
+	for {
+		g := m.calls.Load()
+		if g.enter() {
+			return g, true
+		}
+		if m.calls.Load() == g {
+			return nil, false
+		}
+	}
+}
@@ -0,0 +1,89 @@
+//go:build windows
+
+package proxdll
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// ErrLoaderLock is returned by InitOnce when it is used while the calling thread holds the
+// loader lock, where loading the original DLL could deadlock.
+var ErrLoaderLock = errors.New("proxdll: initialization attempted while holding the loader lock")
+
+// pebLoaderLockOffset returns the offset of the LoaderLock critical section pointer in the PEB.
+func pebLoaderLockOffset() uintptr {
+	if unsafe.Sizeof(uintptr(0)) == 8 {
+		return 0x110
+	}
+	return 0xa0
+}
+
+// InLoaderLock reports whether the calling thread holds the loader lock, as it does while
+// running DllMain, TLS callbacks or package initialization triggered from them.
+func InLoaderLock() bool {
+	if procRtlIsCriticalSectionLockedByThread.Find() != nil {
+		return false
+	}
+	peb := windows.RtlGetCurrentPeb()
+	lock := *(*uintptr)(unsafe.Add(unsafe.Pointer(peb), pebLoaderLockOffset()))
+	if lock == 0 {
+		return false
+	}
+	r, _, _ := procRtlIsCriticalSectionLockedByThread.Call(lock)
+	return r != 0
+}
+
+// InitOnce constructs a Manager exactly once, on first use, and stores the result for
+// every later call. The init function typically calls New and registers hooks.
+//
+// Constructing a Manager from a package init function can deadlock, because package
+// initialization of a c-shared DLL may run while the loader lock is held. InitOnce
+// refuses to run init under the loader lock, returning ErrLoaderLock without consuming
+// the attempt, so the first call from an exported function initializes instead.
+type InitOnce struct {
+	init func() (*Manager, error)
+	mu   sync.Mutex
+	done atomic.Bool
+	m    *Manager
+	err  error
+}
+
+// NewInitOnce returns an InitOnce that runs init on first use.
+func NewInitOnce(init func() (*Manager, error)) *InitOnce {
+	return &InitOnce{init: init}
+}
+
+// Manager returns the Manager built by init, running init if this is the first call.
+// If init failed, its error is returned by this and every later call.
+// init must not call Manager itself.
+func (o *InitOnce) Manager() (*Manager, error) {
+	if o.done.Load() {
+		return o.m, o.err
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.done.Load() {
+		return o.m, o.err
+	}
+	if InLoaderLock() {
+		return nil, ErrLoaderLock
+	}
+	o.m, o.err = o.init()
+	o.done.Store(true)
+	return o.m, o.err
+}
+
+// MustManager is like Manager but panics if initialization failed.
+func (o *InitOnce) MustManager() *Manager {
+	m, err := o.Manager()
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
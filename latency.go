@@ -0,0 +1,39 @@
+package proxdll
+
+import (
+	"math/rand/v2"
+	"time"
+)
+
+// Delay describes latency to inject into calls of an export, to simulate a slow disk,
+// network or driver behind it.
+type Delay struct {
+	// Before is how long to wait before forwarding the call.
+	Before time.Duration
+	// After is how long to wait after the original returns.
+	After time.Duration
+	// Jitter adds a random extra delay of up to Jitter to each nonzero wait.
+	Jitter time.Duration
+}
+
+// InjectDelay returns a Hook that delays calls as described by d.
+// The delay blocks the calling thread, as a slow original would.
+func InjectDelay(d Delay) Hook {
+	return func(call *Call, next Next) (uintptr, uintptr, error) {
+		d.wait(d.Before)
+		r1, r2, lastErr := next()
+		d.wait(d.After)
+		return r1, r2, lastErr
+	}
+}
+
+// wait sleeps for base plus jitter, unless base is zero.
+func (d Delay) wait(base time.Duration) {
+	if base <= 0 {
+		return
+	}
+	if d.Jitter > 0 {
+		base += rand.N(d.Jitter)
+	}
+	time.Sleep(base)
+}
@@ -0,0 +1,118 @@
+//go:build windows && cgo
+
+package lifecycle
+
+/*
+#include <windows.h>
+
+#define PROXDLL_QUEUE_SIZE 4096
+
+typedef struct {
+	DWORD reason;
+	DWORD tid;
+} proxdll_event;
+
+static SRWLOCK proxdll_lock = SRWLOCK_INIT;
+static proxdll_event proxdll_queue[PROXDLL_QUEUE_SIZE];
+static unsigned int proxdll_head, proxdll_tail;
+static unsigned int proxdll_dropped;
+static HANDLE proxdll_pending;
+static HANDLE proxdll_detached;
+static volatile LONG proxdll_listening;
+
+// proxdll_push queues a notification. It only takes an SRW lock and signals an event,
+// both of which are safe under the loader lock.
+static void proxdll_push(DWORD reason, DWORD tid) {
+	AcquireSRWLockExclusive(&proxdll_lock);
+	if (proxdll_tail - proxdll_head < PROXDLL_QUEUE_SIZE) {
+		proxdll_queue[proxdll_tail % PROXDLL_QUEUE_SIZE].reason = reason;
+		proxdll_queue[proxdll_tail % PROXDLL_QUEUE_SIZE].tid = tid;
+		proxdll_tail++;
+	} else {
+		proxdll_dropped++;
+	}
+	ReleaseSRWLockExclusive(&proxdll_lock);
+	SetEvent(proxdll_pending);
+}
+
+BOOL WINAPI DllMain(HINSTANCE module, DWORD reason, LPVOID reserved) {
+	switch (reason) {
+	case DLL_PROCESS_ATTACH:
+		proxdll_pending = CreateEventW(NULL, FALSE, FALSE, NULL);
+		proxdll_detached = CreateEventW(NULL, TRUE, FALSE, NULL);
+		break;
+	case DLL_THREAD_ATTACH:
+	case DLL_THREAD_DETACH:
+		if (proxdll_listening) {
+			proxdll_push(reason, GetCurrentThreadId());
+		}
+		break;
+	case DLL_PROCESS_DETACH:
+		// A non-NULL reserved means the process is exiting and the Go runtime's
+		// threads are already gone, so nothing would deliver the notification.
+		if (reserved == NULL && proxdll_listening) {
+			proxdll_push(reason, GetCurrentThreadId());
+			WaitForSingleObject(proxdll_detached, 1000);
+		}
+		break;
+	}
+	return TRUE;
+}
+
+static void proxdll_listen(void) {
+	InterlockedExchange(&proxdll_listening, 1);
+}
+
+// proxdll_next blocks until a notification is queued and returns it, along with the
+// number of notifications dropped because the queue was full.
+static proxdll_event proxdll_next(unsigned int *dropped) {
+	proxdll_event ev;
+	for (;;) {
+		AcquireSRWLockExclusive(&proxdll_lock);
+		if (proxdll_head != proxdll_tail) {
+			ev = proxdll_queue[proxdll_head % PROXDLL_QUEUE_SIZE];
+			proxdll_head++;
+			*dropped = proxdll_dropped;
+			proxdll_dropped = 0;
+			ReleaseSRWLockExclusive(&proxdll_lock);
+			return ev;
+		}
+		ReleaseSRWLockExclusive(&proxdll_lock);
+		WaitForSingleObject(proxdll_pending, INFINITE);
+	}
+}
+
+static void proxdll_detach_done(void) {
+	SetEvent(proxdll_detached);
+}
+*/
+import "C"
+
+import "sync/atomic"
+
+// dropped counts thread notifications lost because the queue overflowed.
+var dropped atomic.Uint64
+
+// Dropped returns the number of thread notifications lost because they arrived faster
+// than the callbacks consumed them.
+func Dropped() uint64 {
+	return dropped.Load()
+}
+
+func init() {
+	go listen()
+}
+
+// listen delivers queued notifications for the lifetime of the DLL.
+func listen() {
+	C.proxdll_listen()
+	for {
+		var lost C.uint
+		ev := C.proxdll_next(&lost)
+		dropped.Add(uint64(lost))
+		dispatch(uint32(ev.reason), uint32(ev.tid))
+		if ev.reason == C.DLL_PROCESS_DETACH {
+			C.proxdll_detach_done()
+		}
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !windows || !cgo
+
+package lifecycle
+
+// Dropped returns the number of thread notifications lost because they arrived faster
+// than the callbacks consumed them. Without cgo, no notifications are delivered.
+func Dropped() uint64 {
+	return 0
+}
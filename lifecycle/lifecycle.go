@@ -0,0 +1,139 @@
+// Package lifecycle provides the DllMain notifications a proxy DLL needs without running
+// Go code under the loader lock.
+//
+// Go code cannot safely run inside DllMain: the runtime starts threads, and every new
+// thread waits for the loader lock held by DllMain. Instead, process attach callbacks
+// run on the first call to Start, which exported functions make on entry, and thread
+// attach and detach notifications are queued by a small C DllMain and delivered on a
+// goroutine afterwards.
+//
+// Thread and process detach notifications require cgo, which proxy DLLs built with
+// -buildmode=c-shared always use. Importing this package defines DllMain for the DLL,
+// so it cannot be combined with another DllMain.
+package lifecycle
+
+import (
+	"slices"
+	"sync"
+)
+
+var (
+	mu           sync.Mutex
+	attached     bool
+	attachDone   = make(chan struct{})
+	attachFuncs  []func()
+	detachFuncs  []func()
+	threadAttach []func(threadID uint32)
+	threadDetach []func(threadID uint32)
+	startOnce    sync.Once
+)
+
+// OnProcessAttach registers fn to run once, on the first call to Start, which happens
+// outside the loader lock. Registering after Start has run calls fn immediately.
+func OnProcessAttach(fn func()) {
+	mu.Lock()
+	if !attached {
+		attachFuncs = append(attachFuncs, fn)
+		mu.Unlock()
+		return
+	}
+	mu.Unlock()
+	fn()
+}
+
+// Start runs the pending process attach callbacks, in registration order, the first time
+// it is called; later calls wait for them to finish and then return.
+// Exported functions should call Start before doing any work.
+func Start() {
+	startOnce.Do(func() {
+		for {
+			mu.Lock()
+			funcs := attachFuncs
+			attachFuncs = nil
+			if len(funcs) == 0 {
+				attached = true
+				mu.Unlock()
+				break
+			}
+			mu.Unlock()
+			for _, fn := range funcs {
+				fn()
+			}
+		}
+		close(attachDone)
+	})
+	<-attachDone
+}
+
+// Started reports whether Start has finished running the process attach callbacks.
+func Started() bool {
+	select {
+	case <-attachDone:
+		return true
+	default:
+		return false
+	}
+}
+
+// OnProcessDetach registers fn to run when the DLL is unloaded with FreeLibrary.
+// Callbacks run in reverse registration order, and DllMain waits at most one second for them.
+// They do not run when the process exits, since the Go runtime's threads have been
+// terminated by then.
+//
+// DllMain holds the loader lock while it waits, so callbacks must not call into the
+// loader: LoadLibrary, FreeLibrary and GetModuleHandle, or a system function resolved
+// lazily on first use, would block until the wait times out and then race the unload.
+// Use proxdll.Manager.ShutdownAtDetach rather than Shutdown, which calls FreeLibrary.
+func OnProcessDetach(fn func()) {
+	mu.Lock()
+	detachFuncs = append(detachFuncs, fn)
+	mu.Unlock()
+}
+
+// OnThreadAttach registers fn to be told when a thread starts in the process.
+// Notifications arrive asynchronously on a goroutine, in the order they occurred.
+func OnThreadAttach(fn func(threadID uint32)) {
+	mu.Lock()
+	threadAttach = append(threadAttach, fn)
+	mu.Unlock()
+}
+
+// OnThreadDetach registers fn to be told when a thread exits.
+// Notifications arrive asynchronously on a goroutine, so the thread has usually exited
+// by the time fn runs; fn should only use threadID to release state kept for the thread.
+func OnThreadDetach(fn func(threadID uint32)) {
+	mu.Lock()
+	threadDetach = append(threadDetach, fn)
+	mu.Unlock()
+}
+
+// DllMain reasons delivered by the notification queue.
+const (
+	processDetach  = 0
+	threadAttached = 2
+	threadDetached = 3
+)
+
+// dispatch delivers one queued notification.
+func dispatch(reason, threadID uint32) {
+	mu.Lock()
+	var funcs []func(uint32)
+	switch reason {
+	case threadAttached:
+		funcs = threadAttach
+	case threadDetached:
+		funcs = threadDetach
+	case processDetach:
+		funcs := slices.Clone(detachFuncs)
+		mu.Unlock()
+		for _, fn := range slices.Backward(funcs) {
+			fn()
+		}
+		return
+	}
+	mu.Unlock()
+
+	for _, fn := range funcs {
+		fn(threadID)
+	}
+}
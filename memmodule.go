@@ -0,0 +1,479 @@
+//go:build windows
+
+package proxdll
+
+import (
+	"bytes"
+	"debug/pe"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/nilssoncreative/proxdll/pefile"
+	"golang.org/x/sys/windows"
+)
+
+// DllMain reasons passed to the entry point and TLS callbacks of a memory module.
+const (
+	dllProcessDetach = 0
+	dllProcessAttach = 1
+)
+
+// Base relocation types applied by relocate.
+const (
+	imageRelBasedAbsolute = 0
+	imageRelBasedHighLow  = 3
+	imageRelBasedDir64    = 10
+)
+
+// imageMachines maps GOARCH to the PE machine type a process of that architecture loads.
+var imageMachines = map[string]uint16{
+	"386":   pe.IMAGE_FILE_MACHINE_I386,
+	"amd64": pe.IMAGE_FILE_MACHINE_AMD64,
+	"arm":   pe.IMAGE_FILE_MACHINE_ARMNT,
+	"arm64": pe.IMAGE_FILE_MACHINE_ARM64,
+}
+
+// memoryModule is a DLL mapped from memory by LoadMemoryModule rather than by the system loader.
+type memoryModule struct {
+	name     string
+	base     uintptr
+	entry    uintptr
+	tls      []uintptr
+	pdata    uintptr
+	exports  *pefile.ExportTable
+	imports  []windows.Handle
+	mu       sync.Mutex
+	forwards map[string]windows.Handle
+}
+
+// LoadMemoryModule maps the DLL in image, such as one embedded with go:embed, into the
+// process without writing it to disk, and runs its entry point and TLS callbacks.
+// name identifies the module in errors. Its imports are loaded by the system loader.
+//
+// The system loader does not know the module, so GetModuleHandle does not find it and
+// its Handle is zero. Images using implicit thread-local storage (__declspec(thread))
+// are not supported, and neither are resources nor checks that read the file on disk,
+// such as WithExpectedHash.
+func LoadMemoryModule(name string, image []byte) (Module, error) {
+	f, err := pe.NewFile(bytes.NewReader(image))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse PE headers of %s: %w", name, err)
+	}
+	defer f.Close()
+
+	if f.Characteristics&pe.IMAGE_FILE_DLL == 0 {
+		return nil, fmt.Errorf("%s is not a DLL", name)
+	}
+	if want := imageMachines[runtime.GOARCH]; f.Machine != want {
+		return nil, fmt.Errorf("%s is built for machine %#x, but the process is %s", name, f.Machine, runtime.GOARCH)
+	}
+
+	var (
+		imageBase   uint64
+		size        uint32
+		headerSize  uint32
+		entry       uint32
+		dirs        []pe.DataDirectory
+		is64        bool
+		relocatable bool
+	)
+	switch oh := f.OptionalHeader.(type) {
+	case *pe.OptionalHeader32:
+		imageBase, size, headerSize, entry = uint64(oh.ImageBase), oh.SizeOfImage, oh.SizeOfHeaders, oh.AddressOfEntryPoint
+		dirs = oh.DataDirectory[:min(oh.NumberOfRvaAndSizes, uint32(len(oh.DataDirectory)))]
+	case *pe.OptionalHeader64:
+		imageBase, size, headerSize, entry = oh.ImageBase, oh.SizeOfImage, oh.SizeOfHeaders, oh.AddressOfEntryPoint
+		dirs = oh.DataDirectory[:min(oh.NumberOfRvaAndSizes, uint32(len(oh.DataDirectory)))]
+		is64 = true
+	default:
+		return nil, fmt.Errorf("%s has no optional header", name)
+	}
+	directory := func(index int) pe.DataDirectory {
+		if index >= len(dirs) {
+			return pe.DataDirectory{}
+		}
+		return dirs[index]
+	}
+	relocatable = f.Characteristics&pe.IMAGE_FILE_RELOCS_STRIPPED == 0 && directory(pe.IMAGE_DIRECTORY_ENTRY_BASERELOC).Size > 0
+	if int(headerSize) > len(image) || headerSize > size {
+		return nil, fmt.Errorf("%s has corrupt headers", name)
+	}
+
+	// Prefer the image's own base, which needs no relocation.
+	base, err := windows.VirtualAlloc(uintptr(imageBase), uintptr(size), windows.MEM_RESERVE|windows.MEM_COMMIT, windows.PAGE_READWRITE)
+	if err != nil {
+		if !relocatable {
+			return nil, fmt.Errorf("failed to map %s at its fixed base %#x: %w", name, imageBase, err)
+		}
+		if base, err = windows.VirtualAlloc(0, uintptr(size), windows.MEM_RESERVE|windows.MEM_COMMIT, windows.PAGE_READWRITE); err != nil {
+			return nil, fmt.Errorf("failed to allocate %d bytes for %s: %w", size, name, err)
+		}
+	}
+	mod := &memoryModule{name: name, base: base, forwards: make(map[string]windows.Handle)}
+
+	// The image is laid out in Go memory, then copied into place in one write.
+	mem := make([]byte, size)
+	copy(mem, image[:headerSize])
+	for _, s := range f.Sections {
+		data, err := s.Data()
+		if err != nil {
+			mod.free()
+			return nil, fmt.Errorf("failed to read section %s of %s: %w", s.Name, name, err)
+		}
+		if s.VirtualAddress >= size {
+			continue
+		}
+		copy(mem[s.VirtualAddress:], data)
+	}
+
+	if delta := uint64(base) - imageBase; delta != 0 {
+		if err := relocate(mem, directory(pe.IMAGE_DIRECTORY_ENTRY_BASERELOC), delta); err != nil {
+			mod.free()
+			return nil, fmt.Errorf("failed to relocate %s: %w", name, err)
+		}
+	}
+	if mod.imports, err = bindImports(mem, directory(pe.IMAGE_DIRECTORY_ENTRY_IMPORT), is64); err != nil {
+		mod.free()
+		return nil, fmt.Errorf("failed to bind imports of %s: %w", name, err)
+	}
+	if mod.tls, err = tlsCallbacks(mem, directory(pe.IMAGE_DIRECTORY_ENTRY_TLS), base, is64); err != nil {
+		mod.free()
+		return nil, fmt.Errorf("failed to read TLS callbacks of %s: %w", name, err)
+	}
+	if mod.exports, err = pefile.ReadExports(bytes.NewReader(image)); err != nil {
+		mod.free()
+		return nil, fmt.Errorf("failed to read export table of %s: %w", name, err)
+	}
+
+	if err := WriteMemory(base, mem); err != nil {
+		mod.free()
+		return nil, err
+	}
+	if err := protectSections(base, headerSize, f.Sections); err != nil {
+		mod.free()
+		return nil, fmt.Errorf("failed to protect sections of %s: %w", name, err)
+	}
+	procFlushInstructionCache.Call(uintptr(windows.CurrentProcess()), base, uintptr(size))
+
+	// 64-bit code needs its unwind data registered for exceptions to pass through it.
+	if pdata := directory(pe.IMAGE_DIRECTORY_ENTRY_EXCEPTION); is64 && pdata.Size > 0 {
+		count := pdata.Size / runtimeFunctionSize()
+		if r, _, _ := procRtlAddFunctionTable.Call(base+uintptr(pdata.VirtualAddress), uintptr(count), base); r != 0 {
+			mod.pdata = base + uintptr(pdata.VirtualAddress)
+		}
+	}
+
+	for _, cb := range mod.tls {
+		syscall.SyscallN(cb, base, dllProcessAttach, 0)
+	}
+	if entry != 0 {
+		mod.entry = base + uintptr(entry)
+		if r, _, _ := syscall.SyscallN(mod.entry, base, dllProcessAttach, 0); r == 0 {
+			// A failed DLL_PROCESS_ATTACH gets no DLL_PROCESS_DETACH.
+			mod.entry = 0
+			mod.free()
+			return nil, fmt.Errorf("entry point of %s failed to initialize", name)
+		}
+	}
+	return mod, nil
+}
+
+func (mod *memoryModule) Name() string           { return mod.name }
+func (mod *memoryModule) Handle() windows.Handle { return 0 }
+func (mod *memoryModule) baseAddress() uintptr   { return mod.base }
+
+func (mod *memoryModule) FindProc(name string) (uintptr, error) {
+	exp, ok := mod.exports.Lookup(name)
+	if !ok {
+		return 0, fmt.Errorf("export %s not found in %s", name, mod.name)
+	}
+	return mod.address(exp)
+}
+
+func (mod *memoryModule) FindProcByOrdinal(ordinal uint16) (uintptr, error) {
+	exp, ok := mod.exports.LookupOrdinal(ordinal)
+	if !ok {
+		return 0, fmt.Errorf("export #%d not found in %s", ordinal, mod.name)
+	}
+	return mod.address(exp)
+}
+
+// address returns the address of exp, resolving forwarders through the system loader.
+func (mod *memoryModule) address(exp pefile.Export) (uintptr, error) {
+	if exp.Forwarder == "" {
+		return mod.base + uintptr(exp.RVA), nil
+	}
+
+	module, target, ok := strings.Cut(exp.Forwarder, ".")
+	if !ok {
+		return 0, fmt.Errorf("malformed forwarder %q in %s", exp.Forwarder, mod.name)
+	}
+	key := strings.ToLower(module)
+	mod.mu.Lock()
+	h, ok := mod.forwards[key]
+	if !ok {
+		var err error
+		if h, err = windows.LoadLibrary(module + ".dll"); err != nil {
+			mod.mu.Unlock()
+			return 0, fmt.Errorf("failed to load forwarder target %s: %w", module, err)
+		}
+		mod.forwards[key] = h
+	}
+	mod.mu.Unlock()
+
+	if ordinal, ok := parseOrdinal(target); ok {
+		return windows.GetProcAddressByOrdinal(h, uintptr(ordinal))
+	}
+	return windows.GetProcAddress(h, target)
+}
+
+// exportTable returns the module's export table, which has no file to be read from.
+func (mod *memoryModule) exportTable() *pefile.ExportTable {
+	return mod.exports
+}
+
+func (mod *memoryModule) Release() error {
+	for i := len(mod.tls) - 1; i >= 0; i-- {
+		syscall.SyscallN(mod.tls[i], mod.base, dllProcessDetach, 0)
+	}
+	if mod.entry != 0 {
+		syscall.SyscallN(mod.entry, mod.base, dllProcessDetach, 0)
+	}
+	return mod.free()
+}
+
+// free unmaps the module and releases the modules it loaded, without running detach code.
+func (mod *memoryModule) free() error {
+	if mod.pdata != 0 {
+		procRtlDeleteFunctionTable.Call(mod.pdata)
+	}
+	var errs []error
+	for _, h := range mod.forwards {
+		errs = append(errs, windows.FreeLibrary(h))
+	}
+	for _, h := range mod.imports {
+		errs = append(errs, windows.FreeLibrary(h))
+	}
+	errs = append(errs, windows.VirtualFree(mod.base, 0, windows.MEM_RELEASE))
+	return errors.Join(errs...)
+}
+
+// runtimeFunctionSize is the size of a RUNTIME_FUNCTION entry in the exception directory.
+func runtimeFunctionSize() uint32 {
+	if runtime.GOARCH == "arm64" {
+		return 8
+	}
+	return 12
+}
+
+// relocate applies the base relocations in dir to mem, for an image moved by delta.
+func relocate(mem []byte, dir pe.DataDirectory, delta uint64) error {
+	if dir.Size == 0 {
+		return errors.New("image has no relocations")
+	}
+	block, end := uint64(dir.VirtualAddress), uint64(dir.VirtualAddress)+uint64(dir.Size)
+	if end > uint64(len(mem)) {
+		return errors.New("relocation directory is outside the image")
+	}
+	for block+8 <= end {
+		page := uint64(binary.LittleEndian.Uint32(mem[block:]))
+		blockSize := uint64(binary.LittleEndian.Uint32(mem[block+4:]))
+		if blockSize < 8 || block+blockSize > end {
+			return fmt.Errorf("relocation block at %#x is corrupt", block)
+		}
+		for off := block + 8; off+2 <= block+blockSize; off += 2 {
+			entry := binary.LittleEndian.Uint16(mem[off:])
+			at := page + uint64(entry&0xFFF)
+			switch entry >> 12 {
+			case imageRelBasedAbsolute:
+			case imageRelBasedHighLow:
+				if at+4 > uint64(len(mem)) {
+					return fmt.Errorf("relocation at %#x is outside the image", at)
+				}
+				binary.LittleEndian.PutUint32(mem[at:], binary.LittleEndian.Uint32(mem[at:])+uint32(delta))
+			case imageRelBasedDir64:
+				if at+8 > uint64(len(mem)) {
+					return fmt.Errorf("relocation at %#x is outside the image", at)
+				}
+				binary.LittleEndian.PutUint64(mem[at:], binary.LittleEndian.Uint64(mem[at:])+delta)
+			default:
+				return fmt.Errorf("unsupported relocation type %d at %#x", entry>>12, at)
+			}
+		}
+		block += blockSize
+	}
+	return nil
+}
+
+// bindImports loads the modules imported by the image in mem and fills in its import
+// address tables. It returns the loaded modules, to be released with the image.
+func bindImports(mem []byte, dir pe.DataDirectory, is64 bool) ([]windows.Handle, error) {
+	if dir.Size == 0 {
+		return nil, nil
+	}
+	thunkSize, ordinalFlag := uint64(4), uint64(1)<<31
+	if is64 {
+		thunkSize, ordinalFlag = 8, uint64(1)<<63
+	}
+	readThunk := func(at uint64) uint64 {
+		if is64 {
+			return binary.LittleEndian.Uint64(mem[at:])
+		}
+		return uint64(binary.LittleEndian.Uint32(mem[at:]))
+	}
+	writeThunk := func(at uint64, v uintptr) {
+		if is64 {
+			binary.LittleEndian.PutUint64(mem[at:], uint64(v))
+		} else {
+			binary.LittleEndian.PutUint32(mem[at:], uint32(v))
+		}
+	}
+
+	var loaded []windows.Handle
+	fail := func(err error) ([]windows.Handle, error) {
+		for _, h := range loaded {
+			windows.FreeLibrary(h)
+		}
+		return nil, err
+	}
+	// Each IMAGE_IMPORT_DESCRIPTOR is 20 bytes; a zeroed one ends the list.
+	for desc := uint64(dir.VirtualAddress); desc+20 <= uint64(len(mem)); desc += 20 {
+		lookup := uint64(binary.LittleEndian.Uint32(mem[desc:]))
+		nameRVA := uint64(binary.LittleEndian.Uint32(mem[desc+12:]))
+		iat := uint64(binary.LittleEndian.Uint32(mem[desc+16:]))
+		if nameRVA == 0 {
+			break
+		}
+		if lookup == 0 {
+			lookup = iat
+		}
+		module, err := cString(mem, nameRVA)
+		if err != nil {
+			return fail(err)
+		}
+		h, err := windows.LoadLibrary(module)
+		if err != nil {
+			return fail(fmt.Errorf("failed to load %s: %w", module, err))
+		}
+		loaded = append(loaded, h)
+
+		for i := uint64(0); ; i++ {
+			at, slot := lookup+i*thunkSize, iat+i*thunkSize
+			if at+thunkSize > uint64(len(mem)) || slot+thunkSize > uint64(len(mem)) {
+				return fail(fmt.Errorf("import table of %s is outside the image", module))
+			}
+			thunk := readThunk(at)
+			if thunk == 0 {
+				break
+			}
+			var addr uintptr
+			if thunk&ordinalFlag != 0 {
+				addr, err = windows.GetProcAddressByOrdinal(h, uintptr(thunk&0xFFFF))
+			} else {
+				// IMAGE_IMPORT_BY_NAME is a 2-byte hint followed by the name.
+				var fn string
+				if fn, err = cString(mem, (thunk&0x7FFFFFFF)+2); err == nil {
+					addr, err = windows.GetProcAddress(h, fn)
+				}
+			}
+			if err != nil {
+				return fail(fmt.Errorf("failed to resolve import %d of %s: %w", i, module, err))
+			}
+			writeThunk(slot, addr)
+		}
+	}
+	return loaded, nil
+}
+
+// tlsCallbacks returns the addresses of the TLS callbacks of the image in mem, mapped at base.
+func tlsCallbacks(mem []byte, dir pe.DataDirectory, base uintptr, is64 bool) ([]uintptr, error) {
+	if dir.Size == 0 {
+		return nil, nil
+	}
+	// AddressOfCallBacks is the fourth pointer-sized field of IMAGE_TLS_DIRECTORY.
+	ptrSize := uint64(4)
+	if is64 {
+		ptrSize = 8
+	}
+	readPtr := func(at uint64) (uint64, error) {
+		if at+ptrSize > uint64(len(mem)) {
+			return 0, fmt.Errorf("TLS data at %#x is outside the image", at)
+		}
+		if is64 {
+			return binary.LittleEndian.Uint64(mem[at:]), nil
+		}
+		return uint64(binary.LittleEndian.Uint32(mem[at:])), nil
+	}
+
+	list, err := readPtr(uint64(dir.VirtualAddress) + 3*ptrSize)
+	if err != nil || list == 0 {
+		return nil, err
+	}
+	var callbacks []uintptr
+	for at := list - uint64(base); ; at += ptrSize {
+		cb, err := readPtr(at)
+		if err != nil {
+			return nil, err
+		}
+		if cb == 0 {
+			return callbacks, nil
+		}
+		callbacks = append(callbacks, uintptr(cb))
+	}
+}
+
+// protectSections applies the page protection requested by each section's characteristics.
+func protectSections(base uintptr, headerSize uint32, sections []*pe.Section) error {
+	var old uint32
+	if err := windows.VirtualProtect(base, uintptr(headerSize), windows.PAGE_READONLY, &old); err != nil {
+		return err
+	}
+	for _, s := range sections {
+		size := max(s.VirtualSize, s.Size)
+		if size == 0 {
+			continue
+		}
+		if err := windows.VirtualProtect(base+uintptr(s.VirtualAddress), uintptr(size), sectionProtection(s.Characteristics), &old); err != nil {
+			return fmt.Errorf("section %s: %w", s.Name, err)
+		}
+	}
+	return nil
+}
+
+// sectionProtection returns the PAGE_* protection for section characteristics.
+func sectionProtection(c uint32) uint32 {
+	exec := c&pe.IMAGE_SCN_MEM_EXECUTE != 0
+	write := c&pe.IMAGE_SCN_MEM_WRITE != 0
+	read := c&pe.IMAGE_SCN_MEM_READ != 0
+	switch {
+	case exec && write:
+		return windows.PAGE_EXECUTE_READWRITE
+	case exec && read:
+		return windows.PAGE_EXECUTE_READ
+	case exec:
+		return windows.PAGE_EXECUTE
+	case write:
+		return windows.PAGE_READWRITE
+	case read:
+		return windows.PAGE_READONLY
+	default:
+		return windows.PAGE_NOACCESS
+	}
+}
+
+// cString reads the NUL-terminated string at offset at of mem.
+func cString(mem []byte, at uint64) (string, error) {
+	if at >= uint64(len(mem)) {
+		return "", fmt.Errorf("string at %#x is outside the image", at)
+	}
+	s, _, ok := bytes.Cut(mem[at:], []byte{0})
+	if !ok {
+		return "", fmt.Errorf("string at %#x is not terminated", at)
+	}
+	return string(s), nil
+}
@@ -0,0 +1,109 @@
+//go:build windows
+
+package proxdll
+
+import (
+	"errors"
+	"fmt"
+	"unicode/utf16"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// ErrNullPointer is returned by the memory readers for a NULL pointer argument.
+var ErrNullPointer = errors.New("proxdll: null pointer")
+
+// pageSize is the granularity at which memory is mapped, used to keep reads from
+// straddling into a page that may be unmapped.
+const pageSize = 4096
+
+// ReadMemory copies len(buf) bytes of process memory at p into buf.
+// Unlike dereferencing p, it returns an error instead of faulting if the memory is not
+// readable, so hooks and decoders can inspect arguments supplied by the host.
+func ReadMemory(p uintptr, buf []byte) error {
+	if p == 0 {
+		return ErrNullPointer
+	}
+	if len(buf) == 0 {
+		return nil
+	}
+	var n uintptr
+	if err := windows.ReadProcessMemory(windows.CurrentProcess(), p, &buf[0], uintptr(len(buf)), &n); err != nil {
+		return fmt.Errorf("failed to read %d bytes at %#x: %w", len(buf), p, err)
+	}
+	return nil
+}
+
+// WriteMemory copies buf into process memory at p, such as an output buffer of the host.
+// Like ReadMemory, it returns an error instead of faulting if the memory is not writable.
+func WriteMemory(p uintptr, buf []byte) error {
+	if p == 0 {
+		return ErrNullPointer
+	}
+	if len(buf) == 0 {
+		return nil
+	}
+	var n uintptr
+	if err := windows.WriteProcessMemory(windows.CurrentProcess(), p, &buf[0], uintptr(len(buf)), &n); err != nil {
+		return fmt.Errorf("failed to write %d bytes at %#x: %w", len(buf), p, err)
+	}
+	return nil
+}
+
+// ReadWString reads the NUL-terminated UTF-16 string at p, such as an LPCWSTR argument,
+// reading at most maxLen UTF-16 units. truncated reports whether the string continues
+// past maxLen. Unreadable memory is reported as an error rather than a fault.
+func ReadWString(p uintptr, maxLen int) (s string, truncated bool, err error) {
+	units := make([]uint16, 0, min(max(maxLen, 0), 64))
+	for addr := p; len(units) < maxLen; {
+		chunk := make([]uint16, chunkLen(addr, 2, maxLen-len(units)))
+		if err := ReadMemory(addr, unsafe.Slice((*byte)(unsafe.Pointer(&chunk[0])), 2*len(chunk))); err != nil {
+			return "", false, err
+		}
+		for _, c := range chunk {
+			if c == 0 {
+				return string(utf16.Decode(units)), false, nil
+			}
+			units = append(units, c)
+		}
+		addr += uintptr(2 * len(chunk))
+	}
+	return string(utf16.Decode(units)), true, nil
+}
+
+// ReadString reads the NUL-terminated ANSI string at p, such as an LPCSTR argument,
+// reading at most maxLen bytes. Bytes are returned unconverted, which is correct for
+// ASCII and UTF-8 text. Unreadable memory is reported as an error rather than a fault.
+func ReadString(p uintptr, maxLen int) (s string, truncated bool, err error) {
+	var out []byte
+	for addr := p; len(out) < maxLen; {
+		chunk := make([]byte, chunkLen(addr, 1, maxLen-len(out)))
+		if err := ReadMemory(addr, chunk); err != nil {
+			return "", false, err
+		}
+		for _, c := range chunk {
+			if c == 0 {
+				return string(out), false, nil
+			}
+			out = append(out, c)
+		}
+		addr += uintptr(len(chunk))
+	}
+	return string(out), true, nil
+}
+
+// chunkLen returns how many elements of size bytes to read at addr, at most limit,
+// without crossing the next page boundary unless a single element straddles it.
+func chunkLen(addr uintptr, size, limit int) int {
+	n := int(pageSize-addr%pageSize) / size
+	return max(1, min(n, limit))
+}
+
+// unreadableOrNull renders a pointer whose target could not be decoded.
+func unreadableOrNull(v uintptr) string {
+	if v == 0 {
+		return "NULL"
+	}
+	return unreadable(v)
+}
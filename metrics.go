@@ -0,0 +1,108 @@
+//go:build windows
+
+package proxdll
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// MetricsHandler returns an http.Handler serving the Manager's statistics in the
+// Prometheus text exposition format. The Manager must be created with WithStats.
+func (m *Manager) MetricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		m.writeMetrics(w)
+	})
+}
+
+// MetricsServer is an HTTP listener started by ServeMetrics.
+type MetricsServer struct {
+	srv *http.Server
+	ln  net.Listener
+}
+
+// ServeMetrics starts an HTTP server on addr exposing MetricsHandler at /metrics.
+// Use a loopback address such as "127.0.0.1:9464" unless remote scraping is intended.
+func (m *Manager) ServeMetrics(addr string) (*MetricsServer, error) {
+	if m.stats == nil {
+		return nil, errors.New("metrics require a Manager created with WithStats")
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen for metrics on %s: %w", addr, err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", m.MetricsHandler())
+	s := &MetricsServer{srv: &http.Server{Handler: mux}, ln: ln}
+	go s.srv.Serve(ln)
+	m.logger.Info("serving metrics", "addr", ln.Addr().String())
+	return s, nil
+}
+
+// Addr returns the address the server is listening on.
+func (s *MetricsServer) Addr() net.Addr {
+	return s.ln.Addr()
+}
+
+// Close stops the server.
+func (s *MetricsServer) Close() error {
+	return s.srv.Close()
+}
+
+// writeMetrics renders the current statistics in the Prometheus text format.
+func (m *Manager) writeMetrics(w io.Writer) {
+	stats := m.Stats()
+	names := make([]string, 0, len(stats))
+	for name := range stats {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	b := bufio.NewWriter(w)
+	defer b.Flush()
+
+	b.WriteString("# HELP proxdll_calls_total Calls made through the proxy.\n")
+	b.WriteString("# TYPE proxdll_calls_total counter\n")
+	for _, name := range names {
+		fmt.Fprintf(b, "proxdll_calls_total{func=%s} %d\n", promLabel(name), stats[name].Calls)
+	}
+
+	b.WriteString("# HELP proxdll_errors_total Calls that returned a last error other than ERROR_SUCCESS.\n")
+	b.WriteString("# TYPE proxdll_errors_total counter\n")
+	for _, name := range names {
+		fmt.Fprintf(b, "proxdll_errors_total{func=%s} %d\n", promLabel(name), stats[name].Errors)
+	}
+
+	b.WriteString("# HELP proxdll_call_duration_seconds Latency of calls made through the proxy.\n")
+	b.WriteString("# TYPE proxdll_call_duration_seconds histogram\n")
+	for _, name := range names {
+		s := stats[name]
+		label := promLabel(name)
+		var cumulative uint64
+		for i, count := range s.Buckets {
+			cumulative += count
+			le := "+Inf"
+			if i < len(LatencyBuckets) {
+				le = strconv.FormatFloat(LatencyBuckets[i].Seconds(), 'g', -1, 64)
+			}
+			fmt.Fprintf(b, "proxdll_call_duration_seconds_bucket{func=%s,le=%q} %d\n", label, le, cumulative)
+		}
+		fmt.Fprintf(b, "proxdll_call_duration_seconds_sum{func=%s} %s\n", label, strconv.FormatFloat(s.Total.Seconds(), 'g', -1, 64))
+		fmt.Fprintf(b, "proxdll_call_duration_seconds_count{func=%s} %d\n", label, s.Calls)
+	}
+}
+
+// promLabel quotes a label value using the Prometheus escaping rules.
+func promLabel(v string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	return `"` + r.Replace(v) + `"`
+}
@@ -0,0 +1,96 @@
+//go:build windows
+
+package proxdll
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// minidumpType selects what MiniDumpWriteDump includes: data segments, handles,
+// unloaded modules and thread information, which is enough to inspect globals and
+// stacks without the size of a full memory dump.
+const minidumpType = 0x1 | 0x4 | 0x20 | 0x1000
+
+// minidumpExceptionInfo mirrors MINIDUMP_EXCEPTION_INFORMATION, which dbghelp.h packs
+// to 4 bytes, so the pointer is stored as 32-bit halves to avoid padding after ThreadId.
+type minidumpExceptionInfo struct {
+	threadID       uint32
+	pointers       [unsafe.Sizeof(uintptr(0)) / 4]uint32
+	clientPointers uint32
+}
+
+// WriteMinidump writes a minidump of the current process to path, and next to it a
+// sidecar file with the suffix .calls.txt listing the recent calls made through the proxy.
+func (m *Manager) WriteMinidump(path string) error {
+	return m.writeMinidump(path, nil, nil)
+}
+
+// writeMinidump writes the dump, recording the exception described by ptrs if not nil.
+func (m *Manager) writeMinidump(path string, ptrs *exceptionPointers, report *CrashReport) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create minidump: %w", err)
+	}
+	defer f.Close()
+
+	var info *minidumpExceptionInfo
+	if ptrs != nil {
+		info = &minidumpExceptionInfo{threadID: windows.GetCurrentThreadId()}
+		*(*uintptr)(unsafe.Pointer(&info.pointers)) = uintptr(unsafe.Pointer(ptrs))
+	}
+	r, _, err := procMiniDumpWriteDump.Call(
+		uintptr(windows.CurrentProcess()),
+		uintptr(windows.GetCurrentProcessId()),
+		f.Fd(),
+		minidumpType,
+		uintptr(unsafe.Pointer(info)),
+		0, 0)
+	if r == 0 {
+		return fmt.Errorf("failed to write minidump %s: %w", path, err)
+	}
+
+	return m.writeCallsSidecar(strings.TrimSuffix(path, filepath.Ext(path))+".calls.txt", report)
+}
+
+// writeCallsSidecar writes the crash report, if any, and the recent calls to path.
+func (m *Manager) writeCallsSidecar(path string, report *CrashReport) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create call log: %w", err)
+	}
+	w := bufio.NewWriter(f)
+
+	var recent []RecentCall
+	if report != nil {
+		fmt.Fprintf(w, "crash: %s\n", report)
+		fmt.Fprintf(w, "time: %s\n\n", report.Time.Format(time.RFC3339Nano))
+		recent = report.Recent
+	} else {
+		recent = m.RecentCalls()
+	}
+	fmt.Fprintf(w, "recent calls (%d, oldest first):\n", len(recent))
+	for _, call := range recent {
+		fmt.Fprintf(w, "%s tid=%d %s(%s)\n", call.Start.Format("15:04:05.000000"), call.ThreadID, call.Func, formatArgs(call.Args))
+	}
+
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write call log: %w", err)
+	}
+	return f.Close()
+}
+
+// crashDumpPath returns a unique dump file name in dir for a crash at t.
+func crashDumpPath(dir string, t time.Time) string {
+	exe, _ := os.Executable()
+	name := strings.TrimSuffix(filepath.Base(exe), filepath.Ext(exe))
+	return filepath.Join(dir, fmt.Sprintf("%s-%d-%s.dmp", name, os.Getpid(), t.Format("20060102-150405.000")))
+}
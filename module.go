@@ -0,0 +1,176 @@
+//go:build windows
+
+package proxdll
+
+import (
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"syscall"
+
+	"golang.org/x/sys/windows"
+)
+
+// Module is a loaded DLL whose exports the Manager calls. The default implementation
+// wraps a module loaded by the system loader; others can serve modules mapped by hand,
+// mocks or other backends without changes to the Manager.
+type Module interface {
+	// Name returns the name or path the module was loaded as.
+	Name() string
+	// Handle returns the module's base address as known to the system loader,
+	// or zero if the system loader does not know the module.
+	Handle() windows.Handle
+	// FindProc returns the address of the export named name.
+	FindProc(name string) (uintptr, error)
+	// FindProcByOrdinal returns the address of the export with the given ordinal.
+	FindProcByOrdinal(ordinal uint16) (uintptr, error)
+	// Release unloads the module.
+	Release() error
+}
+
+// Loader loads the modules used by a Manager: the original DLL and the targets of its
+// forwarded exports. flags are the LoadLibraryEx flags given by WithLoadFlags.
+type Loader interface {
+	Load(path string, flags uint32) (Module, error)
+}
+
+// SystemLoader loads modules with LoadLibrary, or LoadLibraryEx when flags are given.
+// It is the default Loader.
+var SystemLoader Loader = systemLoader{}
+
+// dataFileFlags are the LoadLibraryEx flags that map a module without making it executable.
+const dataFileFlags = windows.LOAD_LIBRARY_AS_DATAFILE | windows.LOAD_LIBRARY_AS_DATAFILE_EXCLUSIVE | windows.LOAD_LIBRARY_AS_IMAGE_RESOURCE
+
+// errDataFile is returned when resolving a function of a module mapped as a data file.
+var errDataFile = errors.New("module is loaded as a data file and cannot be called")
+
+// isNotFound reports whether err from loading a module means that it, or a module it
+// imports, does not exist, as opposed to existing and failing to load.
+func isNotFound(err error) bool {
+	return errors.Is(err, fs.ErrNotExist) || errors.Is(err, windows.ERROR_MOD_NOT_FOUND)
+}
+
+type systemLoader struct{}
+
+func (systemLoader) Load(path string, flags uint32) (Module, error) {
+	if flags&windows.LOAD_LIBRARY_SEARCH_DLL_LOAD_DIR != 0 && !filepath.IsAbs(path) {
+		return nil, errors.New("LOAD_LIBRARY_SEARCH_DLL_LOAD_DIR requires an absolute path")
+	}
+	if flags != 0 {
+		h, err := windows.LoadLibraryEx(path, 0, uintptr(flags))
+		if err != nil {
+			return nil, err
+		}
+		return &systemModule{dll: &windows.DLL{Name: path, Handle: h}, data: flags&dataFileFlags != 0}, nil
+	}
+	dll, err := windows.LoadDLL(path)
+	if err != nil {
+		return nil, err
+	}
+	return &systemModule{dll: dll}, nil
+}
+
+// systemModule is a Module loaded by the system loader.
+// A module mapped as a data file only serves its file, for export tables and resources.
+type systemModule struct {
+	dll  *windows.DLL
+	data bool
+}
+
+func (s *systemModule) Name() string   { return s.dll.Name }
+func (s *systemModule) Release() error { return s.dll.Release() }
+
+func (s *systemModule) Handle() windows.Handle {
+	// The handle of a data file is not a module the loader knows.
+	if s.data {
+		return 0
+	}
+	return s.dll.Handle
+}
+
+// baseAddress returns where the module is mapped. LoadLibraryEx tags the handles of data
+// files in their low bits.
+func (s *systemModule) baseAddress() uintptr {
+	return uintptr(s.dll.Handle) &^ 3
+}
+
+func (s *systemModule) FindProc(name string) (uintptr, error) {
+	if s.data {
+		return 0, errDataFile
+	}
+	proc, err := s.dll.FindProc(name)
+	if err != nil {
+		return 0, err
+	}
+	return proc.Addr(), nil
+}
+
+func (s *systemModule) FindProcByOrdinal(ordinal uint16) (uintptr, error) {
+	if s.data {
+		return 0, errDataFile
+	}
+	proc, err := s.dll.FindProcByOrdinal(uintptr(ordinal))
+	if err != nil {
+		return 0, err
+	}
+	return proc.Addr(), nil
+}
+
+// Proc is a function exported by a Module.
+type Proc struct {
+	// Name is the export name, or "#N" for an export resolved by ordinal.
+	Name string
+	addr uintptr
+	// data is set for exports of variables, which must not be called.
+	data bool
+}
+
+// NewProc returns a Proc for the function at addr, for Module implementations and tests.
+func NewProc(name string, addr uintptr) *Proc {
+	return &Proc{Name: name, addr: addr}
+}
+
+// Addr returns the address of the function.
+func (p *Proc) Addr() uintptr {
+	return p.addr
+}
+
+// Call invokes the function with args, as windows.Proc.Call does. lastErr is always
+// non-nil and holds the thread's last error, which is only meaningful if the function
+// sets it.
+//
+//go:uintptrescapes
+func (p *Proc) Call(args ...uintptr) (r1, r2 uintptr, lastErr error) {
+	return syscall.SyscallN(p.addr, args...)
+}
+
+// moduleBase returns the address mod is mapped at, or zero if it is unknown.
+func moduleBase(mod Module) uintptr {
+	if b, ok := mod.(interface{ baseAddress() uintptr }); ok {
+		return b.baseAddress()
+	}
+	return uintptr(mod.Handle())
+}
+
+// findProc looks up funcName in mod, treating names of the form "#N" as ordinals.
+func findProc(mod Module, funcName string) (*Proc, error) {
+	var addr uintptr
+	var err error
+	if ordinal, ok := parseOrdinal(funcName); ok {
+		addr, err = mod.FindProcByOrdinal(ordinal)
+	} else {
+		addr, err = mod.FindProc(funcName)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Proc{Name: funcName, addr: callable(funcName, addr)}, nil
+}
+
+// moduleFile returns the path of the file backing mod, for parsing its export table.
+func moduleFile(mod Module) (string, error) {
+	if h := mod.Handle(); h != 0 {
+		return modulePath(h)
+	}
+	return mod.Name(), nil
+}
@@ -0,0 +1,86 @@
+//go:build windows
+
+package proxdll
+
+import (
+	"fmt"
+	"unicode/utf16"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// SetWString replaces argument i with a pointer to a NUL-terminated UTF-16 copy of s,
+// for LPCWSTR parameters. The memory stays valid until the hook returns, so it must
+// not be used if the original keeps the pointer; use AllocWString for that.
+func (c *Call) SetWString(i int, s string) error {
+	p, err := windows.UTF16PtrFromString(s)
+	if err != nil {
+		return err
+	}
+	c.pinner.Pin(p)
+	c.SetArg(i, uintptr(unsafe.Pointer(p)))
+	return nil
+}
+
+// SetString replaces argument i with a pointer to a NUL-terminated copy of s, for LPCSTR
+// parameters. s is copied byte for byte. The memory stays valid until the hook returns.
+func (c *Call) SetString(i int, s string) error {
+	p, err := windows.BytePtrFromString(s)
+	if err != nil {
+		return err
+	}
+	c.pinner.Pin(p)
+	c.SetArg(i, uintptr(unsafe.Pointer(p)))
+	return nil
+}
+
+// SetStruct replaces argument i of call with a pointer to a copy of v, such as a
+// substituted RECT. T must not contain Go pointers. The copy stays valid until the hook returns.
+func SetStruct[T any](call *Call, i int, v T) {
+	p := new(T)
+	*p = v
+	call.pinner.Pin(p)
+	call.SetArg(i, uintptr(unsafe.Pointer(p)))
+}
+
+// AllocWString copies s to a NUL-terminated UTF-16 string outside the Go heap, for
+// pointers the original may keep after the call returns. Release it with FreeMemory.
+func AllocWString(s string) (uintptr, error) {
+	units := utf16.Encode([]rune(s))
+	for _, u := range units {
+		if u == 0 {
+			return 0, fmt.Errorf("string %q contains a NUL character", s)
+		}
+	}
+	units = append(units, 0)
+	return AllocBytes(unsafe.Slice((*byte)(unsafe.Pointer(&units[0])), 2*len(units)))
+}
+
+// AllocString copies s to a NUL-terminated byte string outside the Go heap. Release it with FreeMemory.
+func AllocString(s string) (uintptr, error) {
+	b, err := windows.ByteSliceFromString(s)
+	if err != nil {
+		return 0, err
+	}
+	return AllocBytes(b)
+}
+
+// AllocBytes copies b to memory outside the Go heap, for structs or buffers the original
+// may keep after the call returns. Release it with FreeMemory.
+func AllocBytes(b []byte) (uintptr, error) {
+	h, err := windows.LocalAlloc(windows.LMEM_FIXED, uint32(max(len(b), 1)))
+	if err != nil {
+		return 0, fmt.Errorf("failed to allocate %d bytes: %w", len(b), err)
+	}
+	copy(unsafe.Slice((*byte)(unsafe.Add(unsafe.Pointer(nil), h)), len(b)), b)
+	return h, nil
+}
+
+// FreeMemory releases memory returned by AllocWString, AllocString or AllocBytes.
+func FreeMemory(p uintptr) error {
+	if _, err := windows.LocalFree(windows.Handle(p)); err != nil {
+		return fmt.Errorf("failed to free %#x: %w", p, err)
+	}
+	return nil
+}
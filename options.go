@@ -0,0 +1,406 @@
+//go:build windows
+
+package proxdll
+
+import (
+	"errors"
+	"io/fs"
+	"log/slog"
+	"time"
+)
+
+// Option configures a Manager created by New.
+type Option func(*Manager)
+
+// WithLazyLoad defers loading the original DLL until the first function lookup.
+// This keeps New safe to call from DllMain, where loading libraries under the loader lock
+// can deadlock; any load error is then returned by the first lookup instead of by New.
+func WithLazyLoad() Option {
+	return func(m *Manager) {
+		m.lazy = true
+	}
+}
+
+// WithLogger sets the logger used to report loading and lookup events.
+// By default the Manager logs nothing.
+func WithLogger(logger *slog.Logger) Option {
+	return func(m *Manager) {
+		m.logger = logger
+	}
+}
+
+// WithLogLevel registers the LevelVar controlling the Manager's logger, so the level can
+// be changed at runtime, for example through the control channel.
+// The logger passed to WithLogger must use a handler configured with the same LevelVar.
+func WithLogLevel(level *slog.LevelVar) Option {
+	return func(m *Manager) {
+		m.logLevel = level
+	}
+}
+
+// WithLoadFlags loads the original DLL with LoadLibraryEx using the given LOAD_* flags,
+// instead of the default search order used by windows.LoadDLL. For example,
+// windows.LOAD_LIBRARY_SEARCH_SYSTEM32 only searches the system directory, and
+// windows.LOAD_WITH_ALTERED_SEARCH_PATH searches the directory of an absolute path first.
+// With LOAD_LIBRARY_AS_DATAFILE or LOAD_LIBRARY_AS_IMAGE_RESOURCE the original can be
+// inspected, with ListExports or VersionInfo, but its functions cannot be called.
+func WithLoadFlags(flags uint32) Option {
+	return func(m *Manager) {
+		m.loadFlags = flags
+	}
+}
+
+// WithPin keeps the original DLL loaded until the process exits, even after Free or other
+// FreeLibrary calls in the process, so procs cached by the Manager can never outlive it.
+// Loading fails if the original cannot be pinned, as for modules not loaded by the system loader.
+func WithPin() Option {
+	return func(m *Manager) {
+		m.pin = true
+	}
+}
+
+// WithFreeTimeout bounds how long Free waits for running calls to the original DLL to
+// return. By default Free waits for as long as they take.
+func WithFreeTimeout(timeout time.Duration) Option {
+	return func(m *Manager) {
+		m.freeTimeout = timeout
+	}
+}
+
+// WithStrictExports makes loading fail unless every named export can be resolved.
+// The resolved functions are cached, so missing exports surface once at load time
+// rather than as failures in the middle of host calls.
+func WithStrictExports(funcNames ...string) Option {
+	return func(m *Manager) {
+		m.required = append(m.required, funcNames...)
+	}
+}
+
+// WithMissingExportPolicy sets what happens when the original DLL lacks a function the
+// proxy forwards to: a panic in CallOriginal, a load failure, or a per-call error.
+func WithMissingExportPolicy(policy MissingExportPolicy) Option {
+	return func(m *Manager) {
+		m.missingPolicy = policy
+	}
+}
+
+// WithSystemDirectory loads the original from the system directory rather than the search path.
+// Only the base name of the path given to New is used, so New("version.dll", WithSystemDirectory())
+// loads the genuine version.dll instead of recursively loading the proxy itself.
+func WithSystemDirectory() Option {
+	return WithResolver(SystemDLLPath)
+}
+
+// WithResolver sets a Resolver that maps the path given to New to the original DLL to load.
+func WithResolver(resolver Resolver) Option {
+	return func(m *Manager) {
+		m.resolver = resolver
+	}
+}
+
+// WithTracer sends an Event for every call made through Call to tracer.
+// It may be given several times, and AddTracer adds tracers after construction.
+func WithTracer(tracer Tracer) Option {
+	return func(m *Manager) {
+		m.AddTracer(tracer)
+	}
+}
+
+// WithStats keeps per-export call counters and latencies, available from Stats.
+func WithStats() Option {
+	return func(m *Manager) {
+		m.stats = &statsTable{}
+	}
+}
+
+// WithConfig applies cfg when the Manager is created, see ApplyConfig.
+// WithConfig, WithConfigFile, WithAutoConfig and WithRegistryConfig replace one another;
+// the last one given selects the config.
+func WithConfig(cfg *Config) Option {
+	return func(m *Manager) {
+		m.configSource = func() (*Config, error) { return cfg, nil }
+		m.configFiles = nil
+	}
+}
+
+// WithConfigFile loads the config file at path with LoadConfig when the Manager is created.
+// New fails if the file cannot be read or is invalid.
+func WithConfigFile(path string) Option {
+	return func(m *Manager) {
+		m.configSource = func() (*Config, error) { return LoadConfig(path) }
+		m.configFiles = func() ([]string, error) { return []string{path}, nil }
+	}
+}
+
+// WithAutoConfig applies the config file found by FindConfig next to the proxy DLL, if there is one.
+// New still fails if the file exists but is invalid.
+func WithAutoConfig() Option {
+	return func(m *Manager) {
+		m.configSource = func() (*Config, error) {
+			path, err := FindConfig()
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil, nil
+			}
+			if err != nil {
+				return nil, err
+			}
+			m.logger.Debug("found config file", "path", path)
+			return LoadConfig(path)
+		}
+		m.configFiles = configCandidates
+	}
+}
+
+// WithConfigReload watches the file loaded by WithConfigFile or WithAutoConfig and applies
+// it again whenever it changes, see WatchConfig. With WithAutoConfig, the files FindConfig
+// looks for are watched even if none exists when the Manager is created, so a proxy
+// running on its default config picks up a file created later. It has no effect on
+// configs from WithConfig or WithRegistryConfig.
+func WithConfigReload() Option {
+	return func(m *Manager) {
+		m.configReload = true
+	}
+}
+
+// WithFuzzyConfigNames lets config files name exports loosely: an entry that is not an
+// export of the proxy, such as "createfilew" or "_CreateFileW@28", refers to the one
+// export equal to it ignoring case, surrounding space and a trailing stdcall decoration.
+// Each such match is logged as a warning, so the entry can be corrected.
+func WithFuzzyConfigNames() Option {
+	return func(m *Manager) {
+		m.fuzzyConfig = true
+	}
+}
+
+// WithEnvOverrides lets environment variables override the Manager's settings when it is
+// created, which is convenient when launching a host from a script. See EnvLog,
+// EnvOriginalPath, EnvConfig, EnvTraceFuncs and EnvDenyFuncs. Overrides also apply to
+// configs reloaded by WithConfigReload, and take precedence over other options.
+func WithEnvOverrides() Option {
+	return func(m *Manager) {
+		m.envOverrides = true
+	}
+}
+
+// WithRegistryConfig applies the config stored in the registry for dllName, if there is one,
+// see LoadRegistryConfig. An empty dllName uses the proxy DLL's own file name.
+// This avoids writing config files into protected install directories.
+func WithRegistryConfig(dllName string) Option {
+	return func(m *Manager) {
+		m.configFiles = nil
+		m.configSource = func() (*Config, error) {
+			name := dllName
+			if name == "" {
+				self, err := SelfPath()
+				if err != nil {
+					return nil, err
+				}
+				name = self
+			}
+			cfg, err := LoadRegistryConfig(name)
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil, nil
+			}
+			return cfg, err
+		}
+	}
+}
+
+// WithDefaultConfig applies the config file name from fsys when no other config is found,
+// so a proxy can ship with built-in settings that a config file, the registry or the
+// environment still override. It is meant for go:embed:
+//
+//	//go:embed proxdll.json
+//	var defaults embed.FS
+//
+//	proxdll.New("version_orig.dll", proxdll.WithAutoConfig(), proxdll.WithDefaultConfig(defaults, "proxdll.json"))
+//
+// New fails if the embedded file is invalid. The default config is not watched for changes.
+func WithDefaultConfig(fsys fs.FS, name string) Option {
+	return func(m *Manager) {
+		m.configDefault = func() (*Config, error) { return readConfigFS(fsys, name) }
+	}
+}
+
+// WithFlushInterval flushes tracers that implement Flusher every interval, so buffered
+// events survive the host exiting or crashing, when Shutdown cannot run.
+func WithFlushInterval(interval time.Duration) Option {
+	return func(m *Manager) {
+		m.flushInterval = interval
+	}
+}
+
+// WithCrashCapture installs an unhandled exception filter that, when a fatal exception
+// such as an access violation is raised during a call made through Call and not handled
+// by the host or the original DLL, logs a CrashReport naming the export, the thread and
+// the recent calls, and passes it to report if it is not nil. The exception then
+// proceeds to the filter installed before, or to the default handling that ends the process.
+//
+// Windows skips unhandled exception filters while a debugger is attached, and a host
+// installing its own filter later replaces this one. Tracking calls costs a lock per call.
+func WithCrashCapture(report func(*CrashReport)) Option {
+	return func(m *Manager) {
+		m.crashCapture = true
+		m.crashReport = report
+	}
+}
+
+// WithCrashDumps enables crash capture as WithCrashCapture does, and also writes a
+// minidump and a sidecar file listing the recent calls into dir for each crash report.
+func WithCrashDumps(dir string) Option {
+	return func(m *Manager) {
+		m.crashCapture = true
+		m.crashDumpDir = dir
+	}
+}
+
+// WithFlightRecorder keeps the last size calls made through Call, with their first
+// arguments, thread and start time, available from RecentCalls and DumpRecentCalls.
+// Recording takes no lock and is cheap enough to leave on when full tracing is not.
+// Crash capture enables it with 64 entries unless it is configured explicitly.
+func WithFlightRecorder(size int) Option {
+	return func(m *Manager) {
+		if size > 0 {
+			m.recorder = newFlightRecorder(size)
+		}
+	}
+}
+
+// WithCallers records the Caller of every traced call in its Event, so tracers can tell
+// which module of the host made it. Finding the caller walks the stack on each call, so
+// it is off by default; hooks can always ask for it with Call.Caller.
+func WithCallers() Option {
+	return func(m *Manager) {
+		m.callers = true
+	}
+}
+
+// WithStacks records up to depth frames of the host's stack in the Event of every traced
+// call to funcs, starting at the Caller. funcs are export names or hook patterns, and
+// none selects every export; an invalid pattern panics. Frames are resolved to functions
+// with dbghelp, which reads PDBs found next to each module or along the symbol path
+// (see WithSymbolPath) and falls back to exports.
+func WithStacks(depth int, funcs ...string) Option {
+	return func(m *Manager) {
+		m.stackDepth = min(depth, maxCallerFrames)
+		m.stackFuncs = nil
+		for _, name := range funcs {
+			p := mustHookPattern(name)
+			if p == nil {
+				p = &hookPattern{key: name, match: func(funcName string) bool { return funcName == name }}
+			}
+			m.stackFuncs = append(m.stackFuncs, p)
+		}
+	}
+}
+
+// WithSymbolPath sets where dbghelp looks for PDBs, in _NT_SYMBOL_PATH syntax, and
+// resolves the Caller of traced calls to a function as well, so traces read
+// "module!function+0xoffset" rather than addresses that change with every run. A
+// symbol server element such as "srv*C:\symbols*https://msdl.microsoft.com/download/symbols"
+// downloads PDBs into the local cache, which needs symsrv.dll next to the dbghelp.dll
+// in use. The path is shared by every Manager in the process.
+func WithSymbolPath(path string) Option {
+	return func(m *Manager) {
+		m.symbolPath = path
+	}
+}
+
+// WithUndecoratedNames sets the Undecorated field of events for MSVC-mangled C++
+// exports, so tracers show "public: int __thiscall Math::Add(int)" rather than
+// "?Add@Math@@QAEHH@Z". Hooks, config and signatures still use the mangled name.
+func WithUndecoratedNames() Option {
+	return func(m *Manager) {
+		m.undecorate = true
+	}
+}
+
+// WithFallback registers the result funcName returns when the original cannot be
+// reached, as SetFallback does.
+func WithFallback(funcName string, r1 uintptr, lastErr error) Option {
+	return func(m *Manager) {
+		m.fallbacks[funcName] = Fallback{R1: r1, LastErr: lastErr}
+	}
+}
+
+// WithReplay answers calls from a recording made with Manager.Record instead of the
+// original DLL, which is never loaded. Each export returns its recorded results in the
+// recorded order, writing recorded output buffers back to the host, and fails with
+// ErrNotRecorded once its recorded calls are used up. Hooks and tracers run as usual,
+// so hooks can be tested offline against a reproducible sequence of calls.
+func WithReplay(path string) Option {
+	return func(m *Manager) {
+		m.replayPath = path
+	}
+}
+
+// WithLoader loads the original DLL, and the targets of its forwarded exports, with
+// loader instead of SystemLoader.
+func WithLoader(loader Loader) Option {
+	return func(m *Manager) {
+		m.loader = loader
+	}
+}
+
+// WithEmbeddedImage maps the original DLL from image with LoadMemoryModule instead of
+// loading a file, so a proxy can ship the exact original it was built against:
+//
+//	//go:embed version_orig.dll
+//	var original []byte
+//
+//	m, err := proxdll.New("version_orig.dll", proxdll.WithEmbeddedImage(original))
+//
+// The path given to New only names the module. The targets of forwarded exports are still
+// loaded with the Loader. WithExpectedHash checks image itself; checks that need a file,
+// WithAuthenticode and WithMinVersion, make New fail.
+func WithEmbeddedImage(image []byte) Option {
+	return func(m *Manager) {
+		m.image = image
+	}
+}
+
+// WithExportValidation runs ValidateExports once the original DLL is loaded by New and
+// passes the result to report, which may be nil to only log what is missing.
+// It has no effect with WithLazyLoad; call ValidateExports after the first call instead.
+func WithExportValidation(report func(*ExportReport)) Option {
+	return func(m *Manager) {
+		m.validate = true
+		m.validateReport = report
+	}
+}
+
+// WithExpectedHash refuses to load an original DLL whose SHA-256 digest, in hex, is none
+// of sums, such as another proxy or a tampered file. Several sums allow several known
+// versions. See FileSHA256 and WithVerifyWarnOnly.
+func WithExpectedHash(sums ...string) Option {
+	return func(m *Manager) {
+		m.verifiers = append(m.verifiers, hashVerifier(sums))
+	}
+}
+
+// WithAuthenticode refuses to load an original DLL without a valid Authenticode signature,
+// embedded or through a system catalog, as checked by VerifyAuthenticode. With signers
+// given, the signing certificate's display name must also be one of them, compared
+// case-insensitively.
+func WithAuthenticode(signers ...string) Option {
+	return func(m *Manager) {
+		m.verifiers = append(m.verifiers, authenticodeVerifier(signers))
+	}
+}
+
+// WithMinVersion refuses to load an original DLL whose file version is older than required,
+// such as "10.0.19041", with a diagnostic naming both versions instead of obscure call
+// failures later. See ReadVersionInfo.
+func WithMinVersion(required string) Option {
+	return func(m *Manager) {
+		m.verifiers = append(m.verifiers, minVersionVerifier(required))
+	}
+}
+
+// WithVerifyWarnOnly logs original DLLs failing checks such as WithExpectedHash,
+// WithAuthenticode and WithMinVersion as warnings and loads them anyway, instead of refusing them.
+func WithVerifyWarnOnly() Option {
+	return func(m *Manager) {
+		m.verifyWarnOnly = true
+	}
+}
@@ -0,0 +1,53 @@
+//go:build windows
+
+package proxdll
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// GetOriginalFuncByOrdinal retrieves and caches a function exported by ordinal from the original DLL.
+// It shares the cache with GetOriginalFunc, where the same function is named "#N".
+func (m *Manager) GetOriginalFuncByOrdinal(ord uint32) (*Proc, error) {
+	if ord == 0 || ord > 0xFFFF {
+		return nil, fmt.Errorf("invalid export ordinal %d", ord)
+	}
+	return m.GetOriginalFunc(ordinalName(uint16(ord)))
+}
+
+// CallOriginalByOrdinal invokes the original function with the given ordinal.
+// Like TryCallOriginal, it reports lookup failures through lastErr instead of panicking.
+func (m *Manager) CallOriginalByOrdinal(ord uint32, args ...uintptr) (r1, r2 uintptr, lastErr error) {
+	gate, ok := m.enterCall()
+	if !ok {
+		return 0, 0, ErrFreed
+	}
+	defer gate.exit()
+
+	proc, err := m.GetOriginalFuncByOrdinal(ord)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return proc.Call(args...)
+}
+
+// ordinalName returns the "#N" lookup name for an ordinal.
+func ordinalName(ord uint16) string {
+	return "#" + strconv.Itoa(int(ord))
+}
+
+// parseOrdinal reports whether name has the form "#N" and returns N.
+func parseOrdinal(name string) (uint16, bool) {
+	digits, ok := strings.CutPrefix(name, "#")
+	if !ok {
+		return 0, false
+	}
+	ordinal, err := strconv.ParseUint(digits, 10, 16)
+	if err != nil {
+		return 0, false
+	}
+	return uint16(ordinal), true
+}
@@ -0,0 +1,326 @@
+// Package pefile implements the parts of PE image parsing needed to build proxy DLLs.
+// It is pure Go and has no Windows dependencies, so tools can inspect DLLs on any platform.
+package pefile
+
+import (
+	"debug/pe"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// maxNameLen bounds the length of strings read from the export directory.
+const maxNameLen = 4096
+
+// Export describes a single entry in a DLL's export table.
+type Export struct {
+	// Name is the exported name, or empty if the function is exported by ordinal only.
+	Name string
+	// Ordinal is the biased ordinal, as used by GetProcAddress and import tables.
+	Ordinal uint16
+	// RVA is the relative virtual address of the exported symbol.
+	RVA uint32
+	// Forwarder is the "MODULE.Name" or "MODULE.#N" target of a forwarded export, or empty.
+	// The RVA of a forwarded export points at this string rather than at code or data.
+	Forwarder string
+	// Data reports whether the export is a variable rather than a function, judged by
+	// whether RVA lies in a section that is not executable.
+	Data bool
+}
+
+// ExportTable is the parsed export directory of a DLL.
+type ExportTable struct {
+	// DLLName is the module name recorded in the export directory.
+	DLLName string
+	// OrdinalBase is the ordinal of the first entry in the export address table.
+	OrdinalBase uint32
+	// Exports lists every export, ordered by ordinal.
+	Exports []Export
+}
+
+// Lookup returns the export with the given name.
+func (t *ExportTable) Lookup(name string) (Export, bool) {
+	for _, exp := range t.Exports {
+		if exp.Name == name {
+			return exp, true
+		}
+	}
+	return Export{}, false
+}
+
+// LookupOrdinal returns the export with the given ordinal.
+func (t *ExportTable) LookupOrdinal(ordinal uint16) (Export, bool) {
+	for _, exp := range t.Exports {
+		if exp.Ordinal == ordinal {
+			return exp, true
+		}
+	}
+	return Export{}, false
+}
+
+// NameOrdinals maps each exported name to its ordinal.
+func (t *ExportTable) NameOrdinals() map[string]uint16 {
+	m := make(map[string]uint16, len(t.Exports))
+	for _, exp := range t.Exports {
+		if exp.Name != "" {
+			m[exp.Name] = exp.Ordinal
+		}
+	}
+	return m
+}
+
+// OrdinalNames maps each ordinal to the names it is exported under, in export table order.
+// Ordinals exported without a name map to an empty slice.
+func (t *ExportTable) OrdinalNames() map[uint16][]string {
+	m := make(map[uint16][]string, len(t.Exports))
+	for _, exp := range t.Exports {
+		names := m[exp.Ordinal]
+		if exp.Name != "" {
+			names = append(names, exp.Name)
+		} else if names == nil {
+			names = []string{}
+		}
+		m[exp.Ordinal] = names
+	}
+	return m
+}
+
+// exportDirectory mirrors IMAGE_EXPORT_DIRECTORY.
+type exportDirectory struct {
+	Characteristics       uint32
+	TimeDateStamp         uint32
+	MajorVersion          uint16
+	MinorVersion          uint16
+	Name                  uint32
+	Base                  uint32
+	NumberOfFunctions     uint32
+	NumberOfNames         uint32
+	AddressOfFunctions    uint32
+	AddressOfNames        uint32
+	AddressOfNameOrdinals uint32
+}
+
+// OpenExports reads the export table of the PE file at path.
+func OpenExports(path string) (*ExportTable, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ReadExports(f)
+}
+
+// ReadExports reads the export table of the PE file provided by r.
+func ReadExports(r io.ReaderAt) (*ExportTable, error) {
+	img, err := newImage(r)
+	if err != nil {
+		return nil, err
+	}
+	defer img.Close()
+
+	return img.exports()
+}
+
+// image wraps a parsed PE file and translates RVAs to file contents.
+type image struct {
+	file *pe.File
+	dirs []pe.DataDirectory
+}
+
+func newImage(r io.ReaderAt) (*image, error) {
+	f, err := pe.NewFile(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse PE headers: %w", err)
+	}
+
+	var dirs []pe.DataDirectory
+	switch oh := f.OptionalHeader.(type) {
+	case *pe.OptionalHeader32:
+		dirs = oh.DataDirectory[:min(oh.NumberOfRvaAndSizes, uint32(len(oh.DataDirectory)))]
+	case *pe.OptionalHeader64:
+		dirs = oh.DataDirectory[:min(oh.NumberOfRvaAndSizes, uint32(len(oh.DataDirectory)))]
+	default:
+		f.Close()
+		return nil, errors.New("PE file has no optional header")
+	}
+
+	return &image{file: f, dirs: dirs}, nil
+}
+
+func (img *image) Close() error {
+	return img.file.Close()
+}
+
+// directory returns the data directory at index, or a zero entry if it is absent.
+func (img *image) directory(index int) pe.DataDirectory {
+	if index >= len(img.dirs) {
+		return pe.DataDirectory{}
+	}
+	return img.dirs[index]
+}
+
+// section returns the section containing rva.
+func (img *image) section(rva uint32) (*pe.Section, error) {
+	for _, s := range img.file.Sections {
+		size := max(s.VirtualSize, s.Size)
+		if rva >= s.VirtualAddress && rva < s.VirtualAddress+size {
+			return s, nil
+		}
+	}
+	return nil, fmt.Errorf("RVA %#x is not inside any section", rva)
+}
+
+// readAt fills p with the image contents starting at rva.
+func (img *image) readAt(p []byte, rva uint32) error {
+	s, err := img.section(rva)
+	if err != nil {
+		return err
+	}
+	if _, err := s.ReadAt(p, int64(rva-s.VirtualAddress)); err != nil {
+		return fmt.Errorf("failed to read %d bytes at RVA %#x: %w", len(p), rva, err)
+	}
+	return nil
+}
+
+// readUint32s reads n consecutive little-endian DWORDs starting at rva.
+func (img *image) readUint32s(rva, n uint32) ([]uint32, error) {
+	buf := make([]byte, 4*int(n))
+	if err := img.readAt(buf, rva); err != nil {
+		return nil, err
+	}
+	out := make([]uint32, n)
+	for i := range out {
+		out[i] = binary.LittleEndian.Uint32(buf[4*i:])
+	}
+	return out, nil
+}
+
+// readUint16s reads n consecutive little-endian WORDs starting at rva.
+func (img *image) readUint16s(rva, n uint32) ([]uint16, error) {
+	buf := make([]byte, 2*int(n))
+	if err := img.readAt(buf, rva); err != nil {
+		return nil, err
+	}
+	out := make([]uint16, n)
+	for i := range out {
+		out[i] = binary.LittleEndian.Uint16(buf[2*i:])
+	}
+	return out, nil
+}
+
+// readString reads a NUL-terminated ASCII string starting at rva.
+func (img *image) readString(rva uint32) (string, error) {
+	s, err := img.section(rva)
+	if err != nil {
+		return "", err
+	}
+
+	var name []byte
+	var chunk [64]byte
+	off := int64(rva - s.VirtualAddress)
+	for len(name) < maxNameLen {
+		n, err := s.ReadAt(chunk[:], off)
+		for _, b := range chunk[:n] {
+			if b == 0 {
+				return string(name), nil
+			}
+			name = append(name, b)
+		}
+		if err != nil {
+			return "", fmt.Errorf("unterminated string at RVA %#x: %w", rva, err)
+		}
+		off += int64(n)
+	}
+	return "", fmt.Errorf("string at RVA %#x exceeds %d bytes", rva, maxNameLen)
+}
+
+func (img *image) exports() (*ExportTable, error) {
+	dd := img.directory(pe.IMAGE_DIRECTORY_ENTRY_EXPORT)
+	if dd.VirtualAddress == 0 || dd.Size == 0 {
+		return &ExportTable{}, nil
+	}
+
+	var raw [40]byte
+	if err := img.readAt(raw[:], dd.VirtualAddress); err != nil {
+		return nil, fmt.Errorf("failed to read export directory: %w", err)
+	}
+	var dir exportDirectory
+	if _, err := binary.Decode(raw[:], binary.LittleEndian, &dir); err != nil {
+		return nil, fmt.Errorf("failed to decode export directory: %w", err)
+	}
+	// Aliases can give a directory more names than functions, but every name indexes
+	// the 16-bit ordinal table.
+	if dir.NumberOfFunctions > 0xFFFF || dir.NumberOfNames > 0xFFFF {
+		return nil, fmt.Errorf("export directory is corrupt: %d functions, %d names", dir.NumberOfFunctions, dir.NumberOfNames)
+	}
+
+	table := &ExportTable{OrdinalBase: dir.Base}
+	if dir.Name != 0 {
+		name, err := img.readString(dir.Name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read export directory name: %w", err)
+		}
+		table.DLLName = name
+	}
+	if dir.NumberOfFunctions == 0 {
+		return table, nil
+	}
+
+	funcs, err := img.readUint32s(dir.AddressOfFunctions, dir.NumberOfFunctions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read export address table: %w", err)
+	}
+
+	names := make(map[uint32][]string, dir.NumberOfNames)
+	if dir.NumberOfNames > 0 {
+		nameRVAs, err := img.readUint32s(dir.AddressOfNames, dir.NumberOfNames)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read export name table: %w", err)
+		}
+		indexes, err := img.readUint16s(dir.AddressOfNameOrdinals, dir.NumberOfNames)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read export ordinal table: %w", err)
+		}
+		for i, rva := range nameRVAs {
+			name, err := img.readString(rva)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read export name %d: %w", i, err)
+			}
+			names[uint32(indexes[i])] = append(names[uint32(indexes[i])], name)
+		}
+	}
+
+	for i, rva := range funcs {
+		// Unused slots in the address table have a zero RVA.
+		if rva == 0 {
+			continue
+		}
+		exp := Export{Ordinal: uint16(dir.Base + uint32(i)), RVA: rva}
+		// Addresses inside the export directory itself are forwarder strings.
+		if rva >= dd.VirtualAddress && rva < dd.VirtualAddress+dd.Size {
+			fwd, err := img.readString(rva)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read forwarder of ordinal %d: %w", exp.Ordinal, err)
+			}
+			exp.Forwarder = fwd
+		} else if s, err := img.section(rva); err == nil {
+			exp.Data = s.Characteristics&pe.IMAGE_SCN_MEM_EXECUTE == 0
+		}
+
+		aliases := names[uint32(i)]
+		if len(aliases) == 0 {
+			table.Exports = append(table.Exports, exp)
+			continue
+		}
+		// A single address may be exported under several names.
+		for _, name := range aliases {
+			exp.Name = name
+			table.Exports = append(table.Exports, exp)
+		}
+	}
+
+	return table, nil
+}
@@ -0,0 +1,365 @@
+package pefile
+
+import (
+	"bytes"
+	"debug/pe"
+	"encoding/binary"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+// Layout of the images testImage builds: code in .text, the export directory followed
+// by one page of variables in .rdata.
+const (
+	textRVA  = 0x1000
+	rdataRVA = 0x2000
+	dataSize = 0x100
+)
+
+// testFunc is one slot of a synthetic export address table.
+type testFunc struct {
+	names []string
+	// kind is "code", "data", "unused" for a zero RVA, or a forwarder string.
+	kind string
+}
+
+// testImage returns a minimal PE image exporting funcs from dllName, the first slot
+// at ordinal base. If pe32 is set the image has a 32-bit optional header.
+func testImage(t *testing.T, dllName string, base uint32, funcs []testFunc, pe32 bool) []byte {
+	t.Helper()
+
+	type namedSlot struct {
+		name  string
+		index uint16
+	}
+	var named []namedSlot
+	for i, fn := range funcs {
+		for _, name := range fn.names {
+			named = append(named, namedSlot{name, uint16(i)})
+		}
+	}
+	// The name table is sorted so the loader can binary search it.
+	slices.SortFunc(named, func(a, b namedSlot) int { return bytes.Compare([]byte(a.name), []byte(b.name)) })
+
+	eat := uint32(rdataRVA + 40)
+	namesRVA := eat + 4*uint32(len(funcs))
+	ordinalsRVA := namesRVA + 4*uint32(len(named))
+	stringsRVA := ordinalsRVA + 2*uint32(len(named))
+
+	var strs bytes.Buffer
+	addString := func(s string) uint32 {
+		rva := stringsRVA + uint32(strs.Len())
+		strs.WriteString(s)
+		strs.WriteByte(0)
+		return rva
+	}
+	dllNameRVA := addString(dllName)
+	nameRVAs := make([]uint32, len(named))
+	for i, n := range named {
+		nameRVAs[i] = addString(n.name)
+	}
+	fwdRVAs := make(map[int]uint32)
+	for i, fn := range funcs {
+		switch fn.kind {
+		case "code", "data", "unused":
+		default:
+			fwdRVAs[i] = addString(fn.kind)
+		}
+	}
+	dirSize := stringsRVA + uint32(strs.Len()) - rdataRVA
+	varsRVA := rdataRVA + (dirSize+15)&^15
+
+	var rdata bytes.Buffer
+	write := func(v any) {
+		if err := binary.Write(&rdata, binary.LittleEndian, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(exportDirectory{
+		Name:                  dllNameRVA,
+		Base:                  base,
+		NumberOfFunctions:     uint32(len(funcs)),
+		NumberOfNames:         uint32(len(named)),
+		AddressOfFunctions:    eat,
+		AddressOfNames:        namesRVA,
+		AddressOfNameOrdinals: ordinalsRVA,
+	})
+	for i, fn := range funcs {
+		switch fn.kind {
+		case "code":
+			write(uint32(textRVA + 16*i))
+		case "data":
+			write(varsRVA + uint32(8*i))
+		case "unused":
+			write(uint32(0))
+		default:
+			write(fwdRVAs[i])
+		}
+	}
+	write(nameRVAs)
+	for _, n := range named {
+		write(n.index)
+	}
+	rdata.Write(strs.Bytes())
+	rdata.Write(make([]byte, varsRVA+dataSize-rdataRVA-uint32(rdata.Len())))
+
+	text := bytes.Repeat([]byte{0xC3}, 16*max(len(funcs), 1))
+	return buildImage(t, pe32, []testSection{
+		{".text", textRVA, text, pe.IMAGE_SCN_CNT_CODE | pe.IMAGE_SCN_MEM_EXECUTE | pe.IMAGE_SCN_MEM_READ},
+		{".rdata", rdataRVA, rdata.Bytes(), pe.IMAGE_SCN_CNT_INITIALIZED_DATA | pe.IMAGE_SCN_MEM_READ},
+	}, pe.IMAGE_DIRECTORY_ENTRY_EXPORT, pe.DataDirectory{VirtualAddress: rdataRVA, Size: dirSize})
+}
+
+type testSection struct {
+	name            string
+	rva             uint32
+	data            []byte
+	characteristics uint32
+}
+
+// buildImage lays out a PE file with the given sections, and dd as its data directory
+// at index.
+func buildImage(t *testing.T, pe32 bool, sections []testSection, index int, dd pe.DataDirectory) []byte {
+	t.Helper()
+	const (
+		lfanew    = 0x40
+		fileAlign = 0x200
+	)
+
+	var dirs [16]pe.DataDirectory
+	dirs[index] = dd
+	var opt any
+	fh := pe.FileHeader{
+		Machine:          pe.IMAGE_FILE_MACHINE_AMD64,
+		NumberOfSections: uint16(len(sections)),
+		Characteristics:  pe.IMAGE_FILE_EXECUTABLE_IMAGE | pe.IMAGE_FILE_DLL,
+	}
+	if pe32 {
+		fh.Machine = pe.IMAGE_FILE_MACHINE_I386
+		fh.SizeOfOptionalHeader = uint16(binary.Size(pe.OptionalHeader32{}))
+		opt = &pe.OptionalHeader32{Magic: 0x10b, SectionAlignment: 0x1000, FileAlignment: fileAlign, NumberOfRvaAndSizes: 16, DataDirectory: dirs}
+	} else {
+		fh.SizeOfOptionalHeader = uint16(binary.Size(pe.OptionalHeader64{}))
+		opt = &pe.OptionalHeader64{Magic: 0x20b, SectionAlignment: 0x1000, FileAlignment: fileAlign, NumberOfRvaAndSizes: 16, DataDirectory: dirs}
+	}
+
+	var buf bytes.Buffer
+	write := func(v any) {
+		if err := binary.Write(&buf, binary.LittleEndian, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dos := make([]byte, lfanew)
+	copy(dos, "MZ")
+	binary.LittleEndian.PutUint32(dos[0x3c:], lfanew)
+	buf.Write(dos)
+	buf.WriteString("PE\x00\x00")
+	write(fh)
+	write(opt)
+
+	headersEnd := buf.Len() + 40*len(sections)
+	offset := uint32((headersEnd + fileAlign - 1) &^ (fileAlign - 1))
+	for _, s := range sections {
+		size := uint32((len(s.data) + fileAlign - 1) &^ (fileAlign - 1))
+		sh := pe.SectionHeader32{
+			VirtualSize:      uint32(len(s.data)),
+			VirtualAddress:   s.rva,
+			SizeOfRawData:    size,
+			PointerToRawData: offset,
+			Characteristics:  s.characteristics,
+		}
+		copy(sh.Name[:], s.name)
+		write(sh)
+		offset += size
+	}
+	for _, s := range sections {
+		buf.Write(make([]byte, (fileAlign-buf.Len()%fileAlign)%fileAlign))
+		buf.Write(s.data)
+	}
+	buf.Write(make([]byte, (fileAlign-buf.Len()%fileAlign)%fileAlign))
+	return buf.Bytes()
+}
+
+func TestReadExports(t *testing.T) {
+	tests := []struct {
+		name  string
+		base  uint32
+		funcs []testFunc
+		pe32  bool
+		want  []Export
+	}{
+		{
+			name: "named",
+			base: 1,
+			funcs: []testFunc{
+				{names: []string{"Beta"}, kind: "code"},
+				{names: []string{"Alpha"}, kind: "code"},
+			},
+			want: []Export{
+				{Name: "Beta", Ordinal: 1, RVA: textRVA},
+				{Name: "Alpha", Ordinal: 2, RVA: textRVA + 16},
+			},
+		},
+		{
+			name: "ordinal bias",
+			base: 100,
+			funcs: []testFunc{
+				{names: []string{"First"}, kind: "code"},
+				{kind: "unused"},
+				{names: []string{"Third"}, kind: "code"},
+			},
+			want: []Export{
+				{Name: "First", Ordinal: 100, RVA: textRVA},
+				{Name: "Third", Ordinal: 102, RVA: textRVA + 32},
+			},
+		},
+		{
+			name: "ordinal only",
+			base: 1,
+			funcs: []testFunc{
+				{names: []string{"Named"}, kind: "code"},
+				{kind: "code"},
+			},
+			want: []Export{
+				{Name: "Named", Ordinal: 1, RVA: textRVA},
+				{Ordinal: 2, RVA: textRVA + 16},
+			},
+		},
+		{
+			name: "aliases",
+			base: 1,
+			funcs: []testFunc{
+				{names: []string{"Open", "OpenA"}, kind: "code"},
+			},
+			want: []Export{
+				{Name: "Open", Ordinal: 1, RVA: textRVA},
+				{Name: "OpenA", Ordinal: 1, RVA: textRVA},
+			},
+		},
+		{
+			name: "forwarders",
+			base: 1,
+			funcs: []testFunc{
+				{names: []string{"ByName"}, kind: "KERNEL32.GetTickCount"},
+				{names: []string{"ByOrdinal"}, kind: "OTHER.#7"},
+				{kind: "OTHER.Hidden"},
+			},
+		},
+		{
+			name: "data",
+			base: 1,
+			funcs: []testFunc{
+				{names: []string{"Code"}, kind: "code"},
+				{names: []string{"Variable"}, kind: "data"},
+			},
+		},
+		{
+			name: "32-bit",
+			base: 5,
+			pe32: true,
+			funcs: []testFunc{
+				{names: []string{"_Func@8"}, kind: "code"},
+				{kind: "code"},
+			},
+			want: []Export{
+				{Name: "_Func@8", Ordinal: 5, RVA: textRVA},
+				{Ordinal: 6, RVA: textRVA + 16},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img := testImage(t, "test.dll", tt.base, tt.funcs, tt.pe32)
+			table, err := ReadExports(bytes.NewReader(img))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if table.DLLName != "test.dll" || table.OrdinalBase != tt.base {
+				t.Errorf("DLLName, OrdinalBase = %q, %d; want %q, %d", table.DLLName, table.OrdinalBase, "test.dll", tt.base)
+			}
+			if tt.want != nil && !reflect.DeepEqual(table.Exports, tt.want) {
+				t.Errorf("Exports = %+v\nwant %+v", table.Exports, tt.want)
+			}
+			checkKinds(t, table, tt.base, tt.funcs)
+		})
+	}
+}
+
+// checkKinds checks that every export of table has the forwarder and data flag funcs
+// gives its slot.
+func checkKinds(t *testing.T, table *ExportTable, base uint32, funcs []testFunc) {
+	t.Helper()
+	for _, exp := range table.Exports {
+		fn := funcs[uint32(exp.Ordinal)-base]
+		wantFwd := ""
+		switch fn.kind {
+		case "code", "data":
+		default:
+			wantFwd = fn.kind
+		}
+		if exp.Forwarder != wantFwd || exp.Data != (fn.kind == "data") {
+			t.Errorf("ordinal %d: Forwarder, Data = %q, %v; want %q, %v", exp.Ordinal, exp.Forwarder, exp.Data, wantFwd, fn.kind == "data")
+		}
+	}
+	var want int
+	for _, fn := range funcs {
+		if fn.kind != "unused" {
+			want += max(len(fn.names), 1)
+		}
+	}
+	if len(table.Exports) != want {
+		t.Errorf("got %d exports, want %d", len(table.Exports), want)
+	}
+}
+
+func TestReadExportsNoDirectory(t *testing.T) {
+	img := buildImage(t, false, []testSection{{".text", textRVA, []byte{0xC3}, pe.IMAGE_SCN_MEM_EXECUTE}}, pe.IMAGE_DIRECTORY_ENTRY_EXPORT, pe.DataDirectory{})
+	table, err := ReadExports(bytes.NewReader(img))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(table.Exports) != 0 {
+		t.Errorf("Exports = %+v, want none", table.Exports)
+	}
+}
+
+func TestReadExportsCorrupt(t *testing.T) {
+	img := testImage(t, "test.dll", 1, []testFunc{{names: []string{"A"}, kind: "code"}}, false)
+	f, err := pe.NewFile(bytes.NewReader(img))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The directory starts .rdata, and NumberOfFunctions is its sixth DWORD.
+	binary.LittleEndian.PutUint32(img[f.Section(".rdata").Offset+20:], 0x10000)
+	if _, err := ReadExports(bytes.NewReader(img)); err == nil {
+		t.Error("ReadExports accepted more functions than ordinals")
+	}
+}
+
+func TestExportTableMaps(t *testing.T) {
+	img := testImage(t, "test.dll", 10, []testFunc{
+		{names: []string{"Open", "OpenA"}, kind: "code"},
+		{kind: "code"},
+		{names: []string{"Close"}, kind: "code"},
+	}, false)
+	table, err := ReadExports(bytes.NewReader(img))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantNames := map[string]uint16{"Open": 10, "OpenA": 10, "Close": 12}
+	if got := table.NameOrdinals(); !reflect.DeepEqual(got, wantNames) {
+		t.Errorf("NameOrdinals = %v, want %v", got, wantNames)
+	}
+	wantOrdinals := map[uint16][]string{10: {"Open", "OpenA"}, 11: {}, 12: {"Close"}}
+	if got := table.OrdinalNames(); !reflect.DeepEqual(got, wantOrdinals) {
+		t.Errorf("OrdinalNames = %v, want %v", got, wantOrdinals)
+	}
+	if exp, ok := table.LookupOrdinal(11); !ok || exp.Name != "" {
+		t.Errorf("LookupOrdinal(11) = %+v, %v; want the unnamed export", exp, ok)
+	}
+	if _, ok := table.Lookup("Missing"); ok {
+		t.Error("Lookup found a missing name")
+	}
+}
@@ -0,0 +1,196 @@
+package pefile
+
+import (
+	"debug/pe"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"unicode/utf16"
+)
+
+// Resource types, the RT_* values of a resource's Type.
+const (
+	ResourceIcon      = 3
+	ResourceGroupIcon = 14
+	ResourceVersion   = 16
+	ResourceManifest  = 24
+)
+
+// maxResourceSize bounds the size of a single resource read from the resource directory.
+const maxResourceSize = 64 << 20
+
+// ResourceID identifies a resource type or name, either by Name or, if Name is empty, by ID.
+type ResourceID struct {
+	Name string
+	ID   uint16
+}
+
+// String returns the name, or "#N" for a numeric ID.
+func (id ResourceID) String() string {
+	if id.Name != "" {
+		return id.Name
+	}
+	return fmt.Sprintf("#%d", id.ID)
+}
+
+// Resource is a single entry of a PE file's resource directory.
+type Resource struct {
+	Type ResourceID
+	Name ResourceID
+	// Lang is the language ID, such as 0x409 for en-US.
+	Lang uint16
+	// CodePage is the code page recorded for the data, usually zero.
+	CodePage uint32
+	Data     []byte
+}
+
+// OpenResources reads every resource of the PE file at path.
+func OpenResources(path string) ([]Resource, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ReadResources(f)
+}
+
+// ReadResources reads every resource of the PE file provided by r, ordered as in the
+// resource directory: by type, then name, then language.
+func ReadResources(r io.ReaderAt) ([]Resource, error) {
+	img, err := newImage(r)
+	if err != nil {
+		return nil, err
+	}
+	defer img.Close()
+
+	return img.resources()
+}
+
+// resourceEntry mirrors IMAGE_RESOURCE_DIRECTORY_ENTRY.
+type resourceEntry struct {
+	Name         uint32
+	OffsetToData uint32
+}
+
+// resourceDataEntry mirrors IMAGE_RESOURCE_DATA_ENTRY.
+type resourceDataEntry struct {
+	OffsetToData uint32
+	Size         uint32
+	CodePage     uint32
+	Reserved     uint32
+}
+
+// resourceSubdirectory is set in OffsetToData for entries pointing at another directory,
+// and in Name for entries named by a string.
+const resourceSubdirectory = 1 << 31
+
+func (img *image) resources() ([]Resource, error) {
+	dd := img.directory(pe.IMAGE_DIRECTORY_ENTRY_RESOURCE)
+	if dd.VirtualAddress == 0 || dd.Size == 0 {
+		return nil, nil
+	}
+
+	var out []Resource
+	types, err := img.resourceDirectory(dd.VirtualAddress, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read resource directory: %w", err)
+	}
+	for _, typ := range types {
+		typeID, err := img.resourceID(dd.VirtualAddress, typ.Name)
+		if err != nil {
+			return nil, err
+		}
+		names, err := img.resourceSubdirectory(dd.VirtualAddress, typ)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read resources of type %v: %w", typeID, err)
+		}
+		for _, name := range names {
+			nameID, err := img.resourceID(dd.VirtualAddress, name.Name)
+			if err != nil {
+				return nil, err
+			}
+			langs, err := img.resourceSubdirectory(dd.VirtualAddress, name)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read resource %v/%v: %w", typeID, nameID, err)
+			}
+			for _, lang := range langs {
+				res := Resource{Type: typeID, Name: nameID, Lang: uint16(lang.Name)}
+				if lang.OffsetToData&resourceSubdirectory != 0 {
+					return nil, fmt.Errorf("resource %v/%v is nested too deeply", typeID, nameID)
+				}
+				if res.Data, res.CodePage, err = img.resourceData(dd.VirtualAddress + lang.OffsetToData); err != nil {
+					return nil, fmt.Errorf("failed to read resource %v/%v: %w", typeID, nameID, err)
+				}
+				out = append(out, res)
+			}
+		}
+	}
+	return out, nil
+}
+
+// resourceDirectory reads the entries of the IMAGE_RESOURCE_DIRECTORY at offset off of
+// the resource directory starting at root.
+func (img *image) resourceDirectory(root, off uint32) ([]resourceEntry, error) {
+	var hdr [16]byte
+	if err := img.readAt(hdr[:], root+off); err != nil {
+		return nil, err
+	}
+	n := uint32(binary.LittleEndian.Uint16(hdr[12:])) + uint32(binary.LittleEndian.Uint16(hdr[14:]))
+	raw, err := img.readUint32s(root+off+16, 2*n)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]resourceEntry, n)
+	for i := range entries {
+		entries[i] = resourceEntry{Name: raw[2*i], OffsetToData: raw[2*i+1]}
+	}
+	return entries, nil
+}
+
+// resourceSubdirectory reads the directory entry e points at.
+func (img *image) resourceSubdirectory(root uint32, e resourceEntry) ([]resourceEntry, error) {
+	if e.OffsetToData&resourceSubdirectory == 0 {
+		return nil, fmt.Errorf("entry at %#x is data, not a directory", e.OffsetToData)
+	}
+	return img.resourceDirectory(root, e.OffsetToData&^resourceSubdirectory)
+}
+
+// resourceID decodes the Name field of a directory entry.
+func (img *image) resourceID(root, name uint32) (ResourceID, error) {
+	if name&resourceSubdirectory == 0 {
+		return ResourceID{ID: uint16(name)}, nil
+	}
+	// IMAGE_RESOURCE_DIR_STRING_U is a length followed by that many UTF-16 units.
+	at := root + name&^resourceSubdirectory
+	length, err := img.readUint16s(at, 1)
+	if err != nil {
+		return ResourceID{}, fmt.Errorf("failed to read resource name: %w", err)
+	}
+	units, err := img.readUint16s(at+2, uint32(length[0]))
+	if err != nil {
+		return ResourceID{}, fmt.Errorf("failed to read resource name: %w", err)
+	}
+	return ResourceID{Name: string(utf16.Decode(units))}, nil
+}
+
+// resourceData reads the IMAGE_RESOURCE_DATA_ENTRY at rva and the data it describes.
+func (img *image) resourceData(rva uint32) ([]byte, uint32, error) {
+	var raw [16]byte
+	if err := img.readAt(raw[:], rva); err != nil {
+		return nil, 0, err
+	}
+	var entry resourceDataEntry
+	if _, err := binary.Decode(raw[:], binary.LittleEndian, &entry); err != nil {
+		return nil, 0, err
+	}
+	if entry.Size > maxResourceSize {
+		return nil, 0, fmt.Errorf("resource of %d bytes exceeds %d bytes", entry.Size, maxResourceSize)
+	}
+	data := make([]byte, entry.Size)
+	if err := img.readAt(data, entry.OffsetToData); err != nil {
+		return nil, 0, err
+	}
+	return data, entry.CodePage, nil
+}
@@ -0,0 +1,228 @@
+package pefile
+
+import (
+	"bytes"
+	"debug/pe"
+	"encoding/binary"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf16"
+)
+
+// rsrcRVA is where the images resourceImage builds map their .rsrc section.
+const rsrcRVA = 0x3000
+
+// resourceOffsets locates fields of a section built by resourceSection, as offsets into
+// the section, so tests can corrupt them.
+type resourceOffsets struct {
+	// firstLang is the OffsetToData of the first language entry.
+	firstLang int
+	// firstData is the first IMAGE_RESOURCE_DATA_ENTRY.
+	firstData int
+	// firstName is the length of the first name string.
+	firstName int
+}
+
+// testResourceNode is a directory, or for leaves a language entry, of a resource tree.
+type testResourceNode struct {
+	id       ResourceID
+	children []*testResourceNode
+	res      *Resource
+	off      int
+}
+
+func (n *testResourceNode) child(id ResourceID) *testResourceNode {
+	for _, c := range n.children {
+		if c.id == id {
+			return c
+		}
+	}
+	c := &testResourceNode{id: id}
+	n.children = append(n.children, c)
+	return c
+}
+
+// resourceSection returns a .rsrc section holding resources, in the order given, which
+// must be directory order: by type, then name, then language, named entries first.
+func resourceSection(resources []Resource) ([]byte, resourceOffsets) {
+	root := &testResourceNode{}
+	for i := range resources {
+		res := &resources[i]
+		root.child(res.Type).child(res.Name).child(ResourceID{ID: res.Lang}).res = res
+	}
+
+	// Directories come first, breadth first, then data entries, names and data.
+	dirs := []*testResourceNode{root}
+	var leaves []*testResourceNode
+	for i := 0; i < len(dirs); i++ {
+		for _, c := range dirs[i].children {
+			if c.res != nil {
+				leaves = append(leaves, c)
+			} else {
+				dirs = append(dirs, c)
+			}
+		}
+	}
+	off := 0
+	for _, d := range dirs {
+		d.off = off
+		off += 16 + 8*len(d.children)
+	}
+	for _, l := range leaves {
+		l.off = off
+		off += 16
+	}
+	offs := resourceOffsets{firstLang: -1, firstName: -1}
+	names := make(map[string]int)
+	for _, d := range dirs[1:] {
+		if _, ok := names[d.id.Name]; d.id.Name != "" && !ok {
+			names[d.id.Name] = off
+			if offs.firstName < 0 {
+				offs.firstName = off
+			}
+			off += 2 + 2*len(utf16.Encode([]rune(d.id.Name)))
+		}
+	}
+	dataOffs := make([]int, len(leaves))
+	for i, l := range leaves {
+		off = (off + 7) &^ 7
+		dataOffs[i] = off
+		off += len(l.res.Data)
+	}
+	section := make([]byte, off)
+
+	le := binary.LittleEndian
+	for _, d := range dirs {
+		var named uint16
+		for _, c := range d.children {
+			if c.id.Name != "" {
+				named++
+			}
+		}
+		le.PutUint16(section[d.off+12:], named)
+		le.PutUint16(section[d.off+14:], uint16(len(d.children))-named)
+		for i, c := range d.children {
+			entry := d.off + 16 + 8*i
+			if c.id.Name != "" {
+				le.PutUint32(section[entry:], uint32(names[c.id.Name])|resourceSubdirectory)
+			} else {
+				le.PutUint32(section[entry:], uint32(c.id.ID))
+			}
+			if c.res != nil {
+				if offs.firstLang < 0 {
+					offs.firstLang = entry + 4
+				}
+				le.PutUint32(section[entry+4:], uint32(c.off))
+			} else {
+				le.PutUint32(section[entry+4:], uint32(c.off)|resourceSubdirectory)
+			}
+		}
+	}
+	for name, at := range names {
+		units := utf16.Encode([]rune(name))
+		le.PutUint16(section[at:], uint16(len(units)))
+		for i, u := range units {
+			le.PutUint16(section[at+2+2*i:], u)
+		}
+	}
+	offs.firstData = leaves[0].off
+	for i, l := range leaves {
+		le.PutUint32(section[l.off:], rsrcRVA+uint32(dataOffs[i]))
+		le.PutUint32(section[l.off+4:], uint32(len(l.res.Data)))
+		le.PutUint32(section[l.off+8:], l.res.CodePage)
+		copy(section[dataOffs[i]:], l.res.Data)
+	}
+	return section, offs
+}
+
+// resourceImage returns a PE image whose resource directory is section.
+func resourceImage(t *testing.T, section []byte) []byte {
+	t.Helper()
+	return buildImage(t, false, []testSection{
+		{".rsrc", rsrcRVA, section, pe.IMAGE_SCN_CNT_INITIALIZED_DATA | pe.IMAGE_SCN_MEM_READ},
+	}, pe.IMAGE_DIRECTORY_ENTRY_RESOURCE, pe.DataDirectory{VirtualAddress: rsrcRVA, Size: uint32(len(section))})
+}
+
+// testResources has named and numeric types and names, and several languages.
+var testResources = []Resource{
+	{Type: ResourceID{Name: "CUSTOM"}, Name: ResourceID{Name: "Greeting"}, Lang: 0x409, CodePage: 1252, Data: []byte("hello")},
+	{Type: ResourceID{Name: "CUSTOM"}, Name: ResourceID{ID: 7}, Lang: 0x409, Data: []byte("seven")},
+	{Type: ResourceID{ID: ResourceVersion}, Name: ResourceID{ID: 1}, Lang: 0x407, Data: []byte("version de")},
+	{Type: ResourceID{ID: ResourceVersion}, Name: ResourceID{ID: 1}, Lang: 0x409, Data: []byte("version en")},
+	{Type: ResourceID{ID: ResourceManifest}, Name: ResourceID{ID: 2}, Lang: 0, Data: []byte("<assembly/>")},
+}
+
+func TestReadResources(t *testing.T) {
+	section, _ := resourceSection(testResources)
+	got, err := ReadResources(bytes.NewReader(resourceImage(t, section)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, testResources) {
+		t.Errorf("ReadResources = %+v\nwant %+v", got, testResources)
+	}
+}
+
+func TestReadResourcesNoDirectory(t *testing.T) {
+	img := buildImage(t, false, []testSection{{".rsrc", rsrcRVA, make([]byte, 16), pe.IMAGE_SCN_MEM_READ}}, pe.IMAGE_DIRECTORY_ENTRY_RESOURCE, pe.DataDirectory{})
+	got, err := ReadResources(bytes.NewReader(img))
+	if err != nil || got != nil {
+		t.Errorf("ReadResources = %+v, %v; want none", got, err)
+	}
+}
+
+func TestReadResourcesCorrupt(t *testing.T) {
+	le := binary.LittleEndian
+	tests := []struct {
+		name    string
+		corrupt func(section []byte, offs resourceOffsets)
+		wantErr string
+	}{
+		{
+			name: "nested too deeply",
+			corrupt: func(section []byte, offs resourceOffsets) {
+				le.PutUint32(section[offs.firstLang:], le.Uint32(section[offs.firstLang:])|resourceSubdirectory)
+			},
+			wantErr: "nested too deeply",
+		},
+		{
+			name: "oversized",
+			corrupt: func(section []byte, offs resourceOffsets) {
+				le.PutUint32(section[offs.firstData+4:], maxResourceSize+1)
+			},
+			wantErr: "exceeds",
+		},
+		{
+			name: "truncated name",
+			corrupt: func(section []byte, offs resourceOffsets) {
+				le.PutUint16(section[offs.firstName:], 0xFFFF)
+			},
+			wantErr: "failed to read resource name",
+		},
+		{
+			name: "entries past section",
+			corrupt: func(section []byte, offs resourceOffsets) {
+				le.PutUint16(section[14:], 0xFFFF)
+			},
+			wantErr: "failed to read resource directory",
+		},
+		{
+			name: "data outside image",
+			corrupt: func(section []byte, offs resourceOffsets) {
+				le.PutUint32(section[offs.firstData:], 0x100000)
+			},
+			wantErr: "not inside any section",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			section, offs := resourceSection(testResources)
+			tt.corrupt(section, offs)
+			_, err := ReadResources(bytes.NewReader(resourceImage(t, section)))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ReadResources error = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
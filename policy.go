@@ -0,0 +1,48 @@
+//go:build windows
+
+package proxdll
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/nilssoncreative/proxdll/pefile"
+)
+
+// MissingExportPolicy decides what happens when the original DLL lacks a function the
+// proxy forwards to.
+type MissingExportPolicy int
+
+const (
+	// MissingExportPanic makes CallOriginal panic on a missing function. It is the default.
+	MissingExportPanic MissingExportPolicy = iota
+	// MissingExportFailLoad fails loading the original, and so New unless WithLazyLoad is
+	// given, unless every export of the proxy DLL resolves in the original.
+	// Aliases in the Config are followed, so renamed exports still count.
+	MissingExportFailLoad
+	// MissingExportError makes CallOriginal return the lookup failure as its lastErr,
+	// as TryCallOriginal does, so only the calls to missing functions fail.
+	MissingExportError
+)
+
+// proxyExportNames returns the names of the functions the running proxy DLL exports,
+// as the set of exports the original must provide under MissingExportFailLoad.
+func proxyExportNames() ([]string, error) {
+	self, err := SelfPath()
+	if err != nil {
+		return nil, err
+	}
+	table, err := pefile.OpenExports(self)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read export table of %s: %w", self, err)
+	}
+	names := make([]string, 0, len(table.Exports))
+	for _, exp := range table.Exports {
+		// cgo adds exports of its own to every DLL it builds.
+		if strings.HasPrefix(exp.Name, "_cgo_") {
+			continue
+		}
+		names = append(names, exportName(exp))
+	}
+	return names, nil
+}
@@ -0,0 +1,44 @@
+package proxdll
+
+// Action is the outcome of a PreHook: Continue forwards the call to the original,
+// while Return skips the original and hands fixed results back to the host.
+type Action struct {
+	skip    bool
+	r1, r2  uintptr
+	lastErr error
+}
+
+// Continue is the Action that forwards the call to the original function.
+var Continue = Action{}
+
+// Return is the Action that skips the original function and returns r1, r2 and lastErr
+// to the host, as when stubbing out a broken export or replacing it in Go.
+func Return(r1, r2 uintptr, lastErr error) Action {
+	return Action{skip: true, r1: r1, r2: r2, lastErr: lastErr}
+}
+
+// Skipped reports whether the action skips the original function.
+func (a Action) Skipped() bool {
+	return a.skip
+}
+
+// PreHook runs before the original function. It may rewrite call.Args, and decides with
+// its Action whether the original runs at all.
+type PreHook func(call *Call) Action
+
+// Pre adapts a PreHook to a Hook.
+func Pre(pre PreHook) Hook {
+	return func(call *Call, next Next) (uintptr, uintptr, error) {
+		if a := pre(call); a.skip {
+			return a.r1, a.r2, a.lastErr
+		}
+		return next()
+	}
+}
+
+// Stub returns a Hook that always returns r1, r2 and lastErr without calling the original.
+func Stub(r1, r2 uintptr, lastErr error) Hook {
+	return Pre(func(*Call) Action {
+		return Return(r1, r2, lastErr)
+	})
+}
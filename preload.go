@@ -0,0 +1,38 @@
+//go:build windows
+
+package proxdll
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Preload resolves and caches the named functions eagerly, so later calls never hit the DLL.
+// Every name is attempted; the returned error lists all functions that could not be resolved.
+func (m *Manager) Preload(funcNames ...string) error {
+	var errs []error
+	for _, name := range funcNames {
+		if _, err := m.GetOriginalFunc(name); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to preload %d of %d functions: %w", len(errs), len(funcNames), errors.Join(errs...))
+	}
+	return nil
+}
+
+// PreloadAll preloads every export of the original DLL, using "#N" names for exports
+// that only have an ordinal.
+func (m *Manager) PreloadAll() error {
+	exports, err := m.ListExports()
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(exports))
+	for _, exp := range exports {
+		names = append(names, exportName(exp))
+	}
+	return m.Preload(names...)
+}
@@ -1,72 +1,506 @@
-// Package proxdll provides a generic framework for creating proxy DLLs.
+//go:build windows
+
 package proxdll
 
 import (
 	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"golang.org/x/sys/windows"
+
+	"github.com/nilssoncreative/proxdll/pefile"
 )
 
 // Manager handles the loading of the original DLL and manages function pointers.
 type Manager struct {
-	originalDLL *windows.DLL
-	procs       map[string]*windows.Proc
-	mu          sync.RWMutex
+	path            string
+	lazy            bool
+	resolver        Resolver
+	loader          Loader
+	loadFlags       uint32
+	attach          bool
+	image           []byte
+	pin             bool
+	required        []string
+	missingPolicy   MissingExportPolicy
+	verifiers       []verifier
+	verifyWarnOnly  bool
+	logger          *slog.Logger
+	logLevel        *slog.LevelVar
+	loadOnce        sync.Once
+	loadErr         error
+	loaded          atomic.Bool
+	originalDLL     Module
+	procs           procCache
+	calls           atomic.Pointer[callGate]
+	swapMu          sync.Mutex // serializes Reload and Free
+	freeTimeout     time.Duration
+	exports         *pefile.ExportTable
+	forwards        map[string][]string
+	forwardDLLs     map[string]Module
+	aliases         map[string]string
+	hooks           map[string]resolvedHook
+	hookChains      map[string][]*hookEntry
+	hookPatterns    []*hookPattern
+	hookSeq         uint64
+	disabledHooks   map[string]bool
+	disabledGroups  map[string]bool
+	depth           callDepth
+	callers         bool
+	stackDepth      int
+	stackFuncs      []*hookPattern
+	symbolPath      string
+	undecorate      bool
+	replayPath      string
+	replay          *replayer
+	validate        bool
+	validateReport  func(*ExportReport)
+	tracers         atomic.Pointer[multiTracer]
+	tracerEntries   []*tracerEntry
+	threadCallbacks []*threadCallback
+	flushInterval   time.Duration
+	flushStop       chan struct{}
+	shutdown        atomic.Bool
+	crashReport     func(*CrashReport)
+	crashCapture    bool
+	crashDumpDir    string
+	crash           *crashHandler
+	recorder        *flightRecorder
+	signatures      map[string]*Signature
+	fallbacks       map[string]Fallback
+	stats           *statsTable
+	configSource    func() (*Config, error)
+	configFiles     func() ([]string, error) // the files configSource reads, for WithConfigReload
+	configReload    bool
+	configDefault   func() (*Config, error)
+	configWatcher   *ConfigWatcher
+	envOverrides    bool
+	env             *envSettings
+	config          atomic.Pointer[configState]
+	configMu        sync.Mutex
+	fuzzyConfig     bool
+	mu              sync.RWMutex
+}
+
+// New creates a new proxy Manager for a given DLL, configured by opts.
+// It loads the original DLL into memory, unless WithLazyLoad or WithReplay is given.
+// Use Attach instead to proxy a module that is already loaded.
+// With WithResolver, originalDllPath is the name handed to the Resolver instead.
+func New(originalDllPath string, opts ...Option) (*Manager, error) {
+	m := &Manager{
+		path:           originalDllPath,
+		forwards:       make(map[string][]string),
+		forwardDLLs:    make(map[string]Module),
+		aliases:        make(map[string]string),
+		hooks:          make(map[string]resolvedHook),
+		hookChains:     make(map[string][]*hookEntry),
+		disabledHooks:  make(map[string]bool),
+		disabledGroups: make(map[string]bool),
+		signatures:     make(map[string]*Signature),
+		fallbacks:      make(map[string]Fallback),
+		logger:         slog.New(slog.DiscardHandler),
+		loader:         SystemLoader,
+	}
+	m.calls.Store(&callGate{})
+	for _, opt := range opts {
+		opt(m)
+	}
+	if m.envOverrides {
+		m.readEnv()
+	}
+	if m.image != nil {
+		if err := m.checkImageVerifiers(); err != nil {
+			return nil, err
+		}
+	}
+	if m.symbolPath != "" {
+		if err := symbols.setPath(m.symbolPath); err != nil {
+			return nil, err
+		}
+	}
+
+	if m.replayPath != "" {
+		r, err := loadRecording(m.replayPath)
+		if err != nil {
+			return nil, err
+		}
+		m.replay = r
+	}
+	if err := m.initConfig(); err != nil {
+		return nil, err
+	}
+	if m.crashCapture && m.recorder == nil {
+		m.recorder = newFlightRecorder(defaultRecorderSize)
+	}
+	if m.crashCapture {
+		m.installCrashHandler(m.crashReport, m.crashDumpDir)
+	}
+	if m.flushInterval > 0 {
+		m.flushStop = make(chan struct{})
+		go m.flushLoop(m.flushInterval, m.flushStop)
+	}
+
+	if !m.lazy && m.replay == nil {
+		if _, err := m.dll(); err != nil {
+			return nil, err
+		}
+		if m.validate {
+			m.validateExports(m.validateReport)
+		}
+	}
+	return m, nil
+}
+
+// initConfig applies the config given by options and the environment, and starts watching it.
+func (m *Manager) initConfig() error {
+	if m.configSource == nil && m.configDefault == nil && m.env == nil {
+		return nil
+	}
+
+	var cfg *Config
+	if m.configSource != nil {
+		var err error
+		if cfg, err = m.configSource(); err != nil {
+			return err
+		}
+	}
+	if cfg == nil && m.configDefault != nil {
+		var err error
+		if cfg, err = m.configDefault(); err != nil {
+			return err
+		}
+	}
+	if err := m.ApplyConfig(m.overrideConfig(cfg)); err != nil {
+		return err
+	}
+
+	if !m.configReload {
+		return nil
+	}
+	// The files are watched even if none exists yet, so one created later is applied.
+	var files []string
+	if m.configFiles != nil {
+		var err error
+		if files, err = m.configFiles(); err != nil {
+			return err
+		}
+	} else if cfg != nil && cfg.path != "" {
+		files = []string{cfg.path}
+	}
+	if len(files) > 0 {
+		w, err := m.watchConfig(files)
+		if err != nil {
+			return err
+		}
+		m.configWatcher = w
+	}
+	return nil
+}
+
+// dll returns the original DLL, loading it on first use.
+func (m *Manager) dll() (Module, error) {
+	m.loadOnce.Do(func() {
+		m.loadErr = m.load()
+		if m.loadErr != nil {
+			m.logger.Error("failed to load original DLL", "path", m.path, "error", m.loadErr)
+		}
+	})
+	if m.loadErr != nil {
+		return nil, m.loadErr
+	}
+
+	// Reload replaces the module under the lock, and Free clears it.
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.originalDLL == nil {
+		return nil, ErrFreed
+	}
+	return m.originalDLL, nil
+}
+
+// load loads the original DLL and resolves any exports required by WithStrictExports.
+func (m *Manager) load() error {
+	path := m.path
+	if m.resolver != nil {
+		var err error
+		path, err = m.resolver(m.path)
+		if err != nil {
+			return fmt.Errorf("failed to resolve original DLL for %s: %w", m.path, err)
+		}
+		m.logger.Debug("resolved original DLL", "proxy", m.path, "path", path)
+	}
+	if mapped := wow64Path(path); mapped != path {
+		m.logger.Debug("mapped original DLL to SysWOW64", "path", path, "mapped", mapped)
+		path = mapped
+	}
+
+	dll, resolved, err := m.open(path)
+	if err != nil {
+		return err
+	}
+	m.procs.store(resolved)
+	m.originalDLL = dll
+	m.loaded.Store(true)
+	return nil
+}
+
+// open verifies and loads the original DLL at path, and resolves the exports it is required to have.
+func (m *Manager) open(path string) (Module, map[string]*Proc, error) {
+	// An embedded image is verified itself, never a file that happens to be at path.
+	early := len(m.verifiers) > 0 && m.image == nil && verifiesBeforeLoad(path)
+	if early {
+		if err := m.verify(path); err != nil {
+			return nil, nil, err
+		}
+	}
+	if len(m.verifiers) > 0 && m.image != nil {
+		if err := m.verifyImage(path, m.image); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var dll Module
+	var err error
+	switch {
+	case m.attach:
+		dll, err = attachModule(path)
+	case m.image != nil:
+		dll, err = LoadMemoryModule(path, m.image)
+	default:
+		if err := checkImageBits(path); err != nil {
+			return nil, nil, err
+		}
+		dll, err = m.loader.Load(path, m.loadFlags)
+	}
+	if err != nil {
+		if isNotFound(err) {
+			err = withCategory(err, ErrOriginalNotFound)
+		}
+		if m.attach {
+			return nil, nil, fmt.Errorf("failed to attach to original DLL %s: %w", path, err)
+		}
+		if m.loadFlags != 0 {
+			return nil, nil, fmt.Errorf("failed to load original DLL at %s with flags %#x: %w", path, m.loadFlags, err)
+		}
+		return nil, nil, fmt.Errorf("failed to load original DLL at %s: %w", path, err)
+	}
+	m.logger.Debug("loaded original DLL", "path", path)
+
+	if len(m.verifiers) > 0 && !early && m.image == nil {
+		file, err := moduleFile(dll)
+		if err == nil {
+			err = m.verify(file)
+		}
+		if err != nil {
+			dll.Release()
+			return nil, nil, err
+		}
+	}
+
+	required := m.required
+	if m.missingPolicy == MissingExportFailLoad {
+		names, err := proxyExportNames()
+		if err != nil {
+			dll.Release()
+			return nil, nil, fmt.Errorf("failed to list exports required by proxy: %w", err)
+		}
+		required = append(slices.Clone(required), names...)
+	}
+
+	var missing []string
+	resolved := make(map[string]*Proc, len(required))
+	for _, name := range required {
+		proc, err := findProc(dll, m.originalName(name))
+		if err != nil {
+			missing = append(missing, name)
+			continue
+		}
+		resolved[name] = proc
+	}
+	if len(missing) > 0 {
+		dll.Release()
+		return nil, nil, withCategory(fmt.Errorf("original DLL at %s is missing required exports: %s", path, strings.Join(missing, ", ")), ErrProcNotFound)
+	}
+
+	if m.pin {
+		if err := pinModule(dll); err != nil {
+			dll.Release()
+			return nil, nil, err
+		}
+	}
+
+	return dll, resolved, nil
 }
 
-// New creates a new proxy Manager for a given DLL.
-// It loads the original DLL into memory.
-func New(originalDllPath string) (*Manager, error) {
-	dll, err := windows.LoadDLL(originalDllPath)
+// Handle returns the module handle of the original DLL, loading it if needed, to pass to
+// Windows APIs such as GetModuleFileName. It is zero for originals the system loader does
+// not know, such as those loaded with WithEmbeddedImage or as data files.
+func (m *Manager) Handle() (windows.Handle, error) {
+	dll, err := m.dll()
 	if err != nil {
-		return nil, fmt.Errorf("failed to load original DLL at %s: %w", originalDllPath, err)
+		return 0, err
 	}
+	return dll.Handle(), nil
+}
 
-	return &Manager{
-		originalDLL: dll,
-		procs:       make(map[string]*windows.Proc),
-	}, nil
+// BaseAddress returns the address the original DLL is mapped at, loading it if needed,
+// for inspecting its image in memory or turning RVAs into addresses. It is zero for
+// custom Modules that do not have a system loader handle.
+func (m *Manager) BaseAddress() (uintptr, error) {
+	dll, err := m.dll()
+	if err != nil {
+		return 0, err
+	}
+	return moduleBase(dll), nil
 }
 
-// GetOriginalFunc retrieves and caches a function from the original DLL.
-func (m *Manager) GetOriginalFunc(funcName string) (*windows.Proc, error) {
+// loadedDLL returns the original DLL if it has been loaded, without loading it.
+func (m *Manager) loadedDLL() Module {
+	if !m.loaded.Load() {
+		return nil
+	}
 	m.mu.RLock()
-	proc, ok := m.procs[funcName]
-	m.mu.RUnlock()
+	defer m.mu.RUnlock()
+	return m.originalDLL
+}
 
-	if ok {
+// GetOriginalFunc retrieves and caches a function from the original DLL.
+// A name of the form "#N" resolves the export with ordinal N, for exports that have no name.
+// Aliases set with SetAlias or the Config are followed. Exports of variables fail with
+// ErrDataExport, since calling them would jump into data; see DataExport.
+func (m *Manager) GetOriginalFunc(funcName string) (*Proc, error) {
+	if proc, ok := m.procs.load(funcName); ok {
 		return proc, nil
 	}
 
+	gate := m.calls.Load()
+	dll, err := m.dll()
+	if err != nil {
+		return nil, err
+	}
+
 	// If not cached, find it in the DLL
-	foundProc, err := m.originalDLL.FindProc(funcName)
+	target := m.originalName(funcName)
+	foundProc, chain, err := m.resolve(dll, target)
 	if err != nil {
-		return nil, fmt.Errorf("could not find function %s in original DLL: %w", funcName, err)
+		m.logger.Warn("function not found in original DLL", "func", funcName, "target", target)
+		return nil, &ProcNotFoundError{Name: funcName, Target: target, Err: err}
+	}
+	if foundProc.data {
+		return nil, fmt.Errorf("cannot call %s: %w", funcName, ErrDataExport)
 	}
 
-	// Cache the proc
+	// Cache the proc, unless Reload replaced the DLL it was found in
 	m.mu.Lock()
-	m.procs[funcName] = foundProc
+	if m.calls.Load() != gate {
+		m.mu.Unlock()
+		return m.GetOriginalFunc(funcName)
+	}
+	if len(chain) > 0 {
+		m.forwards[funcName] = chain
+	}
+	m.procs.store(map[string]*Proc{funcName: foundProc})
 	m.mu.Unlock()
 
 	return foundProc, nil
 }
 
-// CallOriginal invokes the original function with the given arguments.
-// It uses the modern `proc.Call()` method.
-func (m *Manager) CallOriginal(funcName string, args ...uintptr) (r1, r2 uintptr, lastErr error) {
+// TryCallOriginal invokes the original function with the given arguments.
+// If the function cannot be resolved, r1 and r2 are zero and lastErr describes the lookup failure.
+func (m *Manager) TryCallOriginal(funcName string, args ...uintptr) (r1, r2 uintptr, lastErr error) {
+	gate, ok := m.enterCall()
+	if !ok {
+		return 0, 0, ErrFreed
+	}
+	defer gate.exit()
+
+	proc, err := m.GetOriginalFunc(funcName)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return proc.Call(args...)
+}
+
+// MustCallOriginal invokes the original function with the given arguments.
+// It panics if the function cannot be resolved, as the proxy cannot fulfill its contract.
+func (m *Manager) MustCallOriginal(funcName string, args ...uintptr) (r1, r2 uintptr, lastErr error) {
+	gate, ok := m.enterCall()
+	if !ok {
+		return 0, 0, ErrFreed
+	}
+	defer gate.exit()
+
 	proc, err := m.GetOriginalFunc(funcName)
 	if err != nil {
-		// This is a critical error as the function doesn't exist.
-		// A panic is appropriate here because the proxy cannot fulfill its contract.
 		panic(err)
 	}
 
 	return proc.Call(args...)
 }
 
+// CallOriginal invokes the original function with the given arguments.
+// It panics if the function cannot be resolved, unless WithMissingExportPolicy selects
+// MissingExportError, in which case it behaves as TryCallOriginal.
+//
+// Deprecated: Use TryCallOriginal, or MustCallOriginal where a panic is acceptable.
+func (m *Manager) CallOriginal(funcName string, args ...uintptr) (r1, r2 uintptr, lastErr error) {
+	if m.missingPolicy == MissingExportError {
+		return m.TryCallOriginal(funcName, args...)
+	}
+	return m.MustCallOriginal(funcName, args...)
+}
+
 // Free unloads the original DLL. It should be called during cleanup.
+// A lazily loaded DLL that was never used is not loaded by Free.
+// Free also stops the config watcher started by WithConfigReload.
+//
+// Calls made after Free begins fail with ErrFreed, and Free waits for the calls already
+// running in the original to return before unloading it. With WithFreeTimeout, Free gives
+// up after the timeout and returns an error, leaving the original loaded. Free must not be
+// called from a call to the original, such as a hook, which would wait for itself.
 func (m *Manager) Free() error {
-	return m.originalDLL.Release()
+	return m.free(true)
+}
+
+// free implements Free. Without release it forgets the original and forwarded DLLs
+// without calling FreeLibrary, for callers under the loader lock.
+func (m *Manager) free(release bool) error {
+	m.loadOnce.Do(func() {
+		m.loadErr = withCategory(fmt.Errorf("original DLL at %s was freed before it was loaded", m.path), ErrManagerClosed)
+	})
+	if m.configWatcher != nil {
+		m.configWatcher.Close()
+	}
+	m.swapMu.Lock()
+	defer m.swapMu.Unlock()
+	if err := m.calls.Load().close(m.freeTimeout); err != nil {
+		return fmt.Errorf("failed to free original DLL at %s: %w", m.path, err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for key, dll := range m.forwardDLLs {
+		if release {
+			dll.Release()
+		}
+		delete(m.forwardDLLs, key)
+	}
+
+	if m.originalDLL == nil {
+		return nil
+	}
+	// Clearing the module makes a second Free a no-op, which matters for attached
+	// modules, whose reference belongs to the host.
+	var err error
+	if release {
+		err = m.originalDLL.Release()
+	}
+	m.originalDLL = nil
+	return err
 }
@@ -0,0 +1,124 @@
+// Package proxytest provides an in-memory stand-in for proxdll.Manager, so hook logic
+// can be unit-tested on any platform, without Windows or the original DLL.
+//
+// A test scripts the original functions, installs the hooks under test and makes calls
+// as the host would:
+//
+//	m := proxytest.New()
+//	m.Return("GetTickCount", 1000, 0, nil)
+//	installHooks(m) // takes a proxdll.HookRegistry
+//	r1, _, _ := m.Call("GetTickCount")
+package proxytest
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/nilssoncreative/proxdll"
+)
+
+// ErrNotScripted is returned by calls to original functions that were not scripted
+// with Return or Handle.
+var ErrNotScripted = errors.New("proxytest: original function not scripted")
+
+// Original is a scripted original function.
+type Original func(args []uintptr) (r1, r2 uintptr, lastErr error)
+
+// Invocation records a call that reached an original function.
+type Invocation struct {
+	Func string
+	// Args holds the arguments as the original received them, after any hook changed them.
+	Args []uintptr
+}
+
+// Manager is a fake proxdll.Manager whose original functions are scripted in memory.
+// It is safe for concurrent use.
+type Manager struct {
+	mu          sync.Mutex
+	hooks       map[string]proxdll.Hook
+	originals   map[string]Original
+	invocations []Invocation
+}
+
+var _ proxdll.HookRegistry = (*Manager)(nil)
+
+// New returns a Manager with no hooks and no scripted functions.
+func New() *Manager {
+	return &Manager{
+		hooks:     make(map[string]proxdll.Hook),
+		originals: make(map[string]Original),
+	}
+}
+
+// Return scripts the original funcName to always return r1, r2 and lastErr.
+func (m *Manager) Return(funcName string, r1, r2 uintptr, lastErr error) {
+	m.Handle(funcName, func([]uintptr) (uintptr, uintptr, error) {
+		return r1, r2, lastErr
+	})
+}
+
+// Handle scripts the original funcName to run fn.
+func (m *Manager) Handle(funcName string, fn Original) {
+	m.mu.Lock()
+	m.originals[funcName] = fn
+	m.mu.Unlock()
+}
+
+// RegisterHook installs hook for funcName, replacing any hook already registered for it.
+func (m *Manager) RegisterHook(funcName string, hook proxdll.Hook) {
+	m.mu.Lock()
+	m.hooks[funcName] = hook
+	m.mu.Unlock()
+}
+
+// UnregisterHook removes the hook for funcName, if any.
+func (m *Manager) UnregisterHook(funcName string) {
+	m.mu.Lock()
+	delete(m.hooks, funcName)
+	m.mu.Unlock()
+}
+
+// Call invokes funcName as the host would, running its hook if one is registered.
+func (m *Manager) Call(funcName string, args ...uintptr) (r1, r2 uintptr, lastErr error) {
+	m.mu.Lock()
+	hook := m.hooks[funcName]
+	m.mu.Unlock()
+
+	if hook == nil {
+		return m.TryCallOriginal(funcName, args...)
+	}
+	call := &proxdll.Call{Name: funcName, Args: slices.Clone(args), Depth: 1}
+	return proxdll.RunHook(hook, call, func() (uintptr, uintptr, error) {
+		return m.TryCallOriginal(call.Name, call.Args...)
+	})
+}
+
+// TryCallOriginal invokes the scripted original funcName, bypassing hooks, and records
+// the invocation. Unscripted functions fail with an error wrapping ErrNotScripted.
+func (m *Manager) TryCallOriginal(funcName string, args ...uintptr) (r1, r2 uintptr, lastErr error) {
+	m.mu.Lock()
+	fn := m.originals[funcName]
+	m.invocations = append(m.invocations, Invocation{Func: funcName, Args: slices.Clone(args)})
+	m.mu.Unlock()
+
+	if fn == nil {
+		return 0, 0, fmt.Errorf("%w: %s", ErrNotScripted, funcName)
+	}
+	return fn(args)
+}
+
+// Invocations returns the calls that reached original functions so far, in order.
+func (m *Manager) Invocations() []Invocation {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return slices.Clone(m.invocations)
+}
+
+// Reset forgets the recorded invocations.
+func (m *Manager) Reset() {
+	m.mu.Lock()
+	m.invocations = nil
+	m.mu.Unlock()
+}
@@ -0,0 +1,165 @@
+package proxytest_test
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/nilssoncreative/proxdll"
+	"github.com/nilssoncreative/proxdll/proxytest"
+)
+
+var errDenied = errors.New("denied")
+
+func TestCall(t *testing.T) {
+	tests := []struct {
+		name    string
+		hook    proxdll.Hook
+		args    []uintptr
+		wantR1  uintptr
+		wantErr error
+		// wantArgs is what the original receives, or nil if it must not run.
+		wantArgs []uintptr
+	}{
+		{
+			name:     "no hook",
+			args:     []uintptr{1, 2},
+			wantR1:   3,
+			wantArgs: []uintptr{1, 2},
+		},
+		{
+			name: "rewrites arguments",
+			hook: proxdll.Pre(func(call *proxdll.Call) proxdll.Action {
+				call.SetArg(1, 40)
+				return proxdll.Continue
+			}),
+			args:     []uintptr{2, 0},
+			wantR1:   42,
+			wantArgs: []uintptr{2, 40},
+		},
+		{
+			name:     "grows arguments",
+			hook:     proxdll.Pre(func(call *proxdll.Call) proxdll.Action { call.SetArg(2, 5); return proxdll.Continue }),
+			args:     []uintptr{1},
+			wantR1:   6,
+			wantArgs: []uintptr{1, 0, 5},
+		},
+		{
+			name:    "stub skips original",
+			hook:    proxdll.Stub(0, 0, errDenied),
+			args:    []uintptr{1, 2},
+			wantErr: errDenied,
+		},
+		{
+			name: "replaces result",
+			hook: func(call *proxdll.Call, next proxdll.Next) (uintptr, uintptr, error) {
+				r1, r2, err := next()
+				return r1 * 10, r2, err
+			},
+			args:     []uintptr{1, 2},
+			wantR1:   30,
+			wantArgs: []uintptr{1, 2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := proxytest.New()
+			m.Handle("Add", func(args []uintptr) (uintptr, uintptr, error) {
+				var sum uintptr
+				for _, a := range args {
+					sum += a
+				}
+				return sum, 0, nil
+			})
+			if tt.hook != nil {
+				m.RegisterHook("Add", tt.hook)
+			}
+
+			r1, _, err := m.Call("Add", tt.args...)
+			if r1 != tt.wantR1 || !errors.Is(err, tt.wantErr) {
+				t.Errorf("Call = %d, %v; want %d, %v", r1, err, tt.wantR1, tt.wantErr)
+			}
+			inv := m.Invocations()
+			switch {
+			case tt.wantArgs == nil && len(inv) != 0:
+				t.Errorf("original ran with %v, want no call", inv[0].Args)
+			case tt.wantArgs != nil && (len(inv) != 1 || !slices.Equal(inv[0].Args, tt.wantArgs)):
+				t.Errorf("invocations = %v, want one with %v", inv, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestCallDoesNotChangeHostArgs(t *testing.T) {
+	m := proxytest.New()
+	m.Return("F", 0, 0, nil)
+	m.RegisterHook("F", proxdll.Pre(func(call *proxdll.Call) proxdll.Action {
+		call.Args[0] = 99
+		return proxdll.Continue
+	}))
+
+	args := []uintptr{1}
+	m.Call("F", args...)
+	if args[0] != 1 {
+		t.Errorf("host argument changed to %d", args[0])
+	}
+}
+
+func TestUnregisterHook(t *testing.T) {
+	m := proxytest.New()
+	m.Return("F", 1, 0, nil)
+	m.RegisterHook("F", proxdll.Stub(2, 0, nil))
+	if r1, _, _ := m.Call("F"); r1 != 2 {
+		t.Fatalf("hooked Call = %d, want 2", r1)
+	}
+	m.UnregisterHook("F")
+	if r1, _, _ := m.Call("F"); r1 != 1 {
+		t.Errorf("unhooked Call = %d, want 1", r1)
+	}
+}
+
+func TestNotScripted(t *testing.T) {
+	m := proxytest.New()
+	if _, _, err := m.Call("Missing"); !errors.Is(err, proxytest.ErrNotScripted) {
+		t.Errorf("err = %v, want ErrNotScripted", err)
+	}
+	if inv := m.Invocations(); len(inv) != 1 || inv[0].Func != "Missing" {
+		t.Errorf("invocations = %v, want the failed call", inv)
+	}
+	m.Reset()
+	if inv := m.Invocations(); len(inv) != 0 {
+		t.Errorf("invocations after Reset = %v", inv)
+	}
+}
+
+func TestInjectFault(t *testing.T) {
+	m := proxytest.New()
+	m.Return("F", 1, 0, nil)
+	m.RegisterHook("F", proxdll.InjectFault(proxdll.Fault{Every: 2, R1: 0, LastErr: errDenied}))
+
+	var got []error
+	for range 4 {
+		_, _, err := m.Call("F")
+		got = append(got, err)
+	}
+	want := []error{nil, errDenied, nil, errDenied}
+	if !slices.Equal(got, want) {
+		t.Errorf("errors = %v, want %v", got, want)
+	}
+	if n := len(m.Invocations()); n != 2 {
+		t.Errorf("original ran %d times, want 2", n)
+	}
+}
+
+func TestHookPanicPropagates(t *testing.T) {
+	m := proxytest.New()
+	m.RegisterHook("F", func(*proxdll.Call, proxdll.Next) (uintptr, uintptr, error) {
+		panic("boom")
+	})
+	defer func() {
+		if r := recover(); r != "boom" {
+			t.Errorf("recovered %v, want the hook's panic", r)
+		}
+	}()
+	m.Call("F")
+}
@@ -0,0 +1,148 @@
+//go:build windows
+
+package proxdll
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/sys/windows"
+)
+
+// defaultRecorderSize is the flight recorder size used for crash capture.
+const defaultRecorderSize = 64
+
+// recorderArgs is the number of arguments kept per call by the flight recorder.
+const recorderArgs = 16
+
+// RecentCall is one call remembered by the flight recorder.
+type RecentCall struct {
+	// Func is the name of the called export.
+	Func string
+	// Args holds the first arguments of the call, up to 16.
+	Args []uintptr
+	// ThreadID identifies the calling thread.
+	ThreadID uint32
+	// Start is when the call entered the proxy.
+	Start time.Time
+}
+
+// recorderSlot holds one call. Every field is atomic so readers never see a torn value;
+// seq tells them whether the slot changed while they copied it.
+type recorderSlot struct {
+	seq   atomic.Uint64
+	name  atomic.Pointer[string]
+	tid   atomic.Uint32
+	start atomic.Int64
+	nargs atomic.Uint32
+	args  [recorderArgs]atomic.Uintptr
+}
+
+// flightRecorder is a fixed-size ring of the most recent calls. Recording takes no lock:
+// writers claim slots with an atomic counter, and readers skip slots that were
+// overwritten while being read.
+type flightRecorder struct {
+	pos   atomic.Uint64
+	slots []recorderSlot
+
+	// names interns export names so slots can refer to them without allocating.
+	namesMu sync.Mutex
+	names   atomic.Pointer[map[string]*string]
+}
+
+func newFlightRecorder(size int) *flightRecorder {
+	r := &flightRecorder{slots: make([]recorderSlot, size)}
+	r.names.Store(&map[string]*string{})
+	return r
+}
+
+// intern returns a stable pointer to funcName.
+func (r *flightRecorder) intern(funcName string) *string {
+	if p, ok := (*r.names.Load())[funcName]; ok {
+		return p
+	}
+	r.namesMu.Lock()
+	defer r.namesMu.Unlock()
+	names := *r.names.Load()
+	if p, ok := names[funcName]; ok {
+		return p
+	}
+	next := maps.Clone(names)
+	p := &funcName
+	next[funcName] = p
+	r.names.Store(&next)
+	return p
+}
+
+// record stores a call in the next slot.
+func (r *flightRecorder) record(funcName string, args []uintptr) {
+	n := r.pos.Add(1)
+	slot := &r.slots[(n-1)%uint64(len(r.slots))]
+
+	slot.seq.Store(0)
+	slot.name.Store(r.intern(funcName))
+	slot.tid.Store(windows.GetCurrentThreadId())
+	slot.start.Store(time.Now().UnixNano())
+	count := min(len(args), recorderArgs)
+	slot.nargs.Store(uint32(count))
+	for i := range count {
+		slot.args[i].Store(args[i])
+	}
+	slot.seq.Store(n)
+}
+
+// snapshot returns the recorded calls, oldest first.
+func (r *flightRecorder) snapshot() []RecentCall {
+	end := r.pos.Load()
+	size := uint64(len(r.slots))
+	begin := uint64(0)
+	if end > size {
+		begin = end - size
+	}
+
+	calls := make([]RecentCall, 0, end-begin)
+	for n := begin + 1; n <= end; n++ {
+		slot := &r.slots[(n-1)%size]
+		if slot.seq.Load() != n {
+			continue
+		}
+		call := RecentCall{
+			Func:     *slot.name.Load(),
+			ThreadID: slot.tid.Load(),
+			Start:    time.Unix(0, slot.start.Load()),
+			Args:     make([]uintptr, min(slot.nargs.Load(), recorderArgs)),
+		}
+		for i := range call.Args {
+			call.Args[i] = slot.args[i].Load()
+		}
+		// Drop the entry if a writer reused the slot while it was being copied.
+		if slot.seq.Load() != n {
+			continue
+		}
+		calls = append(calls, call)
+	}
+	return calls
+}
+
+// RecentCalls returns the calls kept by the flight recorder, oldest first.
+// It returns nil unless the Manager was created with WithFlightRecorder or crash capture.
+func (m *Manager) RecentCalls() []RecentCall {
+	if m.recorder == nil {
+		return nil
+	}
+	return m.recorder.snapshot()
+}
+
+// DumpRecentCalls writes the calls kept by the flight recorder to w, one per line, oldest first.
+func (m *Manager) DumpRecentCalls(w io.Writer) error {
+	for _, call := range m.RecentCalls() {
+		if _, err := fmt.Fprintf(w, "%s tid=%d %s(%s)\n", call.Start.Format("15:04:05.000000"), call.ThreadID, call.Func, formatArgs(call.Args)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
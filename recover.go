@@ -0,0 +1,71 @@
+//go:build windows
+
+package proxdll
+
+import (
+	"fmt"
+	"runtime/debug"
+)
+
+// Failure values commonly returned by Recover for exports whose result reports success.
+const (
+	// FailZero is FALSE, NULL or zero, the failure value of most Win32 functions.
+	FailZero uintptr = 0
+	// FailHRESULT is E_FAIL, for functions returning an HRESULT.
+	FailHRESULT uintptr = 0x80004005
+	// FailInvalidHandle is INVALID_HANDLE_VALUE, for functions returning a HANDLE.
+	FailInvalidHandle = ^uintptr(0)
+)
+
+// Recover stops a panic in an exported stub from tearing down the host. It must be
+// deferred directly by the stub, which needs a named result:
+//
+//	func GetFileVersionInfoW(a0, a1, a2, a3 uintptr) (r1 uintptr) {
+//		defer m.Recover("GetFileVersionInfoW", &r1, proxdll.FailZero)
+//		r1, _, _ = m.Call("GetFileVersionInfoW", a0, a1, a2, a3)
+//		return r1
+//	}
+//
+// On a panic it logs the value and stack and sets *r1 to failure, or to the R1 of the
+// fallback registered for funcName with SetFallback. The panic is also
+// counted as an error in the export's statistics. Recover may be called on a nil Manager.
+func (m *Manager) Recover(funcName string, r1 *uintptr, failure uintptr) {
+	if v := recover(); v != nil {
+		m.recovered(funcName, v)
+		*r1 = m.failure(funcName, failure)
+	}
+}
+
+// recovered reports a panic caught by Recover.
+func (m *Manager) recovered(funcName string, v any) {
+	if m == nil {
+		return
+	}
+	m.logger.Error("recovered panic in export", "func", funcName, "panic", fmt.Sprint(v), "stack", string(debug.Stack()))
+	if m.stats != nil {
+		m.stats.record(funcName, 0, fmt.Errorf("panic: %v", v))
+	}
+}
+
+// failure returns the R1 of funcName's fallback, if one is registered, or else failure.
+func (m *Manager) failure(funcName string, failure uintptr) uintptr {
+	if m == nil {
+		return failure
+	}
+	if fb, ok := m.Fallback(funcName); ok {
+		return fb.R1
+	}
+	return failure
+}
+
+// Recover is like Manager.Recover, for stubs that obtain their Manager from o.
+// It also catches the panic raised by MustManager when initialization failed.
+func (o *InitOnce) Recover(funcName string, r1 *uintptr, failure uintptr) {
+	if v := recover(); v != nil {
+		if o.done.Load() {
+			o.m.recovered(funcName, v)
+			failure = o.m.failure(funcName, failure)
+		}
+		*r1 = failure
+	}
+}
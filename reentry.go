@@ -0,0 +1,74 @@
+//go:build windows
+
+package proxdll
+
+import "sync"
+
+// tlsOutOfIndexes is returned by TlsAlloc when no TLS slot is available.
+const tlsOutOfIndexes = 0xFFFFFFFF
+
+// HookSkipReentrant makes a hook step aside on reentrant calls, those made while another
+// call through the Manager is already running on the same thread, such as an original
+// function calling back into an export the proxy also hooks. The call passes on to the
+// rest of the chain, which keeps a hook that calls proxied exports itself from recursing.
+func HookSkipReentrant() HookOption {
+	return func(e *hookEntry) {
+		e.skipReentrant = true
+	}
+}
+
+// CallDepth returns how many calls through the Manager that run hooks or tracers are in
+// progress on the calling thread, including the current one when called from a hook.
+// Calls on the fast path of Call are not counted, since nothing can intercept them.
+func (m *Manager) CallDepth() int {
+	return m.depth.get()
+}
+
+// callDepth counts nested calls per thread in a TLS slot allocated on first use.
+// Calls from the host run on locked OS threads, so the slot follows the host's thread.
+type callDepth struct {
+	once  sync.Once
+	index uint32
+}
+
+// slot returns the TLS index, or false if none could be allocated.
+func (d *callDepth) slot() (uint32, bool) {
+	d.once.Do(func() {
+		r1, _, _ := procTlsAlloc.Call()
+		d.index = uint32(r1)
+	})
+	return d.index, d.index != tlsOutOfIndexes
+}
+
+// get returns the depth on the calling thread.
+func (d *callDepth) get() int {
+	index, ok := d.slot()
+	if !ok {
+		return 0
+	}
+	r1, _, _ := procTlsGetValue.Call(uintptr(index))
+	return int(r1)
+}
+
+// enter increments the depth on the calling thread and returns it; exit undoes enter.
+// Without a TLS slot, every call appears to be the outermost one.
+func (d *callDepth) enter() int {
+	index, ok := d.slot()
+	if !ok {
+		return 1
+	}
+	r1, _, _ := procTlsGetValue.Call(uintptr(index))
+	procTlsSetValue.Call(uintptr(index), r1+1)
+	return int(r1 + 1)
+}
+
+func (d *callDepth) exit() {
+	index, ok := d.slot()
+	if !ok {
+		return
+	}
+	r1, _, _ := procTlsGetValue.Call(uintptr(index))
+	if r1 > 0 {
+		procTlsSetValue.Call(uintptr(index), r1-1)
+	}
+}
@@ -0,0 +1,75 @@
+//go:build windows
+
+package proxdll
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Reload replaces the original DLL with the one at newPath while the Manager is in use,
+// for example to pick up a rebuilt plugin during development. The new original is loaded
+// and checked like the first one, and every function resolved so far is resolved again in
+// it; if any is missing, Reload fails and the current original stays in use.
+//
+// Calls made once Reload returns, or already waiting for it, run in the new original.
+// The previous original is released when the calls still running in it have returned,
+// waiting at most as long as WithFreeTimeout allows; after that it is left loaded.
+// Reload must not be called from a call to the original, such as a hook.
+func (m *Manager) Reload(newPath string) error {
+	if _, err := m.dll(); err != nil {
+		return err
+	}
+
+	m.swapMu.Lock()
+	defer m.swapMu.Unlock()
+	old := m.calls.Load()
+	if old.closed() {
+		return ErrFreed
+	}
+
+	dll, resolved, err := m.open(newPath)
+	if err != nil {
+		return err
+	}
+	table, err := readExportTable(dll)
+	if err != nil {
+		m.logger.Debug("export table unavailable, resolving directly", "path", newPath, "error", err)
+	}
+
+	var missing []string
+	forwards := make(map[string][]string)
+	for _, name := range m.procs.names() {
+		if _, ok := resolved[name]; ok {
+			continue
+		}
+		proc, chain, err := m.resolveExport(dll, table, m.originalName(name))
+		if err != nil {
+			missing = append(missing, name)
+			continue
+		}
+		resolved[name] = proc
+		if len(chain) > 0 {
+			forwards[name] = chain
+		}
+	}
+	if len(missing) > 0 {
+		dll.Release()
+		return withCategory(fmt.Errorf("original DLL at %s is missing exports in use: %s", newPath, strings.Join(missing, ", ")), ErrProcNotFound)
+	}
+
+	m.mu.Lock()
+	previous := m.originalDLL
+	m.originalDLL = dll
+	m.exports = table
+	m.forwards = forwards
+	m.procs.replace(resolved)
+	m.calls.Store(&callGate{})
+	m.mu.Unlock()
+	m.logger.Info("reloaded original DLL", "path", newPath)
+
+	if err := old.close(m.freeTimeout); err != nil {
+		return fmt.Errorf("failed to release previous original DLL %s: %w", previous.Name(), err)
+	}
+	return previous.Release()
+}
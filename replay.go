@@ -0,0 +1,109 @@
+//go:build windows
+
+package proxdll
+
+import (
+	"bufio"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"golang.org/x/sys/windows"
+)
+
+// ErrNotRecorded is returned in replay mode by calls the recording has no answer for.
+var ErrNotRecorded = errors.New("proxdll: call not in recording")
+
+// Record writes every call made through the Manager to path, in the JSON Lines format
+// of JSONLTracer, until stop is called. The contents of output buffers are recorded for
+// exports whose Signature declares them, so WithReplay can reproduce them later.
+func (m *Manager) Record(path string) (stop func() error, err error) {
+	tracer, err := CreateJSONLTracer(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create recording: %w", err)
+	}
+	remove := m.AddTracer(tracer)
+	return func() error {
+		remove()
+		return tracer.Close()
+	}, nil
+}
+
+// replayer answers calls from a recording, in the order they were recorded for each export.
+type replayer struct {
+	mu    sync.Mutex
+	calls map[string][]*replayedCall
+}
+
+// replayedCall is one recorded call, decoded for replay.
+type replayedCall struct {
+	r1, r2  uintptr
+	lastErr error
+	buffers map[int][]byte
+}
+
+// loadRecording reads a recording written by Record or a JSONLTracer.
+func loadRecording(path string) (*replayer, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open recording: %w", err)
+	}
+	defer f.Close()
+
+	r := &replayer{calls: make(map[string][]*replayedCall)}
+	dec := json.NewDecoder(bufio.NewReader(f))
+	for n := 1; ; n++ {
+		var ev jsonEvent
+		if err := dec.Decode(&ev); errors.Is(err, io.EOF) {
+			return r, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to read call %d of recording %s: %w", n, path, err)
+		}
+
+		call := &replayedCall{r1: ev.R1, r2: ev.R2}
+		if ev.LastErr != nil {
+			call.lastErr = windows.Errno(*ev.LastErr)
+		} else if ev.Error != "" {
+			call.lastErr = errors.New(ev.Error)
+		}
+		for _, buf := range ev.Buffers {
+			if buf.After == "" {
+				continue
+			}
+			data, err := hex.DecodeString(buf.After)
+			if err != nil {
+				return nil, fmt.Errorf("invalid buffer %s in call %d of recording %s: %w", buf.Name, n, path, err)
+			}
+			if call.buffers == nil {
+				call.buffers = make(map[int][]byte)
+			}
+			call.buffers[buf.Arg] = data
+		}
+		r.calls[ev.Func] = append(r.calls[ev.Func], call)
+	}
+}
+
+// call answers the next recorded call of funcName, writing recorded output buffers
+// through the pointers in args.
+func (r *replayer) call(funcName string, args ...uintptr) (uintptr, uintptr, error) {
+	r.mu.Lock()
+	queue := r.calls[funcName]
+	if len(queue) == 0 {
+		r.mu.Unlock()
+		return 0, 0, fmt.Errorf("%w: %s", ErrNotRecorded, funcName)
+	}
+	call := queue[0]
+	r.calls[funcName] = queue[1:]
+	r.mu.Unlock()
+
+	for i, data := range call.buffers {
+		if i < len(args) {
+			WriteMemory(args[i], data)
+		}
+	}
+	return call.r1, call.r2, call.lastErr
+}
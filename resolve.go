@@ -0,0 +1,44 @@
+//go:build windows
+
+package proxdll
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/sys/windows"
+)
+
+// Resolver locates the original DLL for a proxy. It receives the name passed to New
+// and returns the path to load. Resolvers run when the original is loaded, so with
+// WithLazyLoad they run on first use rather than in New.
+type Resolver func(proxyName string) (string, error)
+
+// SystemDLLPath returns the path of the genuine system copy of the named DLL.
+// In a 32-bit process on 64-bit Windows it returns the path in SysWOW64, so the copy
+// matching the process is loaded even if the host has disabled file system redirection.
+// It can be used directly as a Resolver.
+func SystemDLLPath(name string) (string, error) {
+	if IsWow64() {
+		dir, err := SystemWow64Directory()
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(dir, filepath.Base(name)), nil
+	}
+	dir, err := windows.GetSystemDirectory()
+	if err != nil {
+		return "", fmt.Errorf("failed to get system directory: %w", err)
+	}
+	return filepath.Join(dir, filepath.Base(name)), nil
+}
+
+// SuffixResolver returns a Resolver that inserts suffix before the extension of the proxy
+// name, so "dir\version.dll" resolves to "dir\version_orig.dll" for the suffix "_orig".
+func SuffixResolver(suffix string) Resolver {
+	return func(proxyName string) (string, error) {
+		ext := filepath.Ext(proxyName)
+		return strings.TrimSuffix(proxyName, ext) + suffix + ext, nil
+	}
+}
@@ -0,0 +1,78 @@
+//go:build windows
+
+package proxdll
+
+import (
+	"math/rand/v2"
+	"sync"
+
+	"golang.org/x/sys/windows"
+)
+
+// RoutePolicy chooses which original serves a call routed by RouteHook. It returns 0 for
+// the Manager's own original, i for the ith candidate, or a negative number for the
+// Manager's own original too.
+type RoutePolicy func(call *Call) int
+
+// RouteHook returns a Hook that forwards each call to the Manager's own original or to
+// the same export of one of candidates, typically Managers for other versions of the DLL,
+// as chosen by policy. It allows staged migration between library versions in one process.
+func RouteHook(policy RoutePolicy, candidates ...*Manager) Hook {
+	return func(call *Call, next Next) (uintptr, uintptr, error) {
+		i := policy(call)
+		if i <= 0 || i > len(candidates) {
+			return next()
+		}
+		return candidates[i-1].FastCallOriginal(call.Name, call.Args...)
+	}
+}
+
+// SplitPolicy routes calls at random in proportion to weights, where weights[0] is the
+// share of the Manager's own original and weights[i] that of the ith candidate.
+// SplitPolicy(90, 10) sends one call in ten to the first candidate.
+func SplitPolicy(weights ...float64) RoutePolicy {
+	var total float64
+	for _, w := range weights {
+		total += max(w, 0)
+	}
+	return func(*Call) int {
+		if total == 0 {
+			return 0
+		}
+		x := rand.Float64() * total
+		for i, w := range weights {
+			if x -= max(w, 0); x < 0 {
+				return i
+			}
+		}
+		return len(weights) - 1
+	}
+}
+
+// FuncPolicy routes the exports in routes to the given originals, and every other export
+// as fallback does. A nil fallback keeps other exports on the Manager's own original.
+func FuncPolicy(routes map[string]int, fallback RoutePolicy) RoutePolicy {
+	return func(call *Call) int {
+		if i, ok := routes[call.Name]; ok {
+			return i
+		}
+		if fallback == nil {
+			return 0
+		}
+		return fallback(call)
+	}
+}
+
+// StickyPolicy makes the choice of policy for each thread's first call stick for the
+// rest of that thread's calls, so a thread never mixes state between library versions.
+func StickyPolicy(policy RoutePolicy) RoutePolicy {
+	var threads sync.Map // thread ID to route
+	return func(call *Call) int {
+		tid := windows.GetCurrentThreadId()
+		if i, ok := threads.Load(tid); ok {
+			return i.(int)
+		}
+		i, _ := threads.LoadOrStore(tid, policy(call))
+		return i.(int)
+	}
+}
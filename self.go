@@ -0,0 +1,75 @@
+//go:build windows
+
+package proxdll
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// selfMarker is a package variable whose address lies inside the proxy DLL's own image.
+var selfMarker byte
+
+// SelfModule returns the module handle of the DLL this package is linked into,
+// that is, the proxy itself rather than the host executable.
+// The module's reference count is not changed.
+func SelfModule() (windows.Handle, error) {
+	var module windows.Handle
+	flags := uint32(windows.GET_MODULE_HANDLE_EX_FLAG_FROM_ADDRESS | windows.GET_MODULE_HANDLE_EX_FLAG_UNCHANGED_REFCOUNT)
+	if err := windows.GetModuleHandleEx(flags, (*uint16)(unsafe.Pointer(&selfMarker)), &module); err != nil {
+		return 0, fmt.Errorf("failed to get proxy module handle: %w", err)
+	}
+	return module, nil
+}
+
+// selfImage returns the address range [lo, hi) of the proxy DLL's image, which also
+// holds the Go runtime, or an empty range if it cannot be determined.
+var selfImage = sync.OnceValues(func() (lo, hi uintptr) {
+	self, err := SelfModule()
+	if err != nil {
+		return 0, 0
+	}
+	var info windows.ModuleInfo
+	if windows.GetModuleInformation(windows.CurrentProcess(), self, &info, uint32(unsafe.Sizeof(info))) != nil {
+		return 0, 0
+	}
+	return info.BaseOfDll, info.BaseOfDll + uintptr(info.SizeOfImage)
+})
+
+// SelfPath returns the full path of the proxy DLL, independent of the host's working directory.
+func SelfPath() (string, error) {
+	module, err := SelfModule()
+	if err != nil {
+		return "", err
+	}
+	return modulePath(module)
+}
+
+// NextToSelf returns a Resolver that places the result of next in the proxy DLL's directory.
+// With a nil next, the proxy name itself is used, so New("version_orig.dll", WithResolver(NextToSelf(nil)))
+// loads version_orig.dll from wherever the proxy resides. Absolute results are returned unchanged.
+func NextToSelf(next Resolver) Resolver {
+	return func(proxyName string) (string, error) {
+		name := proxyName
+		if next != nil {
+			var err error
+			name, err = next(proxyName)
+			if err != nil {
+				return "", err
+			}
+		}
+		if filepath.IsAbs(name) {
+			return name, nil
+		}
+
+		self, err := SelfPath()
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(filepath.Dir(self), name), nil
+	}
+}
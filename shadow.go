@@ -0,0 +1,126 @@
+//go:build windows
+
+package proxdll
+
+import (
+	"bytes"
+	"errors"
+	"runtime"
+	"syscall"
+	"unsafe"
+)
+
+// maxShadowBuffer bounds the size of an output buffer duplicated for a shadow call.
+// Calls with larger buffers are not shadowed.
+const maxShadowBuffer = 1 << 20
+
+// ShadowResult holds what one version of the original DLL produced for a call.
+type ShadowResult struct {
+	R1, R2  uintptr
+	LastErr error
+	// Buffers holds the captured contents of the call's buffer parameters afterwards,
+	// keyed by parameter name.
+	Buffers map[string][]byte
+}
+
+// Divergence reports a call for which the shadow DLL behaved differently from the original.
+type Divergence struct {
+	Func string
+	Args []uintptr
+	// Primary is the result of the original, which was returned to the host.
+	Primary ShadowResult
+	// Shadow is the result of the shadow DLL, which was discarded.
+	Shadow ShadowResult
+}
+
+// ShadowHook returns a Hook that forwards each call both to the original and to the same
+// export of shadow, typically a Manager for a newer version of the DLL, and passes calls
+// whose results differ to report. The host only ever sees the original's results.
+//
+// Results are compared by R1, the last error and the contents of the buffer parameters
+// declared by the export's Signature, which the shadow receives private copies of.
+// Since every call runs twice, only shadow exports that are free of side effects the
+// host would notice, such as queries and conversions.
+func (m *Manager) ShadowHook(shadow *Manager, report func(*Divergence)) Hook {
+	return func(call *Call, next Next) (uintptr, uintptr, error) {
+		sig := m.Signature(call.Name)
+		shadowArgs, buffers, ok := shadowBuffers(sig, call.Args)
+
+		r1, r2, lastErr := next()
+		if !ok {
+			return r1, r2, lastErr
+		}
+		primary := ShadowResult{R1: r1, R2: r2, LastErr: lastErr, Buffers: make(map[string][]byte, len(buffers))}
+		for name, buf := range buffers {
+			data := make([]byte, len(buf.local))
+			if ReadMemory(call.Args[buf.arg], data) == nil {
+				primary.Buffers[name] = data
+			}
+		}
+
+		sr1, sr2, slastErr := shadow.FastCallOriginal(call.Name, shadowArgs...)
+		runtime.KeepAlive(buffers)
+		secondary := ShadowResult{R1: sr1, R2: sr2, LastErr: slastErr, Buffers: make(map[string][]byte, len(buffers))}
+		for name, buf := range buffers {
+			secondary.Buffers[name] = buf.local
+		}
+
+		if d := diverges(primary, secondary); d != "" {
+			m.logger.Warn("shadow call diverged", "func", call.Name, "field", d)
+			if report != nil {
+				report(&Divergence{Func: call.Name, Args: call.Args, Primary: primary, Shadow: secondary})
+			}
+		}
+		return r1, r2, lastErr
+	}
+}
+
+// shadowBuffer is a private copy of a buffer argument handed to the shadow call.
+type shadowBuffer struct {
+	arg   int
+	local []byte
+}
+
+// shadowBuffers returns the arguments for the shadow call, with each buffer parameter
+// declared by sig replaced by a copy of the host's buffer. It returns false if a buffer
+// cannot be copied, in which case the call is not shadowed.
+func shadowBuffers(sig *Signature, args []uintptr) ([]uintptr, map[string]*shadowBuffer, bool) {
+	shadowArgs := append([]uintptr(nil), args...)
+	if sig == nil {
+		return shadowArgs, nil, true
+	}
+	buffers := make(map[string]*shadowBuffer)
+	for i, p := range sig.Params {
+		if p.Buffer == nil || i >= len(args) || args[i] == 0 {
+			continue
+		}
+		size, n := p.Buffer.bounds(args)
+		if size > maxShadowBuffer {
+			return nil, nil, false
+		}
+		local := make([]byte, max(size, 1))
+		if ReadMemory(args[i], local[:size]) != nil {
+			return nil, nil, false
+		}
+		shadowArgs[i] = uintptr(unsafe.Pointer(&local[0]))
+		buffers[p.Name] = &shadowBuffer{arg: i, local: local[:n]}
+	}
+	return shadowArgs, buffers, true
+}
+
+// diverges returns the first field in which a and b differ, or "" if they agree.
+func diverges(a, b ShadowResult) string {
+	if a.R1 != b.R1 {
+		return "r1"
+	}
+	var ea, eb syscall.Errno
+	if errors.As(a.LastErr, &ea) != errors.As(b.LastErr, &eb) || ea != eb {
+		return "lastErr"
+	}
+	for name, data := range a.Buffers {
+		if !bytes.Equal(data, b.Buffers[name]) {
+			return name
+		}
+	}
+	return ""
+}
@@ -0,0 +1,130 @@
+//go:build windows
+
+package proxdll
+
+import (
+	"errors"
+	"io"
+	"maps"
+	"slices"
+	"time"
+)
+
+// ErrShutdown is returned by Call once Shutdown has begun. It matches ErrManagerClosed.
+var ErrShutdown = withCategory(errors.New("proxdll: manager is shut down"), ErrManagerClosed)
+
+// Flusher is implemented by tracers that buffer events, such as JSONLTracer and ChromeTracer.
+type Flusher interface {
+	Flush() error
+}
+
+// Flush writes out the events buffered by every active tracer that implements Flusher.
+func (m *Manager) Flush() error {
+	t := m.tracers.Load()
+	if t == nil {
+		return nil
+	}
+	return t.Flush()
+}
+
+func (t multiTracer) Flush() error {
+	var errs []error
+	for _, tracer := range t {
+		if f, ok := tracer.(Flusher); ok {
+			errs = append(errs, f.Flush())
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Shutdown tears the Manager down in a fixed order: it stops accepting calls, so Call
+// returns ErrShutdown, stops the config watcher, closes the config sinks and every tracer
+// that implements io.Closer, logs the final statistics, and finally releases the original
+// DLL with Free. Later calls do nothing and return nil.
+//
+// Shutdown must not be used from the lifecycle package's process detach notification,
+// which runs while DllMain holds the loader lock that FreeLibrary needs; use
+// ShutdownAtDetach there. When the host exits rather than unloading the proxy, no Go
+// code can run at detach time, so use WithFlushInterval to bound how many events a
+// crash or exit can lose.
+func (m *Manager) Shutdown() error {
+	return m.shutdownWith(true)
+}
+
+// ShutdownAtDetach is Shutdown for the lifecycle package's process detach notification:
+//
+//	lifecycle.OnProcessDetach(func() { m.ShutdownAtDetach() })
+//
+// It does everything Shutdown does except call FreeLibrary on the original DLL, which
+// would wait for the loader lock DllMain holds. The original stays loaded until the
+// process exits, as its reference is dropped without being released.
+func (m *Manager) ShutdownAtDetach() error {
+	return m.shutdownWith(false)
+}
+
+// shutdownWith implements Shutdown, releasing the original DLL if release is set.
+func (m *Manager) shutdownWith(release bool) error {
+	if !m.shutdown.CompareAndSwap(false, true) {
+		return nil
+	}
+	m.logger.Debug("shutting down")
+
+	if m.flushStop != nil {
+		close(m.flushStop)
+	}
+	if m.configWatcher != nil {
+		m.configWatcher.Close()
+	}
+	if m.crash != nil {
+		m.crash.remove()
+	}
+
+	var errs []error
+	errs = append(errs, m.ApplyConfig(nil))
+
+	m.mu.Lock()
+	entries := m.tracerEntries
+	m.tracerEntries = nil
+	m.publishTracers()
+	m.mu.Unlock()
+	for _, entry := range entries {
+		if c, ok := entry.tracer.(io.Closer); ok {
+			errs = append(errs, c.Close())
+		}
+	}
+
+	m.logFinalStats()
+
+	errs = append(errs, m.free(release))
+	err := errors.Join(errs...)
+	if err != nil {
+		m.logger.Error("shutdown failed", "error", err)
+	}
+	return err
+}
+
+// logFinalStats writes one log entry per export that was called, when WithStats is used.
+func (m *Manager) logFinalStats() {
+	stats := m.Stats()
+	for _, name := range slices.Sorted(maps.Keys(stats)) {
+		st := stats[name]
+		m.logger.Info("final call stats", "func", name, "calls", st.Calls, "errors", st.Errors,
+			"mean", st.Mean, "max", st.Max)
+	}
+}
+
+// flushLoop flushes the tracers every interval until Shutdown.
+func (m *Manager) flushLoop(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if err := m.Flush(); err != nil {
+				m.logger.Warn("failed to flush tracers", "error", err)
+			}
+		}
+	}
+}
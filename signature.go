@@ -0,0 +1,319 @@
+//go:build windows
+
+package proxdll
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/windows"
+)
+
+// maxDecodedString bounds the number of characters decoded from a string argument.
+const maxDecodedString = 256
+
+// Decoder renders one raw argument or result as text for traces.
+type Decoder func(v uintptr) string
+
+// Param describes one parameter of an export.
+type Param struct {
+	// Name is the parameter name shown in traces, such as "lpFileName".
+	Name string
+	// Decode renders the argument; nil renders it as hex.
+	Decode Decoder
+	// Redact hides the argument from every tracer and the flight recorder: decoded traces
+	// show Redacted, raw arguments are zeroed and buffers are not captured.
+	Redact bool
+	// Buffer, if set, marks the argument as a pointer to a buffer whose contents are
+	// captured before and after the call, available from Event.Buffers.
+	Buffer *BufferSpec
+}
+
+// BufferSpec describes how much of a buffer argument to capture.
+type BufferSpec struct {
+	// SizeArg is the index of the parameter holding the buffer size in bytes,
+	// or -1 to always capture Max bytes.
+	SizeArg int
+	// Max bounds the number of bytes captured. Zero means 64.
+	Max int
+}
+
+// defaultBufferCapture is the number of bytes captured when BufferSpec.Max is zero.
+const defaultBufferCapture = 64
+
+// BufferCapture holds the contents of a buffer argument around a call.
+type BufferCapture struct {
+	// Name is the parameter name.
+	Name string
+	// Arg is the index of the parameter among the call's arguments.
+	Arg int
+	// Size is the buffer size declared by the size argument, which may exceed the captured bytes.
+	Size uintptr
+	// Before and After hold the captured bytes, or nil if the buffer was unreadable.
+	Before, After []byte
+}
+
+// Signature describes the parameters and result of an export for decoded tracing.
+type Signature struct {
+	Params []Param
+	// Result renders R1; nil renders it as hex.
+	Result Decoder
+	// Convention says how the export reports failure, for CheckedCallOriginal and Bind.
+	Convention Convention
+}
+
+// Redacted is shown in place of arguments marked with Param.Redact.
+const Redacted = "<redacted>"
+
+// DecodedArg is one argument rendered through a Signature.
+type DecodedArg struct {
+	Name  string
+	Value string
+}
+
+// RegisterSignature records the prototype of funcName, so tracers print named, decoded
+// parameters instead of raw values:
+//
+//	m.RegisterSignature("CreateFileW", &proxdll.Signature{
+//		Params: []proxdll.Param{
+//			{Name: "lpFileName", Decode: proxdll.WString},
+//			{Name: "dwDesiredAccess", Decode: proxdll.AccessMask},
+//		},
+//		Result: proxdll.Handle,
+//	})
+//
+// Arguments beyond the declared parameters are not shown.
+func (m *Manager) RegisterSignature(funcName string, sig *Signature) {
+	m.mu.Lock()
+	m.signatures[funcName] = sig
+	m.mu.Unlock()
+}
+
+// Signature returns the prototype registered for funcName, or nil.
+func (m *Manager) Signature(funcName string) *Signature {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.signatures[funcName]
+}
+
+// redactArgs returns args with the arguments marked Redact zeroed. args itself is not
+// modified; a copy is made when anything is redacted. sig may be nil.
+func (sig *Signature) redactArgs(args []uintptr) []uintptr {
+	if sig == nil {
+		return args
+	}
+	copied := false
+	for i, p := range sig.Params {
+		if !p.Redact || i >= len(args) {
+			continue
+		}
+		if !copied {
+			args = slices.Clone(args)
+			copied = true
+		}
+		args[i] = 0
+	}
+	return args
+}
+
+// hasBuffers reports whether any parameter of sig is a buffer.
+func (sig *Signature) hasBuffers() bool {
+	for _, p := range sig.Params {
+		if p.Buffer != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// captureBuffers reads the buffer arguments of a call described by sig.
+// With prev nil it records the contents before the call; otherwise it fills in After.
+func (sig *Signature) captureBuffers(args []uintptr, prev []BufferCapture) []BufferCapture {
+	out := prev
+	j := 0
+	for i, p := range sig.Params {
+		if p.Buffer == nil || p.Redact || i >= len(args) {
+			continue
+		}
+		size, n := p.Buffer.bounds(args)
+		var data []byte
+		if n > 0 {
+			data = make([]byte, n)
+			if ReadMemory(args[i], data) != nil {
+				data = nil
+			}
+		}
+		if prev == nil {
+			out = append(out, BufferCapture{Name: p.Name, Arg: i, Size: size, Before: data})
+		} else if j < len(out) {
+			out[j].After = data
+		}
+		j++
+	}
+	return out
+}
+
+// bounds returns the declared size of the buffer and the number of bytes to capture.
+func (b *BufferSpec) bounds(args []uintptr) (size uintptr, n int) {
+	limit := b.Max
+	if limit <= 0 {
+		limit = defaultBufferCapture
+	}
+	if b.SizeArg < 0 {
+		return uintptr(limit), limit
+	}
+	if b.SizeArg >= len(args) {
+		return 0, 0
+	}
+	size = args[b.SizeArg]
+	return size, int(min(size, uintptr(limit)))
+}
+
+// HexDump renders captured bytes as space-separated hex pairs, marking truncation
+// when the buffer's declared size exceeds the captured bytes.
+func (c *BufferCapture) HexDump(data []byte) string {
+	if data == nil {
+		return "<unreadable>"
+	}
+	var b strings.Builder
+	for i, x := range data {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		fmt.Fprintf(&b, "%02x", x)
+	}
+	if uintptr(len(data)) < c.Size {
+		fmt.Fprintf(&b, " ... (%d bytes)", c.Size)
+	}
+	return b.String()
+}
+
+// DecodedArgs renders the event's arguments through its Signature.
+// It returns nil if the export has no registered signature.
+func (ev *Event) DecodedArgs() []DecodedArg {
+	if ev.Signature == nil {
+		return nil
+	}
+	n := min(len(ev.Signature.Params), len(ev.Args))
+	args := make([]DecodedArg, n)
+	for i := range n {
+		p := ev.Signature.Params[i]
+		if p.Redact {
+			args[i] = DecodedArg{Name: p.Name, Value: Redacted}
+			continue
+		}
+		args[i] = DecodedArg{Name: p.Name, Value: decode(p.Decode, ev.Args[i])}
+	}
+	return args
+}
+
+// DecodedResult renders R1 through the event's Signature, or as hex without one.
+func (ev *Event) DecodedResult() string {
+	if ev.Signature == nil {
+		return Hex(ev.R1)
+	}
+	return decode(ev.Signature.Result, ev.R1)
+}
+
+func decode(d Decoder, v uintptr) string {
+	if d == nil {
+		return Hex(v)
+	}
+	return d(v)
+}
+
+// Hex renders v as a hexadecimal number.
+func Hex(v uintptr) string {
+	return fmt.Sprintf("%#x", v)
+}
+
+// Uint renders v as an unsigned decimal number.
+func Uint(v uintptr) string {
+	return strconv.FormatUint(uint64(v), 10)
+}
+
+// Int32 renders the low 32 bits of v as a signed decimal, as for INT and LONG parameters.
+func Int32(v uintptr) string {
+	return strconv.FormatInt(int64(int32(v)), 10)
+}
+
+// Bool renders a BOOL as TRUE or FALSE.
+func Bool(v uintptr) string {
+	if uint32(v) != 0 {
+		return "TRUE"
+	}
+	return "FALSE"
+}
+
+// Handle renders a HANDLE, naming NULL and INVALID_HANDLE_VALUE.
+func Handle(v uintptr) string {
+	switch windows.Handle(v) {
+	case 0:
+		return "NULL"
+	case windows.InvalidHandle:
+		return "INVALID_HANDLE_VALUE"
+	}
+	return Hex(v)
+}
+
+// Pointer renders a pointer, naming NULL.
+func Pointer(v uintptr) string {
+	if v == 0 {
+		return "NULL"
+	}
+	return Hex(v)
+}
+
+// WString renders a NUL-terminated UTF-16 string argument, such as LPCWSTR, quoted.
+func WString(v uintptr) string {
+	if v == 0 {
+		return "NULL"
+	}
+	str, truncated, err := ReadWString(v, maxDecodedString)
+	if err != nil {
+		return unreadable(v)
+	}
+	return quoteTruncated(str, truncated)
+}
+
+// String renders a NUL-terminated ANSI string argument, such as LPCSTR, quoted.
+func String(v uintptr) string {
+	if v == 0 {
+		return "NULL"
+	}
+	str, truncated, err := ReadString(v, maxDecodedString)
+	if err != nil {
+		return unreadable(v)
+	}
+	return quoteTruncated(str, truncated)
+}
+
+// unreadable renders a pointer to memory that could not be read.
+func unreadable(v uintptr) string {
+	return fmt.Sprintf("<unreadable %#x>", v)
+}
+
+// quoteTruncated quotes s, marking strings cut at the decoding limit.
+func quoteTruncated(s string, truncated bool) string {
+	q := strconv.Quote(s)
+	if truncated {
+		q += "..."
+	}
+	return q
+}
+
+// formatDecodedArgs renders decoded arguments as a comma-separated name=value list.
+func formatDecodedArgs(args []DecodedArg) string {
+	var b strings.Builder
+	for i, arg := range args {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(arg.Name)
+		b.WriteByte('=')
+		b.WriteString(arg.Value)
+	}
+	return b.String()
+}
@@ -0,0 +1,196 @@
+//go:build windows
+
+package proxdll
+
+import (
+	"fmt"
+	"sync"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// maxSymbolName bounds the length of symbol names returned by dbghelp, in UTF-16 units.
+const maxSymbolName = 512
+
+// dbghelp options: undecorated names, symbols loaded on first use, and no dialogs.
+const symbolOptions = 0x2 | 0x4 | 0x200 | 0x80000 // SYMOPT_UNDNAME | SYMOPT_DEFERRED_LOADS | SYMOPT_FAIL_CRITICAL_ERRORS | SYMOPT_NO_PROMPTS
+
+// symbolInfo mirrors SYMBOL_INFOW, followed by room for the name. The explicit padding
+// keeps Value 8-byte aligned, as in C, on 32-bit platforms too.
+type symbolInfo struct {
+	SizeOfStruct uint32
+	TypeIndex    uint32
+	Reserved     [2]uint64
+	Index        uint32
+	Size         uint32
+	ModBase      uint64
+	Flags        uint32
+	_            uint32
+	Value        uint64
+	Address      uint64
+	Register     uint32
+	Scope        uint32
+	Tag          uint32
+	NameLen      uint32
+	MaxNameLen   uint32
+	Name         [maxSymbolName]uint16
+}
+
+// sizeOfSymbolInfo is sizeof(SYMBOL_INFOW), which dbghelp expects in SizeOfStruct.
+const sizeOfSymbolInfo = 88
+
+// symbolizer resolves addresses to function names with dbghelp. dbghelp is not thread-safe,
+// so every use is serialized. It has its own process handle, so its session does not clash
+// with a host that uses dbghelp too.
+type symbolizer struct {
+	once    sync.Once
+	process windows.Handle
+	err     error
+	mu      sync.Mutex
+	path    string
+	cache   sync.Map // address -> symbolName
+}
+
+type symbolName struct {
+	name   string
+	offset uintptr
+}
+
+// symbols is the process-wide symbolizer; dbghelp keeps one session per process handle.
+var symbols symbolizer
+
+func (s *symbolizer) init() error {
+	s.once.Do(func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		self := windows.CurrentProcess()
+		if s.err = windows.DuplicateHandle(self, self, self, &s.process, 0, false, windows.DUPLICATE_SAME_ACCESS); s.err != nil {
+			return
+		}
+		procSymSetOptions.Call(symbolOptions)
+		// A NULL search path uses the working directory and _NT_SYMBOL_PATH.
+		path, err := utf16PtrOrNil(s.path)
+		if err != nil {
+			s.err = err
+			return
+		}
+		if r, _, err := procSymInitializeW.Call(uintptr(s.process), uintptr(unsafe.Pointer(path)), 1); r == 0 {
+			s.err = err
+		}
+	})
+	return s.err
+}
+
+// setPath sets the search path for PDBs. Addresses already resolved are forgotten, but
+// modules whose symbols were loaded keep them.
+func (s *symbolizer) setPath(path string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.path = path
+	if s.process == 0 {
+		return nil
+	}
+	p, err := utf16PtrOrNil(path)
+	if err != nil {
+		return err
+	}
+	if r, _, err := procSymSetSearchPathW.Call(uintptr(s.process), uintptr(unsafe.Pointer(p))); r == 0 {
+		return fmt.Errorf("failed to set symbol path: %w", err)
+	}
+	s.cache.Clear()
+	return nil
+}
+
+// utf16PtrOrNil converts s for a Win32 call, with nil for the empty string.
+func utf16PtrOrNil(s string) (*uint16, error) {
+	if s == "" {
+		return nil, nil
+	}
+	return windows.UTF16PtrFromString(s)
+}
+
+// symbolize fills in the symbol of c, if dbghelp can find one.
+func (s *symbolizer) symbolize(c *Caller) {
+	if c.Address == 0 {
+		return
+	}
+	if v, ok := s.cache.Load(c.Address); ok {
+		sym := v.(symbolName)
+		c.Symbol, c.SymbolOffset = sym.name, sym.offset
+		return
+	}
+	if s.init() != nil {
+		return
+	}
+
+	s.mu.Lock()
+	sym, ok := s.lookup(c.Address)
+	if !ok {
+		// The module may have been loaded after the session started.
+		procSymRefreshModuleList.Call(uintptr(s.process))
+		sym, _ = s.lookup(c.Address)
+	}
+	s.mu.Unlock()
+
+	s.cache.Store(c.Address, sym)
+	c.Symbol, c.SymbolOffset = sym.name, sym.offset
+}
+
+// lookup calls SymFromAddrW. The caller must hold s.mu.
+func (s *symbolizer) lookup(addr uintptr) (symbolName, bool) {
+	info := &symbolInfo{SizeOfStruct: sizeOfSymbolInfo, MaxNameLen: maxSymbolName}
+	var displacement uint64
+	// The address is a DWORD64, which takes two argument slots on 32-bit platforms.
+	args := append([]uintptr{uintptr(s.process)}, etwUint64Args(uint64(addr))...)
+	args = append(args, uintptr(unsafe.Pointer(&displacement)), uintptr(unsafe.Pointer(info)))
+	r, _, _ := procSymFromAddrW.Call(args...)
+	if r == 0 {
+		return symbolName{}, false
+	}
+	n := min(info.NameLen, maxSymbolName)
+	return symbolName{name: windows.UTF16ToString(info.Name[:n]), offset: uintptr(displacement)}, true
+}
+
+// captureStack walks the calling thread's stack and returns up to depth frames, starting
+// at the caller found by captureCaller, with their symbols when dbghelp finds them.
+func captureStack(depth int) []Caller {
+	lo, hi := selfImage()
+	if lo == hi {
+		return nil
+	}
+
+	var frames [maxCallerFrames]uintptr
+	n, _, _ := procRtlCaptureStackBackTrace.Call(0, maxCallerFrames, uintptr(unsafe.Pointer(&frames[0])), 0)
+	start := 0
+	for start < int(n) && frames[start] >= lo && frames[start] < hi {
+		start++
+	}
+
+	var stack []Caller
+	for _, pc := range frames[start:n] {
+		if len(stack) == depth {
+			break
+		}
+		c := resolveCaller(pc)
+		symbols.symbolize(&c)
+		stack = append(stack, c)
+	}
+	return stack
+}
+
+// wantsStack reports whether calls to funcName record their stack.
+func (m *Manager) wantsStack(funcName string) bool {
+	if m.stackDepth == 0 {
+		return false
+	}
+	if len(m.stackFuncs) == 0 {
+		return true
+	}
+	for _, p := range m.stackFuncs {
+		if p.match(funcName) {
+			return true
+		}
+	}
+	return false
+}
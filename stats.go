@@ -0,0 +1,152 @@
+//go:build windows
+
+package proxdll
+
+import (
+	"maps"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/sys/windows"
+)
+
+// FuncStats is a snapshot of the counters kept for one export.
+type FuncStats struct {
+	// Calls is the number of calls made through Call.
+	Calls uint64
+	// Errors counts calls that returned a lastErr other than nil or ERROR_SUCCESS.
+	// Many Windows functions leave a stale last error on success, so this is an upper bound.
+	Errors uint64
+	// Total is the summed latency of all calls.
+	Total time.Duration
+	// Min, Mean and Max describe the latency of a single call.
+	Min, Mean, Max time.Duration
+	// Buckets counts calls by latency: Buckets[i] is the number of calls that took at most
+	// LatencyBuckets[i] but longer than the previous bound, and the final entry counts the rest.
+	Buckets []uint64
+}
+
+// LatencyBuckets are the upper bounds of the latency histogram kept for each export.
+var LatencyBuckets = []time.Duration{
+	time.Microsecond,
+	10 * time.Microsecond,
+	100 * time.Microsecond,
+	time.Millisecond,
+	10 * time.Millisecond,
+	100 * time.Millisecond,
+	time.Second,
+	10 * time.Second,
+}
+
+// Stats returns a snapshot of the per-export counters, keyed by export name.
+// It returns nil unless the Manager was created with WithStats.
+func (m *Manager) Stats() map[string]FuncStats {
+	if m.stats == nil {
+		return nil
+	}
+	return m.stats.snapshot()
+}
+
+// ResetStats clears all per-export counters.
+func (m *Manager) ResetStats() {
+	if m.stats != nil {
+		m.stats.reset()
+	}
+}
+
+// funcCounters holds the live counters for one export.
+type funcCounters struct {
+	calls  atomic.Uint64
+	errors atomic.Uint64
+	total  atomic.Int64
+	min    atomic.Int64
+	max    atomic.Int64
+	// buckets has one entry per LatencyBuckets bound plus one for larger values.
+	buckets []atomic.Uint64
+}
+
+// statsTable is a copy-on-write map of counters, so recording a call never locks
+// once the export has been seen.
+type statsTable struct {
+	entries atomic.Pointer[map[string]*funcCounters]
+	mu      sync.Mutex // serializes stores
+}
+
+// record adds one call of funcName that took d and returned lastErr.
+func (t *statsTable) record(funcName string, d time.Duration, lastErr error) {
+	c := t.counters(funcName)
+	c.calls.Add(1)
+	if lastErr != nil && lastErr != windows.ERROR_SUCCESS {
+		c.errors.Add(1)
+	}
+	ns := int64(d)
+	c.total.Add(ns)
+	for cur := c.min.Load(); ns < cur && !c.min.CompareAndSwap(cur, ns); cur = c.min.Load() {
+	}
+	for cur := c.max.Load(); ns > cur && !c.max.CompareAndSwap(cur, ns); cur = c.max.Load() {
+	}
+
+	i := 0
+	for i < len(LatencyBuckets) && d > LatencyBuckets[i] {
+		i++
+	}
+	c.buckets[i].Add(1)
+}
+
+// counters returns the counters for funcName, creating them on first use.
+func (t *statsTable) counters(funcName string) *funcCounters {
+	if entries := t.entries.Load(); entries != nil {
+		if c, ok := (*entries)[funcName]; ok {
+			return c
+		}
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	next := make(map[string]*funcCounters)
+	if entries := t.entries.Load(); entries != nil {
+		if c, ok := (*entries)[funcName]; ok {
+			return c
+		}
+		maps.Copy(next, *entries)
+	}
+	c := &funcCounters{buckets: make([]atomic.Uint64, len(LatencyBuckets)+1)}
+	c.min.Store(math.MaxInt64)
+	next[funcName] = c
+	t.entries.Store(&next)
+	return c
+}
+
+func (t *statsTable) snapshot() map[string]FuncStats {
+	out := make(map[string]FuncStats)
+	entries := t.entries.Load()
+	if entries == nil {
+		return out
+	}
+	for name, c := range *entries {
+		s := FuncStats{
+			Calls:  c.calls.Load(),
+			Errors: c.errors.Load(),
+			Total:  time.Duration(c.total.Load()),
+			Max:    time.Duration(c.max.Load()),
+		}
+		for i := range c.buckets {
+			s.Buckets = append(s.Buckets, c.buckets[i].Load())
+		}
+		if s.Calls > 0 {
+			s.Min = time.Duration(c.min.Load())
+			s.Mean = s.Total / time.Duration(s.Calls)
+		}
+		out[name] = s
+	}
+	return out
+}
+
+func (t *statsTable) reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.entries.Store(nil)
+}
@@ -0,0 +1,55 @@
+//go:build windows
+
+package proxdll
+
+import "golang.org/x/sys/windows"
+
+// System functions not wrapped by golang.org/x/sys/windows.
+var (
+	modkernel32 = windows.NewLazySystemDLL("kernel32.dll")
+	modadvapi32 = windows.NewLazySystemDLL("advapi32.dll")
+	modntdll    = windows.NewLazySystemDLL("ntdll.dll")
+	moddbghelp  = windows.NewLazySystemDLL("dbghelp.dll")
+	modcrypt32  = windows.NewLazySystemDLL("crypt32.dll")
+	modwintrust = windows.NewLazySystemDLL("wintrust.dll")
+
+	procOutputDebugStringW             = modkernel32.NewProc("OutputDebugStringW")
+	procGetModuleHandleExW             = modkernel32.NewProc("GetModuleHandleExW")
+	procSetUnhandledExceptionFilter    = modkernel32.NewProc("SetUnhandledExceptionFilter")
+	procTlsAlloc                       = modkernel32.NewProc("TlsAlloc")
+	procTlsGetValue                    = modkernel32.NewProc("TlsGetValue")
+	procTlsSetValue                    = modkernel32.NewProc("TlsSetValue")
+	procFlushInstructionCache          = modkernel32.NewProc("FlushInstructionCache")
+	procGetSystemWow64DirectoryW       = modkernel32.NewProc("GetSystemWow64DirectoryW")
+	procWow64DisableWow64FsRedirection = modkernel32.NewProc("Wow64DisableWow64FsRedirection")
+	procWow64RevertWow64FsRedirection  = modkernel32.NewProc("Wow64RevertWow64FsRedirection")
+
+	procRtlIsCriticalSectionLockedByThread = modntdll.NewProc("RtlIsCriticalSectionLockedByThread")
+	procRtlCaptureStackBackTrace           = modntdll.NewProc("RtlCaptureStackBackTrace")
+	procRtlAddFunctionTable                = modntdll.NewProc("RtlAddFunctionTable")
+	procRtlDeleteFunctionTable             = modntdll.NewProc("RtlDeleteFunctionTable")
+
+	procMiniDumpWriteDump     = moddbghelp.NewProc("MiniDumpWriteDump")
+	procSymInitializeW        = moddbghelp.NewProc("SymInitializeW")
+	procSymSetOptions         = moddbghelp.NewProc("SymSetOptions")
+	procSymSetSearchPathW     = moddbghelp.NewProc("SymSetSearchPathW")
+	procSymFromAddrW          = moddbghelp.NewProc("SymFromAddrW")
+	procSymRefreshModuleList  = moddbghelp.NewProc("SymRefreshModuleList")
+	procUnDecorateSymbolNameW = moddbghelp.NewProc("UnDecorateSymbolNameW")
+
+	procCryptMsgGetParam = modcrypt32.NewProc("CryptMsgGetParam")
+	procCryptMsgClose    = modcrypt32.NewProc("CryptMsgClose")
+
+	procCryptCATAdminAcquireContext2         = modwintrust.NewProc("CryptCATAdminAcquireContext2")
+	procCryptCATAdminReleaseContext          = modwintrust.NewProc("CryptCATAdminReleaseContext")
+	procCryptCATAdminCalcHashFromFileHandle2 = modwintrust.NewProc("CryptCATAdminCalcHashFromFileHandle2")
+	procCryptCATAdminEnumCatalogFromHash     = modwintrust.NewProc("CryptCATAdminEnumCatalogFromHash")
+	procCryptCATAdminReleaseCatalogContext   = modwintrust.NewProc("CryptCATAdminReleaseCatalogContext")
+	procCryptCATCatalogInfoFromContext       = modwintrust.NewProc("CryptCATCatalogInfoFromContext")
+
+	procEventRegister        = modadvapi32.NewProc("EventRegister")
+	procEventUnregister      = modadvapi32.NewProc("EventUnregister")
+	procEventSetInformation  = modadvapi32.NewProc("EventSetInformation")
+	procEventProviderEnabled = modadvapi32.NewProc("EventProviderEnabled")
+	procEventWriteTransfer   = modadvapi32.NewProc("EventWriteTransfer")
+)
@@ -0,0 +1,92 @@
+//go:build windows
+
+package proxdll
+
+import "slices"
+
+// ThreadObserver is implemented by tracers that keep per-thread state, such as buffers
+// keyed by thread ID, so they can set it up and release it as threads come and go.
+// Active tracers implementing it are notified by NotifyThreadAttach and NotifyThreadDetach.
+type ThreadObserver interface {
+	ThreadAttached(threadID uint32)
+	ThreadDetached(threadID uint32)
+}
+
+// threadCallback gives each registered thread callback an identity, since funcs are not comparable.
+type threadCallback struct {
+	fn     func(threadID uint32)
+	detach bool
+}
+
+// OnThreadAttach registers fn to run for every thread attach notification and returns a
+// function that unregisters it.
+func (m *Manager) OnThreadAttach(fn func(threadID uint32)) (remove func()) {
+	return m.addThreadCallback(&threadCallback{fn: fn})
+}
+
+// OnThreadDetach registers fn to run for every thread detach notification and returns a
+// function that unregisters it.
+func (m *Manager) OnThreadDetach(fn func(threadID uint32)) (remove func()) {
+	return m.addThreadCallback(&threadCallback{fn: fn, detach: true})
+}
+
+func (m *Manager) addThreadCallback(cb *threadCallback) (remove func()) {
+	m.mu.Lock()
+	m.threadCallbacks = append(m.threadCallbacks, cb)
+	m.mu.Unlock()
+
+	return func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		if i := slices.Index(m.threadCallbacks, cb); i >= 0 {
+			m.threadCallbacks = slices.Delete(m.threadCallbacks, i, i+1)
+		}
+	}
+}
+
+// NotifyThreadAttach reports that a thread started, running the OnThreadAttach callbacks
+// and then notifying tracers that implement ThreadObserver.
+// The Manager has no DllMain of its own; wire it to one with the lifecycle package:
+//
+//	lifecycle.OnThreadAttach(m.NotifyThreadAttach)
+//	lifecycle.OnThreadDetach(m.NotifyThreadDetach)
+func (m *Manager) NotifyThreadAttach(threadID uint32) {
+	m.notifyThread(threadID, false)
+}
+
+// NotifyThreadDetach reports that a thread exited, running the OnThreadDetach callbacks
+// and then notifying tracers that implement ThreadObserver.
+func (m *Manager) NotifyThreadDetach(threadID uint32) {
+	m.notifyThread(threadID, true)
+}
+
+func (m *Manager) notifyThread(threadID uint32, detach bool) {
+	m.mu.RLock()
+	var fns []func(uint32)
+	for _, cb := range m.threadCallbacks {
+		if cb.detach == detach {
+			fns = append(fns, cb.fn)
+		}
+	}
+	m.mu.RUnlock()
+
+	for _, fn := range fns {
+		fn(threadID)
+	}
+	if t := m.tracers.Load(); t != nil {
+		t.notifyThread(threadID, detach)
+	}
+}
+
+// notifyThread passes a thread notification to the tracers that implement ThreadObserver.
+func (t multiTracer) notifyThread(threadID uint32, detach bool) {
+	for _, tracer := range t {
+		if o, ok := tracer.(ThreadObserver); ok {
+			if detach {
+				o.ThreadDetached(threadID)
+			} else {
+				o.ThreadAttached(threadID)
+			}
+		}
+	}
+}
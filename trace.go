@@ -0,0 +1,194 @@
+//go:build windows
+
+package proxdll
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"slices"
+	"time"
+)
+
+// Event describes one call made through Manager.Call.
+type Event struct {
+	// Func is the name of the called export.
+	Func string
+	// Args holds the raw arguments passed by the host.
+	Args []uintptr
+	// R1 and R2 are the values returned to the host.
+	R1, R2 uintptr
+	// LastErr is the error returned to the host, usually a windows.Errno from GetLastError.
+	LastErr error
+	// Start is when the call entered the proxy.
+	Start time.Time
+	// Duration is how long the call took, including any hooks.
+	Duration time.Duration
+	// ThreadID identifies the calling thread.
+	ThreadID uint32
+	// Caller identifies the code that made the call, if WithCallers is given.
+	Caller Caller
+	// Stack holds the host's frames from Caller outwards, if WithStacks selects Func.
+	Stack []Caller
+	// Undecorated is the C++ declaration Func is mangled from, if WithUndecoratedNames
+	// is given and Func is an MSVC-mangled name.
+	Undecorated string
+	// Signature is the prototype registered for Func with RegisterSignature, or nil.
+	Signature *Signature
+	// Buffers holds the contents of buffer parameters declared by Signature.
+	// Before is set when TraceStart runs; After once the call has returned.
+	Buffers []BufferCapture
+
+	// limited is the copy with fewer Args that config sinks receive, see configTracer.
+	limited *Event
+}
+
+// Tracer receives an Event for every call made through Manager.Call.
+// Trace is called synchronously on the calling thread after the call returns,
+// so implementations must be safe for concurrent use and should return quickly.
+// Tracers that buffer events may also implement io.Closer to be flushed on shutdown.
+type Tracer interface {
+	Trace(ev *Event)
+}
+
+// StartTracer is implemented by tracers that are also notified when a call begins.
+// TraceStart receives the event before the call runs. Func, Args, Start, ThreadID and
+// Signature are set, as are Undecorated, Caller and Stack when enabled and the Before
+// captures of Buffers; R1, R2, LastErr, Duration and the After captures are not set
+// until the same event is later passed to Trace.
+type StartTracer interface {
+	Tracer
+	TraceStart(ev *Event)
+}
+
+// TracerFunc adapts an ordinary function to the Tracer interface.
+type TracerFunc func(ev *Event)
+
+// Trace calls f(ev).
+func (f TracerFunc) Trace(ev *Event) {
+	f(ev)
+}
+
+// MultiTracer returns a Tracer that forwards every event to each of tracers in order.
+// Closing it closes every tracer that implements io.Closer.
+func MultiTracer(tracers ...Tracer) Tracer {
+	return multiTracer(tracers)
+}
+
+type multiTracer []Tracer
+
+func (t multiTracer) Trace(ev *Event) {
+	for _, tracer := range t {
+		tracer.Trace(ev)
+	}
+}
+
+func (t multiTracer) TraceStart(ev *Event) {
+	for _, tracer := range t {
+		if st, ok := tracer.(StartTracer); ok {
+			st.TraceStart(ev)
+		}
+	}
+}
+
+func (t multiTracer) ThreadAttached(threadID uint32) {
+	t.notifyThread(threadID, false)
+}
+
+func (t multiTracer) ThreadDetached(threadID uint32) {
+	t.notifyThread(threadID, true)
+}
+
+func (t multiTracer) Close() error {
+	var errs []error
+	for _, tracer := range t {
+		if c, ok := tracer.(io.Closer); ok {
+			errs = append(errs, c.Close())
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// AddTracer starts sending call events to tracer and returns a function that stops it.
+// Tracers run in the order they were added.
+func (m *Manager) AddTracer(tracer Tracer) (remove func()) {
+	entry := &tracerEntry{tracer: tracer}
+
+	m.mu.Lock()
+	m.tracerEntries = append(m.tracerEntries, entry)
+	m.publishTracers()
+	m.mu.Unlock()
+
+	return func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		if i := slices.Index(m.tracerEntries, entry); i >= 0 {
+			m.tracerEntries = slices.Delete(m.tracerEntries, i, i+1)
+			m.publishTracers()
+		}
+	}
+}
+
+// tracerEntry gives each added tracer an identity, since tracers need not be comparable.
+type tracerEntry struct {
+	tracer Tracer
+}
+
+// publishTracers rebuilds the tracer snapshot read by Call. The caller must hold m.mu.
+func (m *Manager) publishTracers() {
+	if len(m.tracerEntries) == 0 {
+		m.tracers.Store(nil)
+		return
+	}
+	next := make(multiTracer, len(m.tracerEntries))
+	for i, entry := range m.tracerEntries {
+		next[i] = entry.tracer
+	}
+	m.tracers.Store(&next)
+}
+
+// tracer returns the active tracers, or nil if there are none.
+func (m *Manager) tracer() Tracer {
+	if t := m.tracers.Load(); t != nil {
+		return *t
+	}
+	return nil
+}
+
+// LogTracer returns a Tracer that writes each event to logger at the given level.
+func LogTracer(logger *slog.Logger, level slog.Level) Tracer {
+	return TracerFunc(func(ev *Event) {
+		ctx := context.Background()
+		if !logger.Enabled(ctx, level) {
+			return
+		}
+		args := slog.Any("args", ev.Args)
+		if ev.Signature != nil {
+			args = slog.String("args", formatDecodedArgs(ev.DecodedArgs()))
+		}
+		attrs := []slog.Attr{
+			slog.String("func", ev.Func),
+			args,
+			slog.Uint64("r1", uint64(ev.R1)),
+			slog.Uint64("r2", uint64(ev.R2)),
+			slog.Any("lastErr", ev.LastErr),
+			slog.Duration("duration", ev.Duration),
+			slog.Uint64("tid", uint64(ev.ThreadID)),
+		}
+		if ev.Undecorated != "" {
+			attrs = append(attrs, slog.String("undecorated", ev.Undecorated))
+		}
+		if ev.Caller.Address != 0 {
+			attrs = append(attrs, slog.String("caller", ev.Caller.String()))
+		}
+		if len(ev.Stack) > 0 {
+			frames := make([]string, len(ev.Stack))
+			for i, f := range ev.Stack {
+				frames[i] = f.String()
+			}
+			attrs = append(attrs, slog.Any("stack", frames))
+		}
+		logger.LogAttrs(ctx, level, "call", attrs...)
+	})
+}
@@ -0,0 +1,140 @@
+//go:build windows
+
+package proxdll
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// ChromeTracer writes events in the Chrome trace_event JSON array format, which
+// chrome://tracing and Perfetto display as a timeline with one lane per thread.
+// Each call becomes a complete ("X") event, so nested calls stack within their lane.
+// Output is buffered; Close terminates the array and must be called for a well-formed file,
+// although both viewers also accept files truncated by a crash.
+type ChromeTracer struct {
+	mu     sync.Mutex
+	w      *bufio.Writer
+	closer io.Closer
+	base   time.Time
+	pid    int
+	first  bool
+	closed bool
+}
+
+// chromeEvent is a single trace_event record.
+type chromeEvent struct {
+	Name  string         `json:"name"`
+	Cat   string         `json:"cat"`
+	Phase string         `json:"ph"`
+	TS    float64        `json:"ts"`
+	Dur   float64        `json:"dur"`
+	PID   int            `json:"pid"`
+	TID   uint32         `json:"tid"`
+	Args  map[string]any `json:"args,omitempty"`
+}
+
+// NewChromeTracer returns a ChromeTracer writing to w.
+// If w is an io.Closer, closing the tracer closes it too.
+func NewChromeTracer(w io.Writer) *ChromeTracer {
+	t := &ChromeTracer{
+		w:     bufio.NewWriter(w),
+		base:  time.Now(),
+		pid:   os.Getpid(),
+		first: true,
+	}
+	if c, ok := w.(io.Closer); ok {
+		t.closer = c
+	}
+	t.w.WriteString("[\n")
+	return t
+}
+
+// CreateChromeTracer creates or truncates the file at path and returns a ChromeTracer writing to it.
+func CreateChromeTracer(path string) (*ChromeTracer, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewChromeTracer(f), nil
+}
+
+// Trace appends ev to the trace as a complete event.
+func (t *ChromeTracer) Trace(ev *Event) {
+	args := make(map[string]any, len(ev.Args)+3)
+	if ev.Signature != nil {
+		for _, arg := range ev.DecodedArgs() {
+			args[arg.Name] = arg.Value
+		}
+	} else {
+		for i, arg := range ev.Args {
+			args[fmt.Sprintf("a%d", i)] = fmt.Sprintf("%#x", arg)
+		}
+	}
+	for _, buf := range ev.Buffers {
+		args[buf.Name+".before"] = buf.HexDump(buf.Before)
+		args[buf.Name+".after"] = buf.HexDump(buf.After)
+	}
+	args["r1"] = ev.DecodedResult()
+	args["r2"] = fmt.Sprintf("%#x", ev.R2)
+	if ev.LastErr != nil {
+		args["lastErr"] = ev.LastErr.Error()
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return
+	}
+
+	rec := chromeEvent{
+		Name:  ev.DisplayName(),
+		Cat:   "call",
+		Phase: "X",
+		TS:    float64(ev.Start.Sub(t.base).Nanoseconds()) / 1e3,
+		Dur:   float64(ev.Duration.Nanoseconds()) / 1e3,
+		PID:   t.pid,
+		TID:   ev.ThreadID,
+		Args:  args,
+	}
+	data, err := json.Marshal(&rec)
+	if err != nil {
+		return
+	}
+	if !t.first {
+		t.w.WriteString(",\n")
+	}
+	t.first = false
+	t.w.Write(data)
+}
+
+// Flush writes any buffered events to the underlying writer.
+func (t *ChromeTracer) Flush() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.w.Flush()
+}
+
+// Close terminates the event array, flushes it and closes the underlying writer,
+// if it is an io.Closer. Events traced after Close are dropped.
+func (t *ChromeTracer) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return nil
+	}
+	t.closed = true
+
+	t.w.WriteString("\n]\n")
+	err := t.w.Flush()
+	if t.closer != nil {
+		err = errors.Join(err, t.closer.Close())
+	}
+	return err
+}
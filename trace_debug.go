@@ -0,0 +1,59 @@
+//go:build windows
+
+package proxdll
+
+import (
+	"fmt"
+	"strings"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// DebugTracer returns a Tracer that writes one line per event with OutputDebugStringW,
+// so traces appear in DebugView or an attached debugger without any file or console.
+func DebugTracer() Tracer {
+	return TracerFunc(func(ev *Event) {
+		line, err := windows.UTF16PtrFromString(formatEvent(ev) + "\n")
+		if err != nil {
+			return
+		}
+		procOutputDebugStringW.Call(uintptr(unsafe.Pointer(line)))
+	})
+}
+
+// formatEvent renders ev as a single human-readable line.
+func formatEvent(ev *Event) string {
+	var b strings.Builder
+	if ev.Signature != nil {
+		fmt.Fprintf(&b, "proxdll: [%d] %s(%s) = %s", ev.ThreadID, ev.DisplayName(), formatDecodedArgs(ev.DecodedArgs()), ev.DecodedResult())
+	} else {
+		fmt.Fprintf(&b, "proxdll: [%d] %s(%s) = %#x, %#x", ev.ThreadID, ev.DisplayName(), formatArgs(ev.Args), ev.R1, ev.R2)
+	}
+	if ev.LastErr != nil {
+		fmt.Fprintf(&b, " lastErr=%v", ev.LastErr)
+	}
+	fmt.Fprintf(&b, " (%s)", ev.Duration)
+	if ev.Caller.Address != 0 {
+		fmt.Fprintf(&b, " from %s", ev.Caller)
+	}
+	for _, frame := range ev.Stack {
+		fmt.Fprintf(&b, "\n  at %s", frame)
+	}
+	for _, buf := range ev.Buffers {
+		fmt.Fprintf(&b, "\n  %s before: %s\n  %s after:  %s", buf.Name, buf.HexDump(buf.Before), buf.Name, buf.HexDump(buf.After))
+	}
+	return b.String()
+}
+
+// formatArgs renders raw arguments as a comma-separated list of hex values.
+func formatArgs(args []uintptr) string {
+	var b strings.Builder
+	for i, arg := range args {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		fmt.Fprintf(&b, "%#x", arg)
+	}
+	return b.String()
+}
@@ -0,0 +1,132 @@
+//go:build windows
+
+package proxdll
+
+import (
+	"bufio"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"golang.org/x/sys/windows"
+)
+
+// JSONLTracer writes one JSON object per event, separated by newlines.
+// Output is buffered; call Flush or Close to write it out.
+type JSONLTracer struct {
+	mu     sync.Mutex
+	w      *bufio.Writer
+	enc    *json.Encoder
+	closer io.Closer
+}
+
+// jsonEvent is the JSON Lines representation of an Event.
+type jsonEvent struct {
+	Time        string       `json:"ts"`
+	ThreadID    uint32       `json:"tid"`
+	Caller      *Caller      `json:"caller,omitempty"`
+	Stack       []Caller     `json:"stack,omitempty"`
+	Func        string       `json:"func"`
+	Undecorated string       `json:"undecorated,omitempty"`
+	Args        []uintptr    `json:"args"`
+	Params      []DecodedArg `json:"params,omitempty"`
+	Buffers     []jsonBuffer `json:"buffers,omitempty"`
+	R1          uintptr      `json:"r1"`
+	R2          uintptr      `json:"r2"`
+	LastErr     *uint32      `json:"lastErr,omitempty"`
+	Error       string       `json:"error,omitempty"`
+	DurationNS  int64        `json:"durNs"`
+}
+
+// jsonBuffer is the JSON Lines representation of a BufferCapture, with contents in hex.
+type jsonBuffer struct {
+	Name   string  `json:"name"`
+	Arg    int     `json:"arg"`
+	Size   uintptr `json:"size"`
+	Before string  `json:"before,omitempty"`
+	After  string  `json:"after,omitempty"`
+}
+
+// NewJSONLTracer returns a JSONLTracer writing to w.
+// If w is an io.Closer, closing the tracer closes it too.
+func NewJSONLTracer(w io.Writer) *JSONLTracer {
+	t := &JSONLTracer{w: bufio.NewWriter(w)}
+	t.enc = json.NewEncoder(t.w)
+	if c, ok := w.(io.Closer); ok {
+		t.closer = c
+	}
+	return t
+}
+
+// CreateJSONLTracer creates or truncates the file at path and returns a JSONLTracer writing to it.
+func CreateJSONLTracer(path string) (*JSONLTracer, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewJSONLTracer(f), nil
+}
+
+// Trace writes ev as a single JSON line. Encoding errors are dropped.
+func (t *JSONLTracer) Trace(ev *Event) {
+	rec := jsonEvent{
+		Time:        ev.Start.UTC().Format(time.RFC3339Nano),
+		ThreadID:    ev.ThreadID,
+		Func:        ev.Func,
+		Undecorated: ev.Undecorated,
+		Args:        ev.Args,
+		R1:          ev.R1,
+		R2:          ev.R2,
+		DurationNS:  ev.Duration.Nanoseconds(),
+		Stack:       ev.Stack,
+	}
+	if ev.Caller.Address != 0 {
+		rec.Caller = &ev.Caller
+	}
+	if ev.Signature != nil {
+		rec.Params = ev.DecodedArgs()
+	}
+	for _, buf := range ev.Buffers {
+		rec.Buffers = append(rec.Buffers, jsonBuffer{
+			Name:   buf.Name,
+			Arg:    buf.Arg,
+			Size:   buf.Size,
+			Before: hex.EncodeToString(buf.Before),
+			After:  hex.EncodeToString(buf.After),
+		})
+	}
+	var errno windows.Errno
+	if errors.As(ev.LastErr, &errno) {
+		code := uint32(errno)
+		rec.LastErr = &code
+	} else if ev.LastErr != nil {
+		rec.Error = ev.LastErr.Error()
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.enc.Encode(&rec)
+}
+
+// Flush writes any buffered events to the underlying writer.
+func (t *JSONLTracer) Flush() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.w.Flush()
+}
+
+// Close flushes buffered events and closes the underlying writer, if it is an io.Closer.
+func (t *JSONLTracer) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	err := t.w.Flush()
+	if t.closer != nil {
+		err = errors.Join(err, t.closer.Close())
+	}
+	return err
+}
@@ -0,0 +1,94 @@
+//go:build windows
+
+package proxdll
+
+import (
+	"fmt"
+
+	"github.com/nilssoncreative/proxdll/pefile"
+)
+
+// ExportReport is the result of ValidateExports.
+type ExportReport struct {
+	// Missing lists exports of the original that the proxy does not export, which a host
+	// importing them fails to load against, or crashes on when resolving them late.
+	Missing []Export
+	// Extra lists exports of the proxy that the original does not have.
+	Extra []Export
+	// Renumbered lists named exports of the original that the proxy exports under a
+	// different ordinal, which breaks hosts importing them by ordinal.
+	Renumbered []Export
+}
+
+// OK reports whether the proxy covers every export of the original with the same ordinals.
+func (r *ExportReport) OK() bool {
+	return len(r.Missing) == 0 && len(r.Renumbered) == 0
+}
+
+// ValidateExports compares the export table of the running proxy DLL with that of the
+// original, loading it if needed, and logs a warning for each export the proxy fails
+// to cover.
+func (m *Manager) ValidateExports() (*ExportReport, error) {
+	original, err := m.exportTable()
+	if err != nil {
+		return nil, err
+	}
+	self, err := SelfPath()
+	if err != nil {
+		return nil, err
+	}
+	proxy, err := pefile.OpenExports(self)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read export table of %s: %w", self, err)
+	}
+
+	report := compareExports(original, proxy)
+	for _, exp := range report.Missing {
+		m.logger.Warn("export of original DLL missing from proxy", "func", exportName(exp), "ordinal", exp.Ordinal)
+	}
+	for _, exp := range report.Renumbered {
+		m.logger.Warn("export of original DLL has a different ordinal in proxy", "func", exp.Name, "ordinal", exp.Ordinal)
+	}
+	return report, nil
+}
+
+// compareExports reports how proxy deviates from original. Named exports are matched by
+// name, and exports without a name by ordinal.
+func compareExports(original, proxy *pefile.ExportTable) *ExportReport {
+	report := &ExportReport{}
+	for _, exp := range original.Exports {
+		got, ok := lookupExport(proxy, exportName(exp))
+		switch {
+		case !ok:
+			report.Missing = append(report.Missing, exp)
+		case got.Ordinal != exp.Ordinal:
+			report.Renumbered = append(report.Renumbered, exp)
+		}
+	}
+	for _, exp := range proxy.Exports {
+		if _, ok := lookupExport(original, exportName(exp)); !ok {
+			report.Extra = append(report.Extra, exp)
+		}
+	}
+	return report
+}
+
+// exportName returns the name of exp, or "#N" if it is exported by ordinal only.
+func exportName(exp Export) string {
+	if exp.Name != "" {
+		return exp.Name
+	}
+	return ordinalName(exp.Ordinal)
+}
+
+// validateExports runs ValidateExports for WithExportValidation.
+func (m *Manager) validateExports(report func(*ExportReport)) {
+	r, err := m.ValidateExports()
+	if err != nil {
+		m.logger.Warn("failed to validate exports", "error", err)
+		return
+	}
+	if report != nil {
+		report(r)
+	}
+}
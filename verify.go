@@ -0,0 +1,117 @@
+//go:build windows
+
+package proxdll
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrVerification is wrapped by load errors caused by an original DLL that failed a check
+// such as WithExpectedHash.
+var ErrVerification = errors.New("proxdll: original DLL failed verification")
+
+// verifier checks the file of the original DLL before calls are forwarded to it.
+type verifier struct {
+	name  string
+	check func(path string) error
+	// checkImage checks an image given by WithEmbeddedImage, or is nil for checks that
+	// need the file on disk.
+	checkImage func(image []byte) error
+}
+
+// verify runs the verifiers on the original DLL file at path. Failures are returned,
+// or only logged after WithVerifyWarnOnly.
+func (m *Manager) verify(path string) error {
+	return m.runVerifiers(path, func(v verifier) error { return v.check(path) })
+}
+
+// verifyImage runs the verifiers on the image given by WithEmbeddedImage for the
+// original DLL named name, as verify does for files.
+func (m *Manager) verifyImage(name string, image []byte) error {
+	return m.runVerifiers(name+" (embedded image)", func(v verifier) error { return v.checkImage(image) })
+}
+
+// checkImageVerifiers reports an error if a verifier cannot check an embedded image.
+func (m *Manager) checkImageVerifiers() error {
+	for _, v := range m.verifiers {
+		if v.checkImage == nil {
+			return fmt.Errorf("the %s check needs the original DLL on disk and cannot verify an image given by WithEmbeddedImage", v.name)
+		}
+	}
+	return nil
+}
+
+func (m *Manager) runVerifiers(path string, check func(v verifier) error) error {
+	for _, v := range m.verifiers {
+		err := check(v)
+		if err == nil {
+			m.logger.Debug("verified original DLL", "check", v.name, "path", path)
+			continue
+		}
+		if m.verifyWarnOnly {
+			m.logger.Warn("original DLL failed verification", "check", v.name, "path", path, "error", err)
+			continue
+		}
+		return fmt.Errorf("%w: %s check of %s: %w", ErrVerification, v.name, path, err)
+	}
+	return nil
+}
+
+// verifiesBeforeLoad reports whether path names the file the loader will map, so it can
+// be verified before any of its code runs. Other paths are verified once loaded.
+func verifiesBeforeLoad(path string) bool {
+	if !filepath.IsAbs(path) {
+		return false
+	}
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// FileSHA256 returns the hex-encoded SHA-256 digest of the file at path, as accepted by
+// WithExpectedHash.
+func FileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("failed to hash %s: %w", path, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// hashVerifier accepts files and embedded images whose SHA-256 digest is one of sums.
+func hashVerifier(sums []string) verifier {
+	match := func(sum string) error {
+		for _, want := range sums {
+			if strings.EqualFold(sum, want) {
+				return nil
+			}
+		}
+		return fmt.Errorf("SHA-256 %s matches none of the %d expected", sum, len(sums))
+	}
+	return verifier{
+		name: "hash",
+		check: func(path string) error {
+			sum, err := FileSHA256(path)
+			if err != nil {
+				return err
+			}
+			return match(sum)
+		},
+		checkImage: func(image []byte) error {
+			sum := sha256.Sum256(image)
+			return match(hex.EncodeToString(sum[:]))
+		},
+	}
+}
@@ -0,0 +1,136 @@
+//go:build windows
+
+package proxdll
+
+import (
+	"cmp"
+	"fmt"
+	"strconv"
+	"strings"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// Version is a four-part file or product version, such as 10.0.19041.1.
+type Version [4]uint16
+
+// ParseVersion parses a version of one to four dot-separated parts, such as "6.2" or
+// "10.0.19041.1". Missing parts are zero.
+func ParseVersion(s string) (Version, error) {
+	var v Version
+	parts := strings.Split(s, ".")
+	if len(parts) > len(v) {
+		return Version{}, fmt.Errorf("invalid version %q: more than %d parts", s, len(v))
+	}
+	for i, part := range parts {
+		n, err := strconv.ParseUint(part, 10, 16)
+		if err != nil {
+			return Version{}, fmt.Errorf("invalid version %q: %w", s, err)
+		}
+		v[i] = uint16(n)
+	}
+	return v, nil
+}
+
+func (v Version) String() string {
+	return fmt.Sprintf("%d.%d.%d.%d", v[0], v[1], v[2], v[3])
+}
+
+// Compare returns -1, 0 or +1 as v is older than, equal to or newer than w.
+func (v Version) Compare(w Version) int {
+	for i := range v {
+		if c := cmp.Compare(v[i], w[i]); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// VersionInfo holds the VERSIONINFO resource of a DLL.
+type VersionInfo struct {
+	FileVersion    Version
+	ProductVersion Version
+	// The string fields are read from the first language listed in the resource,
+	// and are empty if it does not define them.
+	CompanyName     string
+	FileDescription string
+	ProductName     string
+}
+
+// ReadVersionInfo reads the version resource of the file at path.
+func ReadVersionInfo(path string) (*VersionInfo, error) {
+	size, err := windows.GetFileVersionInfoSize(path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get version info size of %s: %w", path, err)
+	}
+	block := make([]byte, size)
+	if err := windows.GetFileVersionInfo(path, 0, size, unsafe.Pointer(&block[0])); err != nil {
+		return nil, fmt.Errorf("failed to get version info of %s: %w", path, err)
+	}
+
+	var fixed *windows.VS_FIXEDFILEINFO
+	var n uint32
+	if err := windows.VerQueryValue(unsafe.Pointer(&block[0]), `\`, unsafe.Pointer(&fixed), &n); err != nil {
+		return nil, fmt.Errorf("failed to read fixed version info of %s: %w", path, err)
+	}
+	info := &VersionInfo{
+		FileVersion:    splitVersion(fixed.FileVersionMS, fixed.FileVersionLS),
+		ProductVersion: splitVersion(fixed.ProductVersionMS, fixed.ProductVersionLS),
+	}
+
+	var translation *[2]uint16
+	if windows.VerQueryValue(unsafe.Pointer(&block[0]), `\VarFileInfo\Translation`, unsafe.Pointer(&translation), &n) == nil && n >= 4 {
+		prefix := fmt.Sprintf(`\StringFileInfo\%04x%04x\`, translation[0], translation[1])
+		info.CompanyName = versionString(block, prefix+"CompanyName")
+		info.FileDescription = versionString(block, prefix+"FileDescription")
+		info.ProductName = versionString(block, prefix+"ProductName")
+	}
+	return info, nil
+}
+
+// splitVersion converts the packed halves of a VS_FIXEDFILEINFO version.
+func splitVersion(ms, ls uint32) Version {
+	return Version{uint16(ms >> 16), uint16(ms), uint16(ls >> 16), uint16(ls)}
+}
+
+// versionString returns the string value at subBlock of a version resource, or "".
+func versionString(block []byte, subBlock string) string {
+	var p *uint16
+	var n uint32
+	if windows.VerQueryValue(unsafe.Pointer(&block[0]), subBlock, unsafe.Pointer(&p), &n) != nil || n == 0 {
+		return ""
+	}
+	return windows.UTF16ToString(unsafe.Slice(p, n))
+}
+
+// VersionInfo returns the version resource of the original DLL, loading it if needed.
+func (m *Manager) VersionInfo() (*VersionInfo, error) {
+	dll, err := m.dll()
+	if err != nil {
+		return nil, err
+	}
+	path, err := moduleFile(dll)
+	if err != nil {
+		return nil, err
+	}
+	return ReadVersionInfo(path)
+}
+
+// minVersionVerifier accepts files whose file version is at least required.
+func minVersionVerifier(required string) verifier {
+	return verifier{name: "version", check: func(path string) error {
+		want, err := ParseVersion(required)
+		if err != nil {
+			return err
+		}
+		info, err := ReadVersionInfo(path)
+		if err != nil {
+			return err
+		}
+		if info.FileVersion.Compare(want) < 0 {
+			return fmt.Errorf("file version %s is older than the required %s", info.FileVersion, want)
+		}
+		return nil
+	}}
+}
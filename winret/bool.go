@@ -0,0 +1,32 @@
+package winret
+
+import "syscall"
+
+// BOOL values, as returned in r1.
+const (
+	FALSE uintptr = 0
+	TRUE  uintptr = 1
+)
+
+// IsTrue reports whether a BOOL result is TRUE. Only the low 32 bits of r1 are defined.
+func IsTrue(r1 uintptr) bool {
+	return uint32(r1) != 0
+}
+
+// BOOL converts b to a BOOL result.
+func BOOL(b bool) uintptr {
+	if b {
+		return TRUE
+	}
+	return FALSE
+}
+
+// ReturnTrue returns TRUE as the results of a hook.
+func ReturnTrue() (r1, r2 uintptr, lastErr error) {
+	return TRUE, 0, nil
+}
+
+// ReturnFalse returns FALSE as the results of a hook, with errno as the last error.
+func ReturnFalse(errno syscall.Errno) (r1, r2 uintptr, lastErr error) {
+	return FALSE, 0, errno
+}
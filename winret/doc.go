@@ -0,0 +1,12 @@
+// Package winret expresses the result conventions of Windows functions, for hooks that
+// inspect or replace what an export returns.
+//
+// Exports report success in one of three ways: an HRESULT, as COM and many newer APIs
+// do; an NTSTATUS, as ntdll does; or a BOOL together with the thread's last error, as
+// most of Win32 does. Each has a type or helpers here, with Return functions producing
+// the results a proxdll.Hook returns:
+//
+//	m.RegisterHook("DllRegisterServer", proxdll.Stub(winret.E_ACCESSDENIED.Return()))
+//
+// The package does not depend on Windows and can be used in tests on any platform.
+package winret
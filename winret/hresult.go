@@ -0,0 +1,104 @@
+package winret
+
+import "fmt"
+
+// HRESULT is a COM result code. Negative values report failure.
+type HRESULT int32
+
+// Common HRESULT values.
+const (
+	S_OK                      HRESULT = 0
+	S_FALSE                   HRESULT = 1
+	E_NOTIMPL                 HRESULT = -0x7fffbfff // 0x80004001
+	E_NOINTERFACE             HRESULT = -0x7fffbffe // 0x80004002
+	E_POINTER                 HRESULT = -0x7fffbffd // 0x80004003
+	E_ABORT                   HRESULT = -0x7fffbffc // 0x80004004
+	E_FAIL                    HRESULT = -0x7fffbffb // 0x80004005
+	E_UNEXPECTED              HRESULT = -0x7fff0001 // 0x8000FFFF
+	E_ACCESSDENIED            HRESULT = -0x7ff8fffb // 0x80070005
+	E_HANDLE                  HRESULT = -0x7ff8fffa // 0x80070006
+	E_OUTOFMEMORY             HRESULT = -0x7ff8fff2 // 0x8007000E
+	E_INVALIDARG              HRESULT = -0x7ff8ffa9 // 0x80070057
+	E_PENDING                 HRESULT = -0x7ffffff6 // 0x8000000A
+	CLASS_E_NOAGGREGATION     HRESULT = -0x7ffbfef0 // 0x80040110
+	CLASS_E_CLASSNOTAVAILABLE HRESULT = -0x7ffbfeef // 0x80040111
+	REGDB_E_CLASSNOTREG       HRESULT = -0x7ffbfeac // 0x80040154
+)
+
+// Facility codes of an HRESULT.
+const (
+	FACILITY_NULL     = 0
+	FACILITY_RPC      = 1
+	FACILITY_DISPATCH = 2
+	FACILITY_STORAGE  = 3
+	FACILITY_ITF      = 4
+	FACILITY_WIN32    = 7
+	FACILITY_WINDOWS  = 8
+	FACILITY_CONTROL  = 10
+)
+
+// facilityNT marks an HRESULT wrapping an NTSTATUS, as made by HRESULT_FROM_NT.
+const facilityNT = 0x10000000
+
+// MakeHRESULT builds an HRESULT from its parts, as the MAKE_HRESULT macro does.
+func MakeHRESULT(failed bool, facility, code uint16) HRESULT {
+	var sev uint32
+	if failed {
+		sev = 1 << 31
+	}
+	return HRESULT(sev | uint32(facility&0x1fff)<<16 | uint32(code))
+}
+
+// HRESULTFromWin32 converts a Win32 error code, such as a syscall.Errno, to an HRESULT,
+// as the HRESULT_FROM_WIN32 macro does.
+func HRESULTFromWin32(code uint32) HRESULT {
+	if int32(code) <= 0 {
+		return HRESULT(code)
+	}
+	return MakeHRESULT(true, FACILITY_WIN32, uint16(code))
+}
+
+// HRESULTFromNT converts an NTSTATUS to an HRESULT, as the HRESULT_FROM_NT macro does.
+func HRESULTFromNT(status NTSTATUS) HRESULT {
+	return HRESULT(uint32(status) | facilityNT)
+}
+
+// HRESULTOf returns the HRESULT in the r1 result of a call.
+func HRESULTOf(r1 uintptr) HRESULT {
+	return HRESULT(int32(r1))
+}
+
+// Succeeded reports whether hr reports success, as the SUCCEEDED macro does.
+func Succeeded(hr HRESULT) bool {
+	return hr >= 0
+}
+
+// Failed reports whether hr reports failure, as the FAILED macro does.
+func Failed(hr HRESULT) bool {
+	return hr < 0
+}
+
+// FacilityOf returns the facility of hr, such as FACILITY_WIN32.
+func FacilityOf(hr HRESULT) uint16 {
+	return uint16(uint32(hr)>>16) & 0x1fff
+}
+
+// CodeOf returns the code of hr, which for FACILITY_WIN32 is the Win32 error code.
+func CodeOf(hr HRESULT) uint16 {
+	return uint16(hr)
+}
+
+// Uintptr returns hr as the r1 result of a call.
+func (hr HRESULT) Uintptr() uintptr {
+	return uintptr(uint32(hr))
+}
+
+// Return returns hr as the results of a hook.
+func (hr HRESULT) Return() (r1, r2 uintptr, lastErr error) {
+	return hr.Uintptr(), 0, nil
+}
+
+// Error returns hr in hex, so a failed HRESULT can be returned as an error.
+func (hr HRESULT) Error() string {
+	return fmt.Sprintf("HRESULT 0x%08X", uint32(hr))
+}
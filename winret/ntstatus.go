@@ -0,0 +1,60 @@
+package winret
+
+import "fmt"
+
+// NTSTATUS is a result code of the native API. Its top two bits hold the severity:
+// success, information, warning or error.
+type NTSTATUS int32
+
+// Common NTSTATUS values.
+const (
+	STATUS_SUCCESS               NTSTATUS = 0
+	STATUS_PENDING               NTSTATUS = 0x103
+	STATUS_BUFFER_OVERFLOW       NTSTATUS = -0x7ffffffb // 0x80000005
+	STATUS_NO_MORE_ENTRIES       NTSTATUS = -0x7fffffe6 // 0x8000001A
+	STATUS_UNSUCCESSFUL          NTSTATUS = -0x3fffffff // 0xC0000001
+	STATUS_NOT_IMPLEMENTED       NTSTATUS = -0x3ffffffe // 0xC0000002
+	STATUS_INVALID_HANDLE        NTSTATUS = -0x3ffffff8 // 0xC0000008
+	STATUS_INVALID_PARAMETER     NTSTATUS = -0x3ffffff3 // 0xC000000D
+	STATUS_NO_MEMORY             NTSTATUS = -0x3fffffe9 // 0xC0000017
+	STATUS_ACCESS_DENIED         NTSTATUS = -0x3fffffde // 0xC0000022
+	STATUS_BUFFER_TOO_SMALL      NTSTATUS = -0x3fffffdd // 0xC0000023
+	STATUS_OBJECT_NAME_NOT_FOUND NTSTATUS = -0x3fffffcc // 0xC0000034
+	STATUS_NOT_SUPPORTED         NTSTATUS = -0x3fffff45 // 0xC00000BB
+)
+
+// NTSTATUSOf returns the NTSTATUS in the r1 result of a call.
+func NTSTATUSOf(r1 uintptr) NTSTATUS {
+	return NTSTATUS(int32(r1))
+}
+
+// NTSuccess reports whether status is a success or information value, as the
+// NT_SUCCESS macro does.
+func NTSuccess(status NTSTATUS) bool {
+	return status >= 0
+}
+
+// NTError reports whether status has error severity.
+func NTError(status NTSTATUS) bool {
+	return uint32(status)>>30 == 3
+}
+
+// NTWarning reports whether status has warning severity.
+func NTWarning(status NTSTATUS) bool {
+	return uint32(status)>>30 == 2
+}
+
+// Uintptr returns status as the r1 result of a call.
+func (status NTSTATUS) Uintptr() uintptr {
+	return uintptr(uint32(status))
+}
+
+// Return returns status as the results of a hook.
+func (status NTSTATUS) Return() (r1, r2 uintptr, lastErr error) {
+	return status.Uintptr(), 0, nil
+}
+
+// Error returns status in hex, so a failed NTSTATUS can be returned as an error.
+func (status NTSTATUS) Error() string {
+	return fmt.Sprintf("NTSTATUS 0x%08X", uint32(status))
+}
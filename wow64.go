@@ -0,0 +1,95 @@
+//go:build windows
+
+package proxdll
+
+import (
+	"debug/pe"
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// IsWow64 reports whether the process is a 32-bit process running on 64-bit Windows, where
+// System32 is redirected to SysWOW64 unless a thread disables the redirection.
+var IsWow64 = sync.OnceValue(func() bool {
+	var process, native uint16
+	if err := windows.IsWow64Process2(windows.CurrentProcess(), &process, &native); err == nil {
+		return process != 0 // IMAGE_FILE_MACHINE_UNKNOWN for native processes
+	}
+	var wow64 bool
+	return windows.IsWow64Process(windows.CurrentProcess(), &wow64) == nil && wow64
+})
+
+// SystemWow64Directory returns the directory of the 32-bit system DLLs on 64-bit Windows,
+// usually C:\Windows\SysWOW64. It fails on 32-bit Windows, which has no such directory.
+func SystemWow64Directory() (string, error) {
+	buf := make([]uint16, windows.MAX_PATH)
+	r, _, err := procGetSystemWow64DirectoryW.Call(uintptr(unsafe.Pointer(&buf[0])), uintptr(len(buf)))
+	if r == 0 {
+		return "", fmt.Errorf("failed to get SysWOW64 directory: %w", err)
+	}
+	return windows.UTF16ToString(buf[:r]), nil
+}
+
+// WithoutFsRedirection runs fn with WOW64 file system redirection disabled on the calling
+// thread, so System32 names the native 64-bit system directory, and restores it afterwards.
+// Outside WOW64 it just runs fn. fn runs locked to the thread and must not load DLLs,
+// which would load the 64-bit copies.
+func WithoutFsRedirection(fn func() error) error {
+	if !IsWow64() {
+		return fn()
+	}
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	var old uintptr
+	if r, _, err := procWow64DisableWow64FsRedirection.Call(uintptr(unsafe.Pointer(&old))); r == 0 {
+		return fmt.Errorf("failed to disable file system redirection: %w", err)
+	}
+	defer procWow64RevertWow64FsRedirection.Call(old)
+	return fn()
+}
+
+// wow64Path maps a path inside System32 to SysWOW64 in a WOW64 process, so it names the
+// 32-bit DLL even on threads where the host has disabled file system redirection.
+func wow64Path(path string) string {
+	if !IsWow64() || !filepath.IsAbs(path) {
+		return path
+	}
+	system, err := windows.GetSystemDirectory()
+	if err != nil {
+		return path
+	}
+	rel, err := filepath.Rel(system, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, `..\`) {
+		return path
+	}
+	wow64, err := SystemWow64Directory()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(wow64, rel)
+}
+
+// checkImageBits returns an error if the PE file at path is a 64-bit image and the process
+// is 32-bit, which LoadLibrary would only reject as a bad executable format. Files that
+// cannot be parsed are left for the loader to report.
+func checkImageBits(path string) error {
+	if unsafe.Sizeof(uintptr(0)) != 4 {
+		return nil
+	}
+	f, err := pe.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	if _, ok := f.OptionalHeader.(*pe.OptionalHeader64); ok {
+		return fmt.Errorf("original DLL at %s is a 64-bit image, but the process is 32-bit", path)
+	}
+	return nil
+}